@@ -6,6 +6,7 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
@@ -18,6 +19,9 @@ var (
 type Config struct {
 	Name string
 	*viper.Viper
+
+	callbacks   []func()
+	callbacksMu sync.Mutex
 }
 
 var (
@@ -71,7 +75,7 @@ func parse(filename string, watch bool) (*Config, error) {
 		return v, nil
 	}
 
-	v := &Config{filename, viper.New()}
+	v := &Config{Name: filename, Viper: viper.New()}
 
 	if err := v.loadConfig(); err != nil {
 		return nil, err
@@ -79,6 +83,9 @@ func parse(filename string, watch bool) (*Config, error) {
 
 	// 监控配置文件变化并热加载程序
 	if watch {
+		v.Viper.OnConfigChange(func(fsnotify.Event) {
+			v.notify()
+		})
 		v.WatchConfig()
 	}
 
@@ -109,3 +116,21 @@ func (c *Config) LoadEnv(prefix string) {
 	// exp. for key db.url, set env with name DB_URL
 	c.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 }
+
+//OnChange 注册配置热加载回调，配置文件变化并重新加载后依次执行
+func (c *Config) OnChange(fn func()) {
+	c.callbacksMu.Lock()
+	defer c.callbacksMu.Unlock()
+	c.callbacks = append(c.callbacks, fn)
+}
+
+func (c *Config) notify() {
+	c.callbacksMu.Lock()
+	callbacks := make([]func(), len(c.callbacks))
+	copy(callbacks, c.callbacks)
+	c.callbacksMu.Unlock()
+
+	for _, fn := range callbacks {
+		fn()
+	}
+}
@@ -13,6 +13,16 @@ import (
 
 var gormDBv2 *gorm.DB
 
+//NamingStrategy gorm v2 表名及列名规则，原生SQL中的表名(如t_user_role)依赖该规则
+var NamingStrategy = schema.NamingStrategy{
+	// table name prefix, table for `User` would be `t_users`
+	TablePrefix: "t_",
+	// use singular table name, table for `User` would be `user` with this option enabled
+	SingularTable: true,
+	// use name replacer to change struct/field name before convert it to db name
+	NameReplacer: strings.NewReplacer("CID", "Cid"),
+}
+
 //Gormv2 获取gorm v2 默认实例
 func Gormv2(ctx context.Context) (*gorm.DB, error) {
 	if gormDBv2 == nil {
@@ -25,17 +35,16 @@ func Gormv2(ctx context.Context) (*gorm.DB, error) {
 func InitGormDBv2(dsn string, maxopen, maxidle int, lv logger.LogLevel) (*gorm.DB, error) {
 
 	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(lv),
-		NamingStrategy: schema.NamingStrategy{
-			// table name prefix, table for `User` would be `t_users`
-			TablePrefix: "t_",
-			// use singular table name, table for `User` would be `user` with this option enabled
-			SingularTable: true,
-			// use name replacer to change struct/field name before convert it to db name
-			NameReplacer: strings.NewReplacer("CID", "Cid"),
-		},
+		Logger:         logger.Default.LogMode(lv),
+		NamingStrategy: NamingStrategy,
 	})
 	if err == nil {
+		if err := db.Use(&TracingPlugin{}); err != nil {
+			return nil, err
+		}
+		if err := db.Use(&TenantPlugin{}); err != nil {
+			return nil, err
+		}
 		sqlDB, err := db.DB()
 		if err == nil {
 			sqlDB.SetMaxOpenConns(maxopen)
@@ -55,3 +64,8 @@ func InitDefaultGormDBv2(dsn string, maxopen, maxidle int, lv logger.LogLevel) (
 	gormDBv2 = db
 	return db, nil
 }
+
+//SetGormDBv2 设置gorm v2 默认实例
+func SetGormDBv2(db *gorm.DB) {
+	gormDBv2 = db
+}
@@ -0,0 +1,148 @@
+package datasource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// 包含该字段的model自动按租户隔离
+const tenantFieldName = "TenantID"
+
+//ErrMissingTenant 访问租户隔离的表时context中没有租户
+var ErrMissingTenant = errors.New("tenant is required")
+
+type tenantKey struct{}
+
+type withoutTenantKey struct{}
+
+//WithTenant 将租户ID写入context，DB操作使用该context时按租户隔离
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+//TenantFromContext 从context中获取租户ID
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenantID, ok := ctx.Value(tenantKey{}).(string)
+	return tenantID, ok && len(tenantID) > 0
+}
+
+//WithoutTenant 显式跳过租户隔离，仅用于管理后台、定时任务等跨租户操作
+func WithoutTenant(ctx context.Context) context.Context {
+	return context.WithValue(ctx, withoutTenantKey{}, true)
+}
+
+//TenantPlugin gorm v2 多租户插件，对包含TenantID字段的model：
+// 查询、更新、删除自动追加 tenant_id = ? 条件，插入自动填充TenantID；context中没有租户时报ErrMissingTenant
+type TenantPlugin struct{}
+
+//Name 实现gorm.Plugin
+func (p *TenantPlugin) Name() string {
+	return "tenant"
+}
+
+//Initialize 实现gorm.Plugin，注册callback
+func (p *TenantPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	for _, err := range []error{
+		cb.Create().Before("gorm:create").Register("tenant:create", p.create),
+		cb.Query().Before("gorm:query").Register("tenant:query", p.scope(false)),
+		cb.Update().Before("gorm:update").Register("tenant:update", p.scope(true)),
+		cb.Delete().Before("gorm:delete").Register("tenant:delete", p.scope(true)),
+		cb.Row().Before("gorm:row").Register("tenant:row", p.scope(false)),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tenant 返回租户字段及租户ID，model不需要隔离或显式跳过时field为nil
+func (p *TenantPlugin) tenant(db *gorm.DB) (*schema.Field, string) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return nil, ""
+	}
+	field := db.Statement.Schema.LookUpField(tenantFieldName)
+	if field == nil {
+		return nil, ""
+	}
+
+	ctx := db.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if skip, _ := ctx.Value(withoutTenantKey{}).(bool); skip {
+		return nil, ""
+	}
+	tenantID, ok := TenantFromContext(ctx)
+	if !ok {
+		db.AddError(fmt.Errorf("%w: %s", ErrMissingTenant, db.Statement.Schema.Table))
+		return nil, ""
+	}
+	return field, tenantID
+}
+
+func (p *TenantPlugin) scope(write bool) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		field, tenantID := p.tenant(db)
+		if field == nil || (write && !hasConditions(db)) {
+			return
+		}
+		db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+			clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: tenantID},
+		}})
+	}
+}
+
+// hasConditions 更新、删除没有条件时不追加租户条件，保留gorm的ErrMissingWhereClause保护
+func hasConditions(db *gorm.DB) bool {
+	if _, ok := db.Statement.Clauses["WHERE"]; ok || db.AllowGlobalUpdate {
+		return true
+	}
+
+	// 按主键更新、删除时gorm在callback中追加主键条件
+	rv := db.Statement.ReflectValue
+	pk := db.Statement.Schema.PrioritizedPrimaryField
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		return rv.Len() > 0
+	case reflect.Struct:
+		if pk != nil {
+			_, zero := pk.ValueOf(rv)
+			return !zero
+		}
+	}
+	return false
+}
+
+func (p *TenantPlugin) create(db *gorm.DB) {
+	field, tenantID := p.tenant(db)
+	if field == nil {
+		return
+	}
+
+	set := func(rv reflect.Value) {
+		if v, zero := field.ValueOf(rv); !zero && v != tenantID {
+			db.AddError(fmt.Errorf("tenant mismatch: %v != %s", v, tenantID))
+			return
+		}
+		if err := field.Set(rv, tenantID); err != nil {
+			db.AddError(err)
+		}
+	}
+	rv := db.Statement.ReflectValue
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			set(reflect.Indirect(rv.Index(i)))
+		}
+	case reflect.Struct:
+		set(rv)
+	}
+}
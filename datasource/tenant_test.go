@@ -0,0 +1,71 @@
+package datasource
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type tenantPost struct {
+	gorm.Model
+	TenantID string
+	Title    string
+}
+
+func newDryRunDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(mysql.New(mysql.Config{
+		DSN:                       "root:root@tcp(127.0.0.1:3306)/test",
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{DryRun: true, DisableAutomaticPing: true, Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Use(&TenantPlugin{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func Test_TenantPlugin(t *testing.T) {
+	db := newDryRunDB(t)
+	ctx := WithTenant(context.Background(), "acme")
+
+	convey.Convey("TenantPlugin query", t, func() {
+		var posts []tenantPost
+		stmt := db.WithContext(ctx).Where("title = ?", "a").Find(&posts).Statement
+		convey.So(stmt.SQL.String(), convey.ShouldContainSubstring, "`tenant_posts`.`tenant_id` = ?")
+		convey.So(stmt.Vars, convey.ShouldContain, "acme")
+	})
+
+	convey.Convey("TenantPlugin create", t, func() {
+		post := &tenantPost{Title: "a"}
+		err := db.WithContext(ctx).Create(post).Error
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(post.TenantID, convey.ShouldEqual, "acme")
+
+		err = db.WithContext(ctx).Create(&tenantPost{TenantID: "other"}).Error
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+
+	convey.Convey("TenantPlugin missing tenant", t, func() {
+		var posts []tenantPost
+		err := db.WithContext(context.Background()).Find(&posts).Error
+		convey.So(errors.Is(err, ErrMissingTenant), convey.ShouldBeTrue)
+
+		err = db.WithContext(WithoutTenant(context.Background())).Find(&posts).Error
+		convey.So(err, convey.ShouldBeNil)
+	})
+
+	convey.Convey("TenantPlugin update without conditions", t, func() {
+		err := db.WithContext(ctx).Model(&tenantPost{}).Update("title", "b").Error
+		convey.So(errors.Is(err, gorm.ErrMissingWhereClause), convey.ShouldBeTrue)
+
+		stmt := db.WithContext(ctx).Model(&tenantPost{Model: gorm.Model{ID: 1}}).Update("title", "b").Statement
+		convey.So(stmt.SQL.String(), convey.ShouldContainSubstring, "`tenant_id` = ?")
+	})
+}
@@ -0,0 +1,84 @@
+package errcode
+
+import "net/http"
+
+// errInternal 非CustomError的错误对客户端统一返回InternalError
+var errInternal *CustomError
+
+// 内置错误码，通过NewCustomError使用时可附带具体的错误信息
+// InvalidParameter、InvalidJWTClaims由protocol包注册
+func init() {
+	errInternal = Register(Definition{
+		Code:       ErrCodeInternalError,
+		HTTPStatus: http.StatusInternalServerError,
+		Retryable:  true,
+		Messages: map[string]string{
+			LangZH: "服务内部错误，请稍后重试",
+			LangEN: "Internal error, please try again later",
+		},
+	})
+	Register(Definition{
+		Code:       ErrNoAuthToken,
+		HTTPStatus: http.StatusUnauthorized,
+		Messages: map[string]string{
+			LangZH: "未登录",
+			LangEN: "No auth token",
+		},
+	})
+	Register(Definition{
+		Code:       ErrInvalidAuthToken,
+		HTTPStatus: http.StatusUnauthorized,
+		Messages: map[string]string{
+			LangZH: "登录态无效，请重新登录",
+			LangEN: "Invalid auth token, please log in again",
+		},
+	})
+	Register(Definition{
+		Code:       ErrExpiredAuthToken,
+		HTTPStatus: http.StatusUnauthorized,
+		Messages: map[string]string{
+			LangZH: "登录态已过期",
+			LangEN: "Auth token expired",
+		},
+	})
+	Register(Definition{
+		Code:       ErrRevokedAuthToken,
+		HTTPStatus: http.StatusUnauthorized,
+		Messages: map[string]string{
+			LangZH: "登录态已注销，请重新登录",
+			LangEN: "Auth token revoked, please log in again",
+		},
+	})
+	Register(Definition{
+		Code:       ErrNoJWTClaims,
+		HTTPStatus: http.StatusUnauthorized,
+		Messages: map[string]string{
+			LangZH: "登录态缺少用户信息",
+			LangEN: "No claims in auth token",
+		},
+	})
+	Register(Definition{
+		Code:       ErrInvalidApiKey,
+		HTTPStatus: http.StatusUnauthorized,
+		Messages: map[string]string{
+			LangZH: "API密钥无效",
+			LangEN: "Invalid API key",
+		},
+	})
+	Register(Definition{
+		Code:       ErrExpiredApiKey,
+		HTTPStatus: http.StatusUnauthorized,
+		Messages: map[string]string{
+			LangZH: "API密钥已过期",
+			LangEN: "API key expired",
+		},
+	})
+	Register(Definition{
+		Code:       ErrInsufficientScope,
+		HTTPStatus: http.StatusForbidden,
+		Messages: map[string]string{
+			LangZH: "API密钥没有该接口的授权范围",
+			LangEN: "API key scope insufficient",
+		},
+	})
+}
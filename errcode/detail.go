@@ -0,0 +1,41 @@
+package errcode
+
+//Detail 错误详情，如参数校验失败的字段、规则及消息
+type Detail struct {
+	Field    string `json:"Field"`
+	Rule     string `json:"Rule"`
+	Message  string `json:"Message"`
+	messages map[string]string
+}
+
+//NewDetail 新建错误详情，messages为各语言的消息，必须包含DefaultLanguage
+func NewDetail(field, rule string, messages map[string]string) Detail {
+	return Detail{
+		Field:    field,
+		Rule:     rule,
+		Message:  messages[DefaultLanguage],
+		messages: messages,
+	}
+}
+
+//WithDetails 返回附带错误详情的副本
+func (e *CustomError) WithDetails(details ...Detail) *CustomError {
+	w := *e
+	w.Details = details
+	return &w
+}
+
+//LocalizeDetails 错误详情在指定语言下的副本，不支持该语言时使用默认语言的消息
+func LocalizeDetails(details []Detail, lang string) []Detail {
+	if len(details) == 0 {
+		return nil
+	}
+	localized := make([]Detail, len(details))
+	for i, d := range details {
+		localized[i] = d
+		if msg, ok := d.messages[lang]; ok {
+			localized[i].Message = msg
+		}
+	}
+	return localized
+}
@@ -1,33 +1,80 @@
 package errcode
 
-import "fmt"
+import "errors"
 
 //CustomError 自定义Error类型
+// Message为返回给客户端的消息，cause为内部原因，只记录到日志，可通过errors.Is/As检查
 type CustomError struct {
-	Code    string `json:"Code"`
-	Message string `json:"Message"`
-	error
+	Code    string                 `json:"Code"`
+	Message string                 `json:"Message"`
+	Params  map[string]interface{} `json:"-"`                 // 消息模板参数，用于生成其他语言的消息
+	Details []Detail               `json:"Details,omitempty"` // 错误详情，如参数校验失败的字段
+	cause   error
+	stack   []uintptr
 }
 
-//Error 自定义类型Error实现error interface
+//Error 自定义类型Error实现error interface，包含内部原因
 func (e CustomError) Error() string {
-	return e.Message
+	if e.cause == nil || e.cause.Error() == e.Message {
+		return e.Message
+	}
+	return e.Message + "->" + e.cause.Error()
+}
+
+//Unwrap 返回内部原因
+func (e CustomError) Unwrap() error {
+	return e.cause
 }
 
-//Wrap wrap inner err
+//Is 错误码相同即认为是同一错误，用于errors.Is(err, protocol.ErrCodeXXX)
+func (e CustomError) Is(target error) bool {
+	switch t := target.(type) {
+	case *CustomError:
+		return t != nil && t.Code == e.Code
+	case CustomError:
+		return t.Code == e.Code
+	}
+	return false
+}
+
+//As 使errors.As可以在*CustomError和CustomError之间转换
+func (e CustomError) As(target interface{}) bool {
+	switch t := target.(type) {
+	case **CustomError:
+		*t = &e
+		return true
+	case *CustomError:
+		*t = e
+		return true
+	}
+	return false
+}
+
+//Wrap 返回以err为内部原因的副本，客户端消息不变
 func (e *CustomError) Wrap(err error) *CustomError {
-	return &CustomError{
+	return e.wrap(err, 1)
+}
+
+func (e *CustomError) wrap(err error, skip int) *CustomError {
+	w := &CustomError{
 		Code:    e.Code,
-		Message: fmt.Sprintf("%s->%s", e.Message, err.Error()),
+		Message: e.Message,
+		Params:  e.Params,
+		Details: e.Details,
+		cause:   err,
+	}
+	if !hasStack(err) {
+		w.stack = callers(skip + 1)
 	}
+	return w
 }
 
-//Set set err message
+//Set 返回以err的内容为客户端消息的副本，err同时作为内部原因
 func (e *CustomError) Set(err error) *CustomError {
-	return &CustomError{
-		Code:    e.Code,
-		Message: err.Error(),
-	}
+	w := e.wrap(err, 1)
+	w.Message = err.Error()
+	w.Params = nil
+	return w
 }
 
 // error code that can be assocation custom messages
@@ -36,25 +83,47 @@ var ErrInvalidParam = "InvalidParameter"
 var ErrNoAuthToken = "NoAuthToken"
 var ErrInvalidAuthToken = "InvalidAuthToken"
 var ErrExpiredAuthToken = "ExpiredAuthToken"
+var ErrRevokedAuthToken = "RevokedAuthToken"
 var ErrNoJWTClaims = "NoJWTClaims"
 var ErrInvalidJWTClaims = "InvalidJWTClaims"
+var ErrInvalidApiKey = "InvalidApiKey"
+var ErrExpiredApiKey = "ExpiredApiKey"
+var ErrInsufficientScope = "InsufficientScope"
+
+//WithParams 返回带消息模板参数的副本，使用注册的模板生成消息，未注册时以原消息为模板
+func (e *CustomError) WithParams(params map[string]interface{}) *CustomError {
+	msg := e.Message
+	if def, ok := Lookup(e.Code); ok {
+		msg = def.Messages[DefaultLanguage]
+	}
+	return &CustomError{
+		Code:    e.Code,
+		Message: render(msg, params),
+		Params:  params,
+		Details: e.Details,
+		cause:   e.cause,
+		stack:   callers(1),
+	}
+}
 
 //NewCustomError 新建自定义Error
 func NewCustomError(code, message string) *CustomError {
 	return &CustomError{
 		Code:    code,
 		Message: message,
+		stack:   callers(1),
 	}
 }
 
-//ErrorCode 获取Error的ErrorCode
+//Internal 将err包装为InternalError，客户端只能看到注册的消息
+func Internal(err error) *CustomError {
+	return errInternal.wrap(err, 1)
+}
+
+//ErrorCode 获取Error的ErrorCode，沿错误链查找第一个CustomError
 func ErrorCode(err error) string {
-	pcerr, ok := err.(*CustomError)
-	if ok {
-		return pcerr.Code
-	}
-	cerr, ok := err.(CustomError)
-	if ok {
+	var cerr *CustomError
+	if errors.As(err, &cerr) {
 		return cerr.Code
 	}
 	return ErrCodeInternalError
@@ -0,0 +1,70 @@
+package errcode
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+var errTestNotFound = Register(Definition{
+	Code:     "TestNotFound",
+	Messages: map[string]string{LangZH: "记录不存在", LangEN: "record not found"},
+})
+
+func Test_ErrorChain(t *testing.T) {
+	cause := errors.New("record not found")
+	convey.Convey("wrap", t, func() {
+		err := errTestNotFound.Wrap(cause)
+		convey.So(err.Message, convey.ShouldEqual, "记录不存在")
+		convey.So(err.Error(), convey.ShouldEqual, "记录不存在->record not found")
+		convey.So(errors.Unwrap(err), convey.ShouldEqual, cause)
+		convey.So(errors.Is(err, cause), convey.ShouldBeTrue)
+		convey.So(errors.Is(err, errTestNotFound), convey.ShouldBeTrue)
+		convey.So(errors.Is(err, errInternal), convey.ShouldBeFalse)
+
+		set := errTestNotFound.Set(cause)
+		convey.So(set.Message, convey.ShouldEqual, "record not found")
+		convey.So(set.Error(), convey.ShouldEqual, "record not found")
+		convey.So(errors.Is(set, cause), convey.ShouldBeTrue)
+	})
+
+	convey.Convey("walk chain", t, func() {
+		err := fmt.Errorf("load user: %w", errTestNotFound.Wrap(cause))
+		convey.So(ErrorCode(err), convey.ShouldEqual, "TestNotFound")
+		convey.So(errors.Is(err, errTestNotFound), convey.ShouldBeTrue)
+
+		var cerr *CustomError
+		convey.So(errors.As(err, &cerr), convey.ShouldBeTrue)
+		convey.So(cerr.Code, convey.ShouldEqual, "TestNotFound")
+
+		// 值类型的CustomError同样可以匹配
+		var value error = CustomError{Code: "TestNotFound", Message: "x"}
+		convey.So(ErrorCode(fmt.Errorf("%w", value)), convey.ShouldEqual, "TestNotFound")
+		convey.So(errors.Is(value, errTestNotFound), convey.ShouldBeTrue)
+
+		convey.So(ErrorCode(cause), convey.ShouldEqual, ErrCodeInternalError)
+		convey.So(ErrorCode(nil), convey.ShouldEqual, ErrCodeInternalError)
+
+		internal := Internal(cause)
+		convey.So(internal.Code, convey.ShouldEqual, ErrCodeInternalError)
+		convey.So(internal.Message, convey.ShouldNotContainSubstring, "record not found")
+		convey.So(errors.Is(internal, cause), convey.ShouldBeTrue)
+	})
+
+	convey.Convey("stack", t, func() {
+		convey.So(StackTrace(NewCustomError("TestNotFound", "x")), convey.ShouldBeEmpty)
+
+		SetCaptureStack(true)
+		defer SetCaptureStack(false)
+		inner := NewCustomError("TestNotFound", "x")
+		err := errInternal.Wrap(fmt.Errorf("query: %w", inner))
+		stack := StackTrace(err)
+		convey.So(strings.SplitN(stack, "\n", 2)[0], convey.ShouldEndWith, "errcode.Test_ErrorChain.func3")
+		// 错误链中已有调用栈时包装不再记录
+		convey.So(err.stack, convey.ShouldBeEmpty)
+		convey.So(StackTrace(errInternal.Wrap(cause)), convey.ShouldContainSubstring, "err_test.go")
+	})
+}
@@ -0,0 +1,158 @@
+package errcode
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 错误消息的语言
+const (
+	LangZH = "zh"
+	LangEN = "en"
+)
+
+//DefaultLanguage 注册错误码返回的CustomError使用该语言的消息
+var DefaultLanguage = LangZH
+
+//Definition 错误码定义
+type Definition struct {
+	Code       string
+	HTTPStatus int               // 开启真实HTTP状态码时返回的状态码
+	Retryable  bool              // 客户端是否可以稍后重试相同请求
+	Messages   map[string]string // 各语言的消息模板，{name}为参数占位符，必须包含DefaultLanguage
+}
+
+//Message 指定语言的消息模板，不支持该语言时使用默认语言
+func (d *Definition) Message(lang string) string {
+	if msg, ok := d.Messages[lang]; ok {
+		return msg
+	}
+	return d.Messages[DefaultLanguage]
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]*Definition)
+)
+
+//Register 注册错误码，返回使用默认语言消息的CustomError，错误码重复注册或缺少默认语言消息时panic
+func Register(def Definition) *CustomError {
+	msg, ok := def.Messages[DefaultLanguage]
+	if len(def.Code) == 0 || !ok {
+		panic(fmt.Sprintf("errcode %q: code and %s message required", def.Code, DefaultLanguage))
+	}
+	if def.HTTPStatus == 0 {
+		def.HTTPStatus = http.StatusBadRequest
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := registry[def.Code]; ok {
+		panic(fmt.Sprintf("errcode %q registered twice", def.Code))
+	}
+	registry[def.Code] = &def
+	return &CustomError{Code: def.Code, Message: msg}
+}
+
+//Lookup 查询错误码定义
+func Lookup(code string) (*Definition, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	def, ok := registry[code]
+	return def, ok
+}
+
+//Definitions 全部错误码定义，按错误码排序
+func Definitions() []*Definition {
+	registryMu.RLock()
+	defs := make([]*Definition, 0, len(registry))
+	for _, def := range registry {
+		defs = append(defs, def)
+	}
+	registryMu.RUnlock()
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Code < defs[j].Code })
+	return defs
+}
+
+//HTTPStatus 错误对应的HTTP状态码，未注册的错误码及非CustomError返回500
+func HTTPStatus(err error) int {
+	if def, ok := Lookup(ErrorCode(err)); ok {
+		return def.HTTPStatus
+	}
+	return http.StatusInternalServerError
+}
+
+//Retryable 错误是否可以稍后重试
+func Retryable(err error) bool {
+	def, ok := Lookup(ErrorCode(err))
+	return ok && def.Retryable
+}
+
+//Localize 错误在指定语言下的消息
+// 默认语言使用错误自身的消息(可能是具体的错误信息)，其他语言使用注册的消息模板，未注册时使用错误自身的消息
+func Localize(e *CustomError, lang string) string {
+	if lang == DefaultLanguage {
+		return e.Message
+	}
+	def, ok := Lookup(e.Code)
+	if !ok {
+		return e.Message
+	}
+	msg, ok := def.Messages[lang]
+	if !ok {
+		return e.Message
+	}
+	return render(msg, e.Params)
+}
+
+// render 替换消息模板中的{name}参数，没有对应参数的占位符保持不变
+func render(msg string, params map[string]interface{}) string {
+	if len(params) == 0 {
+		return msg
+	}
+	pairs := make([]string, 0, len(params)*2)
+	for k, v := range params {
+		pairs = append(pairs, "{"+k+"}", fmt.Sprint(v))
+	}
+	return strings.NewReplacer(pairs...).Replace(msg)
+}
+
+//WriteMarkdown 输出错误码文档，languages为消息列的语言
+func WriteMarkdown(w io.Writer, languages []string) error {
+	header := "| 错误码 | HTTP状态码 | 可重试 |"
+	sep := "| --- | --- | --- |"
+	for _, lang := range languages {
+		header += " 消息(" + lang + ") |"
+		sep += " --- |"
+	}
+	if _, err := fmt.Fprintln(w, header+"\n"+sep); err != nil {
+		return err
+	}
+	for _, def := range Definitions() {
+		retryable := "否"
+		if def.Retryable {
+			retryable = "是"
+		}
+		row := fmt.Sprintf("| %s | %d | %s |", def.Code, def.HTTPStatus, retryable)
+		for _, lang := range languages {
+			row += " " + strings.Replace(def.Message(lang), "|", "\\|", -1) + " |"
+		}
+		if _, err := fmt.Fprintln(w, row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//WriteJSON 以JSON数组输出全部错误码定义
+func WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(Definitions())
+}
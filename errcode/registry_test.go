@@ -0,0 +1,42 @@
+package errcode
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Register(t *testing.T) {
+	convey.Convey("register", t, func() {
+		e := Register(Definition{
+			Code:     "TestTooLong",
+			Messages: map[string]string{LangZH: "长度不能超过{max}", LangEN: "length exceeds {max}"},
+		})
+		convey.So(e.Code, convey.ShouldEqual, "TestTooLong")
+		convey.So(e.Message, convey.ShouldEqual, "长度不能超过{max}")
+		convey.So(HTTPStatus(e), convey.ShouldEqual, http.StatusBadRequest)
+		convey.So(Retryable(e), convey.ShouldBeFalse)
+		convey.So(HTTPStatus(NewCustomError("TestUnknown", "")), convey.ShouldEqual, http.StatusInternalServerError)
+
+		convey.So(func() { Register(Definition{Code: "TestTooLong", Messages: map[string]string{LangZH: "x"}}) }, convey.ShouldPanic)
+		convey.So(func() { Register(Definition{Code: "TestNoDefault", Messages: map[string]string{LangEN: "x"}}) }, convey.ShouldPanic)
+
+		p := e.WithParams(map[string]interface{}{"max": 10})
+		convey.So(p.Message, convey.ShouldEqual, "长度不能超过10")
+		convey.So(Localize(p, LangZH), convey.ShouldEqual, "长度不能超过10")
+		convey.So(Localize(p, LangEN), convey.ShouldEqual, "length exceeds 10")
+		convey.So(Localize(p, "fr"), convey.ShouldEqual, "长度不能超过10")
+
+		// 默认语言保留具体的错误信息
+		custom := NewCustomError(ErrCodeInternalError, "连接超时")
+		convey.So(Localize(custom, LangZH), convey.ShouldEqual, "连接超时")
+		convey.So(Localize(custom, LangEN), convey.ShouldEqual, "Internal error, please try again later")
+		convey.So(Retryable(custom), convey.ShouldBeTrue)
+
+		var buf bytes.Buffer
+		convey.So(WriteMarkdown(&buf, []string{LangZH, LangEN}), convey.ShouldBeNil)
+		convey.So(buf.String(), convey.ShouldContainSubstring, "| TestTooLong | 400 | 否 | 长度不能超过{max} | length exceeds {max} |")
+	})
+}
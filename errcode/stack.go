@@ -0,0 +1,79 @@
+package errcode
+
+import (
+	"errors"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// 记录的调用栈最大深度
+const maxStackDepth = 32
+
+var captureStack int32
+
+//SetCaptureStack 设置创建、包装CustomError时是否记录调用栈，记录调用栈有一定开销，默认关闭
+func SetCaptureStack(enabled bool) {
+	var v int32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreInt32(&captureStack, v)
+}
+
+// callers 记录调用栈，skip为callers调用方之上跳过的层数
+func callers(skip int) []uintptr {
+	if atomic.LoadInt32(&captureStack) == 0 {
+		return nil
+	}
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(skip+2, pcs)
+	return pcs[:n]
+}
+
+// hasStack 错误链中是否已有记录调用栈的CustomError
+func hasStack(err error) bool {
+	return len(innermostStack(err)) > 0
+}
+
+// innermostStack 错误链中最内层(最接近出错位置)的调用栈
+func innermostStack(err error) []uintptr {
+	var stack []uintptr
+	for ; err != nil; err = errors.Unwrap(err) {
+		switch e := err.(type) {
+		case *CustomError:
+			if len(e.stack) > 0 {
+				stack = e.stack
+			}
+		case CustomError:
+			if len(e.stack) > 0 {
+				stack = e.stack
+			}
+		}
+	}
+	return stack
+}
+
+//StackTrace 格式化错误链中最内层CustomError记录的调用栈，未记录时返回空
+func StackTrace(err error) string {
+	stack := innermostStack(err)
+	if len(stack) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	frames := runtime.CallersFrames(stack)
+	for {
+		frame, more := frames.Next()
+		b.WriteString(frame.Function)
+		b.WriteString("\n\t")
+		b.WriteString(frame.File)
+		b.WriteString(":")
+		b.WriteString(strconv.Itoa(frame.Line))
+		b.WriteString("\n")
+		if !more {
+			break
+		}
+	}
+	return b.String()
+}
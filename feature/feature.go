@@ -0,0 +1,178 @@
+package feature
+
+import (
+	"context"
+	"hash/fnv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"ginfra/config"
+	"ginfra/datasource"
+	"ginfra/log"
+	"ginfra/models"
+	"ginfra/protocol"
+	"ginfra/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+//Flag 功能开关
+// 开关关闭时对所有人关闭；开启时白名单用户/客户命中，其余按Percentage灰度
+type Flag struct {
+	Name       string
+	Enabled    bool
+	Users      []string
+	Customers  []string
+	Percentage int
+}
+
+var (
+	// 配置文件中的开关, map[string]*Flag
+	cfgFlags atomic.Value
+	// DB中的开关, map[string]*Flag
+	dbFlags atomic.Value
+)
+
+func init() {
+	cfgFlags.Store(map[string]*Flag{})
+	dbFlags.Store(map[string]*Flag{})
+}
+
+//Init 加载配置文件features.flags中的开关，并随配置热加载更新
+// features.dbrefresh大于0且DB已初始化时，定时从DB加载开关
+func Init(cfg *config.Config) error {
+	if err := loadConfigFlags(cfg); err != nil {
+		return err
+	}
+	cfg.OnChange(func() {
+		if err := loadConfigFlags(cfg); err != nil {
+			log.WithContext(context.Background()).Error("reload feature flags fail",
+				zap.String("error", err.Error()))
+		}
+	})
+
+	interval := cfg.GetDuration("features.dbrefresh")
+	if interval > 0 {
+		if err := loadDBFlags(); err != nil {
+			return err
+		}
+		go func() {
+			for range time.Tick(interval) {
+				if err := loadDBFlags(); err != nil {
+					log.WithContext(context.Background()).Error("refresh feature flags fail",
+						zap.String("error", err.Error()))
+				}
+			}
+		}()
+	}
+	return nil
+}
+
+func loadConfigFlags(cfg *config.Config) error {
+	var flags map[string]*Flag
+	if err := cfg.UnmarshalKey("features.flags", &flags); err != nil {
+		return err
+	}
+
+	m := make(map[string]*Flag, len(flags))
+	for name, f := range flags {
+		if f == nil {
+			continue
+		}
+		f.Name = name
+		m[name] = f
+	}
+	cfgFlags.Store(m)
+	return nil
+}
+
+func loadDBFlags() error {
+	db, err := datasource.Gormv2(context.Background())
+	if err != nil {
+		return err
+	}
+	rows, err := models.ListFeatureFlags(db)
+	if err != nil {
+		return err
+	}
+
+	m := make(map[string]*Flag, len(rows))
+	for _, r := range rows {
+		m[r.Name] = &Flag{
+			Name:       r.Name,
+			Enabled:    r.Enabled,
+			Users:      splitList(r.Users),
+			Customers:  splitList(r.Customers),
+			Percentage: r.Percentage,
+		}
+	}
+	dbFlags.Store(m)
+	return nil
+}
+
+func splitList(s string) []string {
+	list := make([]string, 0)
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if len(v) > 0 {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+//Lookup 查询开关，DB优先
+func Lookup(name string) (*Flag, bool) {
+	if f, ok := dbFlags.Load().(map[string]*Flag)[name]; ok {
+		return f, true
+	}
+	f, ok := cfgFlags.Load().(map[string]*Flag)[name]
+	return f, ok
+}
+
+//Enabled 判断开关对指定用户、客户是否开启，未定义的开关视为关闭
+func Enabled(name, uid, customerID string) bool {
+	f, ok := Lookup(name)
+	if !ok {
+		return false
+	}
+	return f.Evaluate(uid, customerID)
+}
+
+//EnabledFor 判断开关对当前请求的用户、客户是否开启
+func EnabledFor(c *gin.Context, name string) bool {
+	return Enabled(name, protocol.GetUserId(c), protocol.GetCustomerId(c))
+}
+
+//Evaluate 计算开关结果
+func (f *Flag) Evaluate(uid, customerID string) bool {
+	if !f.Enabled {
+		return false
+	}
+	if len(uid) > 0 && utils.StringInSlice(uid, f.Users) {
+		return true
+	}
+	if len(customerID) > 0 && utils.StringInSlice(customerID, f.Customers) {
+		return true
+	}
+	if f.Percentage >= 100 {
+		return true
+	}
+	if f.Percentage <= 0 {
+		return false
+	}
+
+	// 同一用户对同一开关的灰度结果保持稳定
+	subject := uid
+	if len(subject) == 0 {
+		subject = customerID
+	}
+	if len(subject) == 0 {
+		return false
+	}
+	h := fnv.New32a()
+	h.Write([]byte(f.Name + ":" + subject))
+	return int(h.Sum32()%100) < f.Percentage
+}
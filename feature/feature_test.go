@@ -0,0 +1,36 @@
+package feature
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func Test_FlagEvaluate(t *testing.T) {
+	convey.Convey("Flag.Evaluate", t, func() {
+		f := &Flag{Name: "discuz_token", Enabled: true, Users: []string{"100"}, Customers: []string{"c1"}}
+		convey.So(f.Evaluate("100", ""), convey.ShouldBeTrue)
+		convey.So(f.Evaluate("", "c1"), convey.ShouldBeTrue)
+		convey.So(f.Evaluate("101", "c2"), convey.ShouldBeFalse)
+
+		f.Enabled = false
+		convey.So(f.Evaluate("100", "c1"), convey.ShouldBeFalse)
+
+		f = &Flag{Name: "discuz_token", Enabled: true, Percentage: 30}
+		hits := 0
+		for i := 0; i < 10000; i++ {
+			uid := strconv.Itoa(i)
+			r := f.Evaluate(uid, "")
+			convey.So(f.Evaluate(uid, ""), convey.ShouldEqual, r)
+			if r {
+				hits++
+			}
+		}
+		convey.So(hits, convey.ShouldBeBetween, 2500, 3500)
+	})
+
+	convey.Convey("Enabled undefined flag", t, func() {
+		convey.So(Enabled("undefined", "100", "c1"), convey.ShouldBeFalse)
+	})
+}
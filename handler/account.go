@@ -0,0 +1,468 @@
+package handler
+
+import (
+	"context"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"ginfra/config"
+	"ginfra/datasource"
+	"ginfra/errcode"
+	"ginfra/log"
+	mw "ginfra/middleware"
+	"ginfra/models"
+	"ginfra/protocol"
+	"ginfra/sms"
+	"ginfra/utils"
+	"ginfra/validation"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+//AccountConfig 站内账号配置
+type AccountConfig struct {
+	BcryptCost        int           // bcrypt计算强度
+	MinPasswordLength int           // 密码最短长度
+	MaxFailures       int           // 连续密码错误达到该次数时锁定，0表示不锁定
+	LockDuration      time.Duration // 锁定时长
+	ResetExpires      time.Duration // 找回密码凭证有效期
+	ResetURL          string        // 找回密码页面地址，邮件中的链接为该地址加token参数
+	VerifyExpires     time.Duration // 邮箱验证链接有效期
+	VerifyURL         string        // 邮箱验证页面地址，邮件中的链接为该地址加token参数
+	UidNode           int64         // snowflake节点号，多实例部署时各实例需不同
+}
+
+var (
+	accountCfg = AccountConfig{
+		BcryptCost:        10,
+		MinPasswordLength: 8,
+		MaxFailures:       5,
+		LockDuration:      15 * time.Minute,
+		ResetExpires:      30 * time.Minute,
+		VerifyExpires:     24 * time.Hour,
+		UidNode:           1,
+	}
+	uidNode *snowflake.Node
+	// 账号不存在时也校验一次密码，避免通过响应时间判断账号是否存在
+	dummyPasswordHash string
+)
+
+//PasswordResetSender 发送找回密码凭证到邮箱身份，由邮件服务注册；未注册时找回密码不可用
+var PasswordResetSender func(ctx context.Context, auth *models.UserAuth, token string) error
+
+var usernameRegexp = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]{3,31}$`)
+
+func init() {
+	cfg, err := config.Parse("")
+	if err != nil {
+		panic(err)
+	}
+	if err := cfg.UnmarshalKey("account", &accountCfg); err != nil {
+		panic(err)
+	}
+
+	uidNode, err = utils.NewSnowFlakeNode(accountCfg.UidNode)
+	if err != nil {
+		panic(err)
+	}
+	dummyPasswordHash, err = utils.HashPassword("ginfra", accountCfg.BcryptCost)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// normalizeIdentifier 校验并规范化站内身份标识，邮箱统一小写
+func normalizeIdentifier(identityType int, identifier string) (string, bool) {
+	identifier = strings.TrimSpace(identifier)
+	switch identityType {
+	case models.IdentityTypeUsername:
+		return identifier, usernameRegexp.MatchString(identifier)
+	case models.IdentityTypeEmail:
+		addr, err := mail.ParseAddress(identifier)
+		if err != nil || addr.Address != identifier || len(identifier) > 128 {
+			return "", false
+		}
+		return strings.ToLower(identifier), true
+	case models.IdentityTypePhone:
+		return identifier, validation.IsPhone(identifier)
+	}
+	return "", false
+}
+
+func checkPasswordPolicy(password string) error {
+	if len(password) < accountCfg.MinPasswordLength || len(password) > utils.MaxPasswordLength {
+		return protocol.ErrCodeWeakPassword.WithParams(map[string]interface{}{
+			"min": accountCfg.MinPasswordLength,
+			"max": utils.MaxPasswordLength,
+		})
+	}
+	return nil
+}
+
+//RegisterRequest 注册请求参数
+type RegisterRequest struct {
+	IdentityType int    `binding:"required,oneof=1 2 3"` // 1用户名 2邮箱 3手机号
+	Identifier   string `binding:"required,max=128"`
+	Password     string `binding:"required"`
+	Code         string `binding:"required_if=IdentityType 3,max=8"` // 手机号注册时为bind用途的短信验证码
+}
+
+//Register 使用用户名、邮箱或手机号注册站内账号
+// 手机号需校验短信验证码，注册成功后直接登录；邮箱注册后发送验证邮件，验证后才能登录
+func Register(c *gin.Context) {
+	var req RegisterRequest
+	err := protocol.Bind(c, &req)
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.InvalidParameter(err))
+		return
+	}
+	identifier, ok := normalizeIdentifier(req.IdentityType, req.Identifier)
+	if !ok {
+		protocol.SetErrResponse(c, protocol.ErrCodeInvalidParameter)
+		return
+	}
+	if err := checkPasswordPolicy(req.Password); err != nil {
+		protocol.SetErrResponse(c, err)
+		return
+	}
+
+	if req.IdentityType == models.IdentityTypePhone && !verifySmsCode(c, identifier, sms.PurposeBind, req.Code) {
+		return
+	}
+
+	db, err := datasource.Gormv2(c.Request.Context())
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	_, err = models.GetUserAuthByIdentifier(db, req.IdentityType, identifier)
+	if err == nil {
+		protocol.SetErrResponse(c, protocol.ErrCodeAccountExists)
+		return
+	}
+	if err != gorm.ErrRecordNotFound {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+
+	certificate, err := utils.HashPassword(req.Password, accountCfg.BcryptCost)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", errcode.ErrCodeInternalError))
+		protocol.SetErrResponse(c, errcode.NewCustomError(errcode.ErrCodeInternalError, "注册失败"))
+		return
+	}
+	auth := &models.UserAuth{
+		Uid:          uint64(uidNode.Generate().Int64()),
+		IdentityType: req.IdentityType,
+		Identifier:   identifier,
+		Certificate:  certificate,
+	}
+	err = auth.Insert(db)
+	if err != nil {
+		// 并发注册同一账号时唯一索引冲突
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	log.WithGinContext(c).Info("account registered", zap.Uint64("Uid", auth.Uid), zap.Int("IdentityType", auth.IdentityType))
+	if auth.IdentityType == models.IdentityTypeEmail {
+		// 邮箱验证前不签发登录态
+		if err := sendVerifyEmail(c.Request.Context(), db, auth); err != nil {
+			log.WithGinContext(c).Error("send verify email fail", zap.Uint64("Uid", auth.Uid), zap.String("error", err.Error()))
+		}
+		protocol.SetResponse(c, struct{}{})
+		return
+	}
+
+	pair, err := issueTokenPair(c, db, auth)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", errcode.ErrCodeInternalError))
+		protocol.SetErrResponse(c, errcode.NewCustomError(errcode.ErrCodeInternalError, "生成登录态token失败"))
+		return
+	}
+	protocol.SetResponse(c, pair)
+}
+
+//LoginRequest 密码登录请求参数
+type LoginRequest struct {
+	IdentityType int    `binding:"required,oneof=1 2 3"` // 1用户名 2邮箱 3手机号
+	Identifier   string `binding:"required,max=128"`
+	Password     string `binding:"required,max=72"`
+}
+
+//Login 使用用户名、邮箱或手机号及密码登录，连续密码错误达到account.maxfailures次时锁定
+// 账号不存在与密码错误返回相同错误码；邮箱未验证时重新发送验证邮件并拒绝登录
+func Login(c *gin.Context) {
+	var req LoginRequest
+	err := protocol.Bind(c, &req)
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.InvalidParameter(err))
+		return
+	}
+	identifier, ok := normalizeIdentifier(req.IdentityType, req.Identifier)
+	if !ok {
+		protocol.SetErrResponse(c, protocol.ErrCodeInvalidCredentials)
+		return
+	}
+
+	db, err := datasource.Gormv2(c.Request.Context())
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	auth, err := models.GetUserAuthByIdentifier(db, req.IdentityType, identifier)
+	if err == gorm.ErrRecordNotFound {
+		utils.CheckPassword(dummyPasswordHash, req.Password)
+		mw.RecordCaptchaFailure(c)
+		protocol.SetErrResponse(c, protocol.ErrCodeInvalidCredentials)
+		return
+	}
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+
+	if err := verifyPassword(c, db, auth, req.Password); err != nil {
+		mw.RecordCaptchaFailure(c)
+		protocol.SetErrResponse(c, err)
+		return
+	}
+	if auth.IdentityType == models.IdentityTypeEmail && auth.VerifiedAt == nil {
+		if err := sendVerifyEmail(c.Request.Context(), db, auth); err != nil {
+			log.WithGinContext(c).Error("send verify email fail", zap.Uint64("Uid", auth.Uid), zap.String("error", err.Error()))
+		}
+		protocol.SetErrResponse(c, protocol.ErrCodeEmailNotVerified)
+		return
+	}
+
+	pair, err := issueTokenPair(c, db, auth)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", errcode.ErrCodeInternalError))
+		protocol.SetErrResponse(c, errcode.NewCustomError(errcode.ErrCodeInternalError, "生成登录态token失败"))
+		return
+	}
+	protocol.SetResponse(c, pair)
+}
+
+// verifyPassword 校验密码并维护错误次数，锁定期间不校验密码
+func verifyPassword(c *gin.Context, db *gorm.DB, auth *models.UserAuth, password string) error {
+	if auth.Locked(time.Now()) {
+		return protocol.ErrCodeAccountLocked
+	}
+	if len(auth.Certificate) > 0 && utils.CheckPassword(auth.Certificate, password) {
+		if err := auth.ResetLoginFailures(db); err != nil {
+			log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		}
+		return nil
+	}
+	if len(auth.Certificate) == 0 {
+		utils.CheckPassword(dummyPasswordHash, password)
+	}
+
+	locked, err := auth.RecordLoginFailure(db, accountCfg.MaxFailures, accountCfg.LockDuration)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		return protocol.ErrCodeDBException
+	}
+	if locked {
+		mw.Audit(c, mw.AuditEventAccountLocked, strconv.FormatUint(auth.Uid, 10))
+		return protocol.ErrCodeAccountLocked
+	}
+	return protocol.ErrCodeInvalidCredentials
+}
+
+//ChangePasswordRequest 修改密码请求参数
+type ChangePasswordRequest struct {
+	OldPassword string `binding:"required,max=72"`
+	NewPassword string `binding:"required"`
+}
+
+//ChangePassword 修改当前用户的密码，仅支持JWT鉴权；修改后全部登录态失效，需重新登录
+func ChangePassword(c *gin.Context) {
+	claims, ok := jwtClaims(c)
+	if !ok {
+		return
+	}
+	var req ChangePasswordRequest
+	err := protocol.Bind(c, &req)
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.InvalidParameter(err))
+		return
+	}
+	if err := checkPasswordPolicy(req.NewPassword); err != nil {
+		protocol.SetErrResponse(c, err)
+		return
+	}
+
+	db, err := datasource.Gormv2(c.Request.Context())
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	auth, err := models.GetPasswordUserAuthByUid(db, claims.Uid)
+	if err == gorm.ErrRecordNotFound {
+		protocol.SetErrResponse(c, protocol.ErrCodeInvalidCredentials)
+		return
+	}
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	if err := verifyPassword(c, db, auth, req.OldPassword); err != nil {
+		protocol.SetErrResponse(c, err)
+		return
+	}
+
+	if !setPassword(c, db, auth.Uid, req.NewPassword) {
+		return
+	}
+	mw.Audit(c, mw.AuditEventPasswordChanged, strconv.FormatUint(auth.Uid, 10))
+	revokeUserTokens(c, auth.Uid)
+}
+
+//ResetPasswordRequest 找回密码请求参数，仅支持邮箱，手机号账号可通过短信验证码登录
+type ResetPasswordRequest struct {
+	IdentityType int    `binding:"required,oneof=2"` // 2邮箱
+	Identifier   string `binding:"required,max=128"`
+}
+
+//ResetPassword 生成找回密码凭证并通过邮件发送
+// 账号是否存在均返回成功，避免通过该接口探测账号
+func ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	err := protocol.Bind(c, &req)
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.InvalidParameter(err))
+		return
+	}
+	if PasswordResetSender == nil {
+		protocol.SetErrResponse(c, protocol.ErrCodeServiceUnavailable)
+		return
+	}
+	identifier, ok := normalizeIdentifier(req.IdentityType, req.Identifier)
+	if !ok {
+		protocol.SetErrResponse(c, protocol.ErrCodeInvalidParameter)
+		return
+	}
+
+	db, err := datasource.Gormv2(c.Request.Context())
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	auth, err := models.GetUserAuthByIdentifier(db, req.IdentityType, identifier)
+	if err == gorm.ErrRecordNotFound {
+		protocol.SetResponse(c, struct{}{})
+		return
+	}
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+
+	token, err := utils.RandomString(40)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", errcode.ErrCodeInternalError))
+		protocol.SetErrResponse(c, errcode.NewCustomError(errcode.ErrCodeInternalError, "生成找回密码凭证失败"))
+		return
+	}
+	reset := &models.PasswordReset{
+		Uid:       auth.Uid,
+		TokenHash: utils.SHA256Hex(token),
+		ExpiresAt: time.Now().Add(accountCfg.ResetExpires),
+	}
+	err = reset.Insert(db)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	if err := PasswordResetSender(c.Request.Context(), auth, token); err != nil {
+		log.WithGinContext(c).Error("send password reset token fail",
+			zap.Uint64("Uid", auth.Uid), zap.String("error", err.Error()))
+	}
+	protocol.SetResponse(c, struct{}{})
+}
+
+//ConfirmResetPasswordRequest 使用找回密码凭证设置新密码的请求参数
+type ConfirmResetPasswordRequest struct {
+	Token       string `binding:"required,max=64"`
+	NewPassword string `binding:"required"`
+}
+
+//ConfirmResetPassword 使用找回密码凭证设置新密码，凭证仅能使用一次；成功后解除锁定，全部登录态失效
+func ConfirmResetPassword(c *gin.Context) {
+	var req ConfirmResetPasswordRequest
+	err := protocol.Bind(c, &req)
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.InvalidParameter(err))
+		return
+	}
+	if err := checkPasswordPolicy(req.NewPassword); err != nil {
+		protocol.SetErrResponse(c, err)
+		return
+	}
+
+	db, err := datasource.Gormv2(c.Request.Context())
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	reset, err := models.GetPasswordResetByHash(db, utils.SHA256Hex(req.Token))
+	if err == gorm.ErrRecordNotFound || (err == nil && (reset.UsedAt != nil || reset.ExpiresAt.Before(time.Now()))) {
+		protocol.SetErrResponse(c, protocol.ErrCodeInvalidResetToken)
+		return
+	}
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	ok, err := reset.Consume(db)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	if !ok {
+		// 并发使用，已被其他请求使用
+		protocol.SetErrResponse(c, protocol.ErrCodeInvalidResetToken)
+		return
+	}
+
+	if !setPassword(c, db, reset.Uid, req.NewPassword) {
+		return
+	}
+	log.WithGinContext(c).Info("password reset", zap.Uint64("Uid", reset.Uid))
+	revokeUserTokens(c, reset.Uid)
+}
+
+// setPassword 修改用户全部站内身份的密码，并使未使用的找回密码凭证失效；失败时设置错误响应
+func setPassword(c *gin.Context, db *gorm.DB, uid uint64, password string) bool {
+	certificate, err := utils.HashPassword(password, accountCfg.BcryptCost)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", errcode.ErrCodeInternalError))
+		protocol.SetErrResponse(c, errcode.NewCustomError(errcode.ErrCodeInternalError, "修改密码失败"))
+		return false
+	}
+	err = models.UpdatePasswordByUid(db, uid, certificate)
+	if err == nil {
+		err = models.DeletePasswordResetsByUid(db, uid)
+	}
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ginfra/log"
+	"ginfra/protocol"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smartystreets/goconvey/convey"
+	"go.uber.org/zap"
+)
+
+func Test_AccountValidation(t *testing.T) {
+	oldLogger := log.ZLog
+	defer func() { log.ZLog = oldLogger }()
+	log.ZLog = zap.NewNop()
+
+	r := gin.New()
+	r.POST("/Register", Register)
+	r.POST("/ResetPassword", ResetPassword)
+	do := func(path, body string) string {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+
+	convey.Convey("手机号注册需携带短信验证码", t, func() {
+		body := do("/Register", `{"IdentityType":3,"Identifier":"13800000000","Password":"password123"}`)
+		convey.So(body, convey.ShouldContainSubstring, protocol.ErrCodeInvalidParameter.Code)
+		convey.So(body, convey.ShouldContainSubstring, `"Field":"Code"`)
+
+		body = do("/Register", `{"IdentityType":3,"Identifier":"13800000000","Password":"password123","Code":"123456"}`)
+		convey.So(body, convey.ShouldNotContainSubstring, protocol.ErrCodeInvalidParameter.Code)
+	})
+
+	convey.Convey("找回密码仅支持邮箱", t, func() {
+		body := do("/ResetPassword", `{"IdentityType":3,"Identifier":"13800000000"}`)
+		convey.So(body, convey.ShouldContainSubstring, protocol.ErrCodeInvalidParameter.Code)
+		convey.So(body, convey.ShouldContainSubstring, `"Field":"IdentityType"`)
+	})
+}
@@ -0,0 +1,221 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"ginfra/datasource"
+	"ginfra/errcode"
+	"ginfra/log"
+	mw "ginfra/middleware"
+	"ginfra/models"
+	"ginfra/protocol"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+//HandleApiKey API密钥鉴权通过后，以密钥所属用户设置与JWT一致的登录态信息
+func HandleApiKey(c *gin.Context, key *models.ApiKey) error {
+	if key.Uid == 0 {
+		return errors.New("API密钥未绑定用户")
+	}
+
+	c.Set("claims", &ClaimData{
+		Uid:          key.Uid,
+		Identifier:   key.SecretId,
+		IdentityType: models.IdentityTypeApiKey,
+	})
+	c.Set(protocol.CtxUserID, strconv.FormatUint(key.Uid, 10))
+	return nil
+}
+
+//CreateApiKeyRequest 创建API密钥的请求参数，CustomerID需为当前请求所属客户，或在全局拥有apikey:write权限
+type CreateApiKeyRequest struct {
+	CustomerID string   `binding:"required,min=1"`
+	Name       string   `binding:"max=128"`
+	Scopes     []string `binding:"required,min=1"`
+	ExpiresIn  int64    `binding:"min=0"` // 有效期，单位秒，0表示永不过期
+}
+
+//CreateApiKeyResponse 创建API密钥的响应参数，SecretKey仅在此返回一次
+type CreateApiKeyResponse struct {
+	SecretId  string
+	SecretKey string
+	ExpiresAt *time.Time
+}
+
+//CreateApiKey 创建归属当前用户的API密钥，通过密钥的请求按该用户身份鉴权
+func CreateApiKey(c *gin.Context) {
+	claims, ok := jwtClaims(c)
+	if !ok {
+		return
+	}
+	var req CreateApiKeyRequest
+	err := protocol.Bind(c, &req)
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.InvalidParameter(err))
+		return
+	}
+	if !mw.AuthorizeCustomer(c, req.CustomerID, "apikey:write") {
+		return
+	}
+
+	secretId, secretKey, err := mw.GenerateApiKey()
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", errcode.ErrCodeInternalError))
+		protocol.SetErrResponse(c, errcode.NewCustomError(errcode.ErrCodeInternalError, "生成API密钥失败"))
+		return
+	}
+
+	key := &models.ApiKey{
+		SecretId:      secretId,
+		SecretKeyHash: mw.HashSecretKey(secretKey),
+		Uid:           claims.Uid,
+		CustomerID:    req.CustomerID,
+		Name:          req.Name,
+		Scopes:        strings.Join(req.Scopes, ","),
+	}
+	if req.ExpiresIn > 0 {
+		expiresAt := time.Now().Add(time.Duration(req.ExpiresIn) * time.Second)
+		key.ExpiresAt = &expiresAt
+	}
+
+	db, err := datasource.Gormv2(c.Request.Context())
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	err = key.Insert(db)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+
+	protocol.SetResponse(c, &CreateApiKeyResponse{
+		SecretId:  secretId,
+		SecretKey: secretKey,
+		ExpiresAt: key.ExpiresAt,
+	})
+}
+
+//DescribeApiKeysRequest 查询API密钥列表的请求参数，CustomerID为空时查询全部客户，需在全局拥有apikey:read权限
+type DescribeApiKeysRequest struct {
+	CustomerID string
+}
+
+//DescribeApiKeysResponse 查询API密钥列表的响应参数
+type DescribeApiKeysResponse struct {
+	ApiKeys []*models.ApiKey
+}
+
+//DescribeApiKeys 查询API密钥列表
+func DescribeApiKeys(c *gin.Context) {
+	var req DescribeApiKeysRequest
+	err := protocol.Bind(c, &req)
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.InvalidParameter(err))
+		return
+	}
+	if !mw.AuthorizeCustomer(c, req.CustomerID, "apikey:read") {
+		return
+	}
+
+	db, err := datasource.Gormv2(c.Request.Context())
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	keys, err := models.ListApiKeys(db, req.CustomerID)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+
+	protocol.SetResponse(c, &DescribeApiKeysResponse{ApiKeys: keys})
+}
+
+//ApiKeyRequest 操作单个API密钥的请求参数
+type ApiKeyRequest struct {
+	SecretId string `binding:"required,min=1"`
+}
+
+//DisableApiKey 禁用API密钥
+func DisableApiKey(c *gin.Context) {
+	key, db, ok := bindApiKey(c)
+	if !ok {
+		return
+	}
+
+	err := key.Disable(db)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+
+	protocol.SetResponse(c, struct{}{})
+}
+
+//RotateApiKeyResponse 轮换API密钥的响应参数
+type RotateApiKeyResponse struct {
+	SecretId  string
+	SecretKey string
+}
+
+//RotateApiKey 轮换API密钥，SecretId不变，旧SecretKey立即失效
+func RotateApiKey(c *gin.Context) {
+	key, db, ok := bindApiKey(c)
+	if !ok {
+		return
+	}
+
+	_, secretKey, err := mw.GenerateApiKey()
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", errcode.ErrCodeInternalError))
+		protocol.SetErrResponse(c, errcode.NewCustomError(errcode.ErrCodeInternalError, "生成API密钥失败"))
+		return
+	}
+
+	err = key.UpdateSecretKeyHash(db, mw.HashSecretKey(secretKey))
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+
+	protocol.SetResponse(c, &RotateApiKeyResponse{
+		SecretId:  key.SecretId,
+		SecretKey: secretKey,
+	})
+}
+
+func bindApiKey(c *gin.Context) (*models.ApiKey, *gorm.DB, bool) {
+	var req ApiKeyRequest
+	err := protocol.Bind(c, &req)
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.InvalidParameter(err))
+		return nil, nil, false
+	}
+
+	db, err := datasource.Gormv2(c.Request.Context())
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return nil, nil, false
+	}
+	key, err := models.GetApiKeyBySecretId(db, req.SecretId)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("SecretId", req.SecretId))
+		protocol.SetErrResponse(c, protocol.ErrCodeApiKeyNotFound)
+		return nil, nil, false
+	}
+	if !mw.AuthorizeCustomer(c, key.CustomerID, "apikey:write") {
+		return nil, nil, false
+	}
+	return key, db, true
+}
@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	mw "ginfra/middleware"
+	"ginfra/models"
+	"ginfra/protocol"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func Test_HandleApiKey(t *testing.T) {
+	convey.Convey("HandleApiKey", t, func() {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("POST", "/", nil)
+		key := &models.ApiKey{SecretId: "AKIDtest", SecretKeyHash: mw.HashSecretKey("secret"), CustomerID: "c1"}
+		convey.So(HandleApiKey(c, key), convey.ShouldNotBeNil)
+
+		key.Uid = 42
+		convey.So(HandleApiKey(c, key), convey.ShouldBeNil)
+		data, ok := c.Value("claims").(*ClaimData)
+		convey.So(ok, convey.ShouldBeTrue)
+		convey.So(data.Uid, convey.ShouldEqual, 42)
+		convey.So(data.Identifier, convey.ShouldEqual, "AKIDtest")
+		convey.So(data.IdentityType, convey.ShouldEqual, models.IdentityTypeApiKey)
+		convey.So(protocol.GetUserId(c), convey.ShouldEqual, "42")
+	})
+}
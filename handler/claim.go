@@ -8,6 +8,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 
 	"ginfra/log"
 	mw "ginfra/middleware"
@@ -21,10 +22,14 @@ type ClaimData struct {
 	Uid          uint64
 	Identifier   string
 	IdentityType int
+	Perms        []string `json:",omitempty"` // 全局角色权限，配置rbac.embedclaims时写入
+	CustomerID   string   `json:",omitempty"` // 登录态绑定的客户(租户)，用户只属于一个客户时签发
+	Tenants      []string `json:",omitempty"` // 用户所属的客户(租户)，只能通过这些客户的子域名访问
 }
 
-//generateToken 生成登录态token
-func generateToken(s *models.UserAuth) (string, error) {
+//generateToken 生成属于会话sid的登录态token，返回token及jti
+// 只由newTokenPair调用，登录接口需通过issueTokenPair签发token对并创建会话，不能直接返回该token
+func generateToken(db *gorm.DB, s *models.UserAuth, sid string) (string, string, error) {
 
 	// 构造SignKey: 签名和解签名需要使用一个值
 	// 构造用户claims信息(负荷)
@@ -33,13 +38,24 @@ func generateToken(s *models.UserAuth) (string, error) {
 		Identifier:   s.Identifier,
 		IdentityType: s.IdentityType,
 	}
-
-	// 根据claims生成token对象, expires = 0 表示将从配置文件中读取默认值
-	token, err := mw.GenerateToken(claimData, 0)
+	tenants, err := models.ListUserTenants(db, s.Uid)
 	if err != nil {
-		return "", err
+		return "", "", err
+	}
+	claimData.Tenants = tenants
+	if len(tenants) == 1 {
+		claimData.CustomerID = tenants[0]
+	}
+	if mw.RBACEmbedClaims {
+		perms, err := models.GetUserPermissions(db, s.Uid, "")
+		if err != nil {
+			return "", "", err
+		}
+		claimData.Perms = perms
 	}
-	return token, nil
+
+	// 根据claims生成短期的access token，过期后使用refresh token换取
+	return mw.GenerateSessionToken(claimData, mw.AccessExpires, sid)
 }
 
 func HandleClaims(c *gin.Context, claims *utils.CustomClaims) error {
@@ -57,6 +73,13 @@ func HandleClaims(c *gin.Context, claims *utils.CustomClaims) error {
 
 	c.Set("claims", data)
 	c.Set(protocol.CtxUserID, strconv.FormatUint(data.Uid, 10))
+	if data.Perms != nil {
+		c.Set(protocol.CtxPermissions, data.Perms)
+	}
+	if len(data.CustomerID) > 0 {
+		c.Set(protocol.CtxCustomerID, data.CustomerID)
+	}
+	c.Set(protocol.CtxTenants, data.Tenants)
 	return nil
 }
 
@@ -12,7 +12,6 @@ import (
 	"ginfra/utils"
 
 	"github.com/gin-gonic/gin"
-	"go.uber.org/zap"
 )
 
 //GetDiscuzTokenRequest 获取discuz token的请求参数
@@ -27,10 +26,9 @@ type GetDiscuzTokenResponse struct {
 //GetDiscuzToken 获取Discuz Token
 func GetDiscuzToken(c *gin.Context) {
 	var req GetDiscuzTokenRequest
-	err := c.ShouldBindJSON(&req)
+	err := protocol.Bind(c, &req)
 	if err != nil {
-		log.WithGinContext(c).Error(err.Error(), zap.String("error", errcode.ErrInvalidParam))
-		protocol.SetErrResponse(c, protocol.ErrCodeInvalidParameter)
+		protocol.SetErrResponse(c, protocol.InvalidParameter(err))
 		return
 	}
 
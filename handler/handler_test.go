@@ -12,6 +12,7 @@ import (
 	mw "ginfra/middleware"
 
 	. "github.com/agiledragon/gomonkey"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
 	"github.com/gavv/httpexpect"
 	"github.com/gin-gonic/gin"
 	"github.com/imroc/req"
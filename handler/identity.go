@@ -0,0 +1,322 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"ginfra/datasource"
+	"ginfra/errcode"
+	"ginfra/idp"
+	"ginfra/log"
+	mw "ginfra/middleware"
+	"ginfra/models"
+	"ginfra/protocol"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+//PhoneCodeVerifier 校验手机验证码，由短信服务注册；未注册时不能绑定手机号
+var PhoneCodeVerifier func(ctx context.Context, phone, code string) (bool, error)
+
+//Identity 用户绑定的身份
+type Identity struct {
+	IdentityType int
+	Identifier   string
+	CreatedAt    time.Time
+}
+
+//DescribeIdentitiesResponse 查询绑定身份的响应
+type DescribeIdentitiesResponse struct {
+	Identities []*Identity
+}
+
+//DescribeIdentities 查询当前用户绑定的全部身份，仅支持JWT鉴权
+func DescribeIdentities(c *gin.Context) {
+	claims, ok := jwtClaims(c)
+	if !ok {
+		return
+	}
+	db, err := datasource.Gormv2(c.Request.Context())
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	auths, err := models.ListUserAuthsByUid(db, claims.Uid)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+
+	resp := &DescribeIdentitiesResponse{Identities: make([]*Identity, 0, len(auths))}
+	for _, a := range auths {
+		resp.Identities = append(resp.Identities, &Identity{
+			IdentityType: a.IdentityType,
+			Identifier:   a.Identifier,
+			CreatedAt:    a.CreatedAt,
+		})
+	}
+	protocol.SetResponse(c, resp)
+}
+
+//LinkIdentityRequest 绑定第三方身份的请求参数，网页授权需先访问 /auth/{provider}/authorize
+type LinkIdentityRequest struct {
+	Provider string `binding:"required,max=32"`
+	Code     string `binding:"required,max=256"`
+	State    string `binding:"max=128"`
+}
+
+//LinkIdentity 为当前用户绑定第三方身份，仅支持JWT鉴权
+func LinkIdentity(c *gin.Context) {
+	claims, ok := jwtClaims(c)
+	if !ok {
+		return
+	}
+	var req LinkIdentityRequest
+	err := protocol.Bind(c, &req)
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.InvalidParameter(err))
+		return
+	}
+	p, ok := idp.Get(req.Provider)
+	if !ok {
+		protocol.SetErrResponse(c, protocol.ErrCodeProviderNotFound)
+		return
+	}
+	profile, ok := exchangeProfile(c, p, req.Code, req.State)
+	if !ok {
+		return
+	}
+
+	linkIdentity(c, &models.UserAuth{
+		Uid:          claims.Uid,
+		IdentityType: p.IdentityType(),
+		Identifier:   profile.Openid,
+		Openid:       profile.Openid,
+	})
+}
+
+//LinkPhoneRequest 绑定手机号的请求参数
+type LinkPhoneRequest struct {
+	Phone string `binding:"required,phone"`
+	Code  string `binding:"required,max=16"` // 短信验证码
+}
+
+//LinkPhone 为当前用户绑定手机号，绑定后可使用手机号及原密码登录，仅支持JWT鉴权
+func LinkPhone(c *gin.Context) {
+	claims, ok := jwtClaims(c)
+	if !ok {
+		return
+	}
+	var req LinkPhoneRequest
+	err := protocol.Bind(c, &req)
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.InvalidParameter(err))
+		return
+	}
+	if PhoneCodeVerifier == nil {
+		protocol.SetErrResponse(c, protocol.ErrCodeServiceUnavailable)
+		return
+	}
+	phone, ok := normalizeIdentifier(models.IdentityTypePhone, req.Phone)
+	if !ok {
+		protocol.SetErrResponse(c, protocol.ErrCodeInvalidParameter)
+		return
+	}
+	ok, err = PhoneCodeVerifier(c.Request.Context(), phone, req.Code)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", errcode.ErrCodeInternalError))
+		protocol.SetErrResponse(c, errcode.NewCustomError(errcode.ErrCodeInternalError, "校验验证码失败"))
+		return
+	}
+	if !ok {
+		protocol.SetErrResponse(c, protocol.ErrCodeInvalidVerifyCode)
+		return
+	}
+
+	auth := &models.UserAuth{
+		Uid:          claims.Uid,
+		IdentityType: models.IdentityTypePhone,
+		Identifier:   phone,
+	}
+	// 站内身份共用密码
+	db, err := datasource.Gormv2(c.Request.Context())
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	if pwd, err := models.GetPasswordUserAuthByUid(db, claims.Uid); err == nil {
+		auth.Certificate = pwd.Certificate
+	} else if err != gorm.ErrRecordNotFound {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	linkIdentity(c, auth)
+}
+
+// linkIdentity 绑定身份，身份已属于其他用户或当前用户已绑定同类型身份时报错
+func linkIdentity(c *gin.Context, auth *models.UserAuth) {
+	db, err := datasource.Gormv2(c.Request.Context())
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	existing, err := models.GetUserAuthByIdentifier(db, auth.IdentityType, auth.Identifier)
+	if err == nil {
+		if existing.Uid == auth.Uid {
+			protocol.SetResponse(c, struct{}{})
+			return
+		}
+		// 需由管理员合并账号
+		protocol.SetErrResponse(c, protocol.ErrCodeIdentityLinked)
+		return
+	}
+	if err != gorm.ErrRecordNotFound {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+
+	auths, err := models.ListUserAuthsByUid(db, auth.Uid)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	for _, a := range auths {
+		if a.IdentityType == auth.IdentityType {
+			protocol.SetErrResponse(c, protocol.ErrCodeIdentityTypeLinked)
+			return
+		}
+	}
+
+	err = auth.Insert(db)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	mw.Audit(c, mw.AuditEventIdentityLinked, fmt.Sprintf("type %d: %s", auth.IdentityType, auth.Identifier))
+	protocol.SetResponse(c, struct{}{})
+}
+
+//UnlinkIdentityRequest 解绑身份的请求参数
+type UnlinkIdentityRequest struct {
+	IdentityType int `binding:"required,min=1"`
+}
+
+//UnlinkIdentity 解绑当前用户的身份，不能解绑最后一个身份，仅支持JWT鉴权
+func UnlinkIdentity(c *gin.Context) {
+	claims, ok := jwtClaims(c)
+	if !ok {
+		return
+	}
+	var req UnlinkIdentityRequest
+	err := protocol.Bind(c, &req)
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.InvalidParameter(err))
+		return
+	}
+
+	db, err := datasource.Gormv2(c.Request.Context())
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	auths, err := models.ListUserAuthsByUid(db, claims.Uid)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	var auth *models.UserAuth
+	for _, a := range auths {
+		if a.IdentityType == req.IdentityType {
+			auth = a
+		}
+	}
+	if auth == nil {
+		protocol.SetErrResponse(c, protocol.ErrCodeIdentityNotFound)
+		return
+	}
+
+	deleted, err := auth.Unlink(db)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	if !deleted {
+		protocol.SetErrResponse(c, protocol.ErrCodeLastIdentity)
+		return
+	}
+	mw.Audit(c, mw.AuditEventIdentityUnlinked, fmt.Sprintf("type %d: %s", auth.IdentityType, auth.Identifier))
+	protocol.SetResponse(c, struct{}{})
+}
+
+//MergeAccountsRequest 管理员合并账号的请求参数
+type MergeAccountsRequest struct {
+	SourceUid    uint64 `binding:"required,min=1"` // 合并后删除的账号
+	TargetUid    uint64 `binding:"required,min=1,nefield=SourceUid"`
+	PreferSource bool   // 同类型身份冲突时保留源账号的身份，默认保留目标账号的身份
+}
+
+//MergeAccounts 管理员将源账号的身份及角色合并到目标账号，冲突处理记录到审计日志；源账号的登录态失效
+func MergeAccounts(c *gin.Context) {
+	var req MergeAccountsRequest
+	err := protocol.Bind(c, &req)
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.InvalidParameter(err))
+		return
+	}
+
+	db, err := datasource.Gormv2(c.Request.Context())
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	result, err := models.MergeAccounts(db, req.SourceUid, req.TargetUid, req.PreferSource)
+	if err == gorm.ErrRecordNotFound {
+		protocol.SetErrResponse(c, protocol.ErrCodeIdentityNotFound)
+		return
+	}
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+
+	detail, _ := json.Marshal(struct {
+		SourceUid uint64
+		TargetUid uint64
+		*models.MergeResult
+	}{req.SourceUid, req.TargetUid, result})
+	mw.Audit(c, mw.AuditEventAccountsMerged, string(detail))
+	mw.InvalidatePermissions(req.TargetUid)
+
+	err = mw.RevokeUserTokens(c.Request.Context(), strconv.FormatUint(req.SourceUid, 10))
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", errcode.ErrCodeInternalError))
+	}
+	protocol.SetResponse(c, result)
+}
+
+// jwtClaims 获取JWT登录态，非JWT鉴权时设置错误响应
+func jwtClaims(c *gin.Context) (*ClaimData, bool) {
+	if protocol.GetAuthType(c) != protocol.AuthTypeJWT {
+		protocol.SetErrResponse(c, protocol.ErrCodeUnAuthorized)
+		return nil, false
+	}
+	claims, err := getClaimData(c)
+	if err != nil {
+		protocol.SetErrResponse(c, err)
+		return nil, false
+	}
+	return claims, true
+}
@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ginfra/datasource"
+	"ginfra/log"
+	"ginfra/models"
+	"ginfra/protocol"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smartystreets/goconvey/convey"
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newMigratedDB 创建按models.AutoMigrate建表的内存数据库并设置为默认实例，返回清理函数
+func newMigratedDB(t *testing.T) (*gorm.DB, func()) {
+	// 每个测试独立的共享缓存内存库，连接池中的连接访问同一个库
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{
+		Logger:         logger.Discard,
+		NamingStrategy: datasource.NamingStrategy,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := models.AutoMigrate(db); err != nil {
+		t.Fatal(err)
+	}
+	datasource.SetGormDBv2(db)
+	return db, func() {
+		datasource.SetGormDBv2(nil)
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	}
+}
+
+func Test_MergeAccounts(t *testing.T) {
+	oldLogger := log.ZLog
+	defer func() { log.ZLog = oldLogger }()
+	log.ZLog = zap.NewNop()
+	db, closeDB := newMigratedDB(t)
+	defer closeDB()
+
+	auths := []*models.UserAuth{
+		{Uid: 1, IdentityType: models.IdentityTypeUsername, Identifier: "source"},
+		{Uid: 1, IdentityType: models.IdentityTypePhone, Identifier: "13800000000"},
+		{Uid: 2, IdentityType: models.IdentityTypeUsername, Identifier: "target"},
+	}
+	for _, a := range auths {
+		if err := db.Create(a).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	session := &models.UserSession{SessionID: "s1", Uid: 1, RefreshFamily: "f1", ExpiresAt: time.Now().Add(time.Hour)}
+	if err := db.Create(session).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&models.UserRole{Uid: 1, RoleID: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.POST("/MergeAccounts", MergeAccounts)
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/MergeAccounts", bytes.NewBufferString(`{"SourceUid":1,"TargetUid":2}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+
+	convey.Convey("合并账号", t, func() {
+		convey.So(w.Body.String(), convey.ShouldNotContainSubstring, protocol.ErrCodeDBException.Code)
+		convey.So(w.Body.String(), convey.ShouldContainSubstring, "13800000000")
+
+		targets, err := models.ListUserAuthsByUid(db, 2)
+		convey.So(err, convey.ShouldBeNil)
+		identifiers := make([]string, 0, len(targets))
+		for _, a := range targets {
+			identifiers = append(identifiers, a.Identifier)
+		}
+		convey.So(identifiers, convey.ShouldContain, "13800000000")
+		convey.So(identifiers, convey.ShouldContain, "target")
+		convey.So(identifiers, convey.ShouldNotContain, "source")
+
+		sources, err := models.ListUserAuthsByUid(db, 1)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(sources, convey.ShouldBeEmpty)
+
+		convey.So(db.First(session, session.ID).Error, convey.ShouldBeNil)
+		convey.So(session.RevokedAt, convey.ShouldNotBeNil)
+
+		var roles int64
+		convey.So(db.Model(&models.UserRole{}).Where("uid = ?", 2).Count(&roles).Error, convey.ShouldBeNil)
+		convey.So(roles, convey.ShouldEqual, 1)
+	})
+}
@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+
+	"ginfra/datasource"
+	"ginfra/errcode"
+	"ginfra/idp"
+	"ginfra/log"
+	"ginfra/models"
+	"ginfra/protocol"
+	"ginfra/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 网页授权state的Cookie，回调时校验防止CSRF
+const (
+	oauthStateCookie = "oauth_state"
+	oauthStateMaxAge = 600
+)
+
+//ProviderCallbackRequest 第三方登录回调参数，网页授权通过query传递，小程序通过JSON传递
+type ProviderCallbackRequest struct {
+	Code  string `form:"code" json:"Code" binding:"required,max=256"`
+	State string `form:"state" json:"State" binding:"max=128"`
+}
+
+//AuthorizeProvider 跳转到第三方平台的网页授权页面
+func AuthorizeProvider(c *gin.Context) {
+	p, ok := idp.Get(c.Param("provider"))
+	if !ok {
+		protocol.SetErrResponse(c, protocol.ErrCodeProviderNotFound)
+		return
+	}
+	r, ok := p.(idp.Redirector)
+	if !ok {
+		protocol.SetErrResponse(c, protocol.ErrCodeProviderNotFound)
+		return
+	}
+
+	state, err := utils.RandomString(32)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", errcode.ErrCodeInternalError))
+		protocol.SetErrResponse(c, errcode.NewCustomError(errcode.ErrCodeInternalError, "生成授权state失败"))
+		return
+	}
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oauthStateCookie, state, oauthStateMaxAge, "/", "", true, true)
+	c.Redirect(http.StatusFound, r.AuthURL(state))
+}
+
+//ProviderCallback 第三方登录回调，使用code换取用户信息，按(IdentityType, Openid)查找或创建用户并签发登录态
+func ProviderCallback(c *gin.Context) {
+	p, ok := idp.Get(c.Param("provider"))
+	if !ok {
+		protocol.SetErrResponse(c, protocol.ErrCodeProviderNotFound)
+		return
+	}
+	var req ProviderCallbackRequest
+	var err error
+	if c.Request.Method == http.MethodGet {
+		err = c.ShouldBindQuery(&req)
+	} else {
+		err = protocol.Bind(c, &req)
+	}
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.InvalidParameter(err))
+		return
+	}
+
+	profile, ok := exchangeProfile(c, p, req.Code, req.State)
+	if !ok {
+		return
+	}
+
+	db, err := datasource.Gormv2(c.Request.Context())
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	auth, err := models.GetUserAuthByIdentifier(db, p.IdentityType(), profile.Openid)
+	if err == gorm.ErrRecordNotFound {
+		auth = &models.UserAuth{
+			Uid:          uint64(uidNode.Generate().Int64()),
+			IdentityType: p.IdentityType(),
+			Identifier:   profile.Openid,
+			Openid:       profile.Openid,
+		}
+		err = auth.Insert(db)
+		if err == nil {
+			log.WithGinContext(c).Info("account registered", zap.Uint64("Uid", auth.Uid),
+				zap.String("provider", p.Name()))
+		}
+	}
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+
+	pair, err := issueTokenPair(c, db, auth)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", errcode.ErrCodeInternalError))
+		protocol.SetErrResponse(c, errcode.NewCustomError(errcode.ErrCodeInternalError, "生成登录态token失败"))
+		return
+	}
+	protocol.SetResponse(c, pair)
+}
+
+// exchangeProfile 网页授权校验state后使用code换取第三方用户信息，失败时设置错误响应
+func exchangeProfile(c *gin.Context, p idp.IdentityProvider, code, state string) (*idp.Profile, bool) {
+	// 网页授权校验state与跳转时写入Cookie的一致
+	if _, ok := p.(idp.Redirector); ok {
+		expected, _ := c.Cookie(oauthStateCookie)
+		c.SetCookie(oauthStateCookie, "", -1, "/", "", true, true)
+		if len(expected) == 0 || subtle.ConstantTimeCompare([]byte(expected), []byte(state)) != 1 {
+			protocol.SetErrResponse(c, protocol.ErrCodeInvalidOAuthState)
+			return nil, false
+		}
+	}
+
+	token, err := p.Exchange(c.Request.Context(), code)
+	if errors.Is(err, idp.ErrInvalidCode) {
+		log.WithGinContext(c).Info(err.Error(), zap.String("provider", p.Name()))
+		protocol.SetErrResponse(c, protocol.ErrCodeInvalidAuthCode)
+		return nil, false
+	}
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("provider", p.Name()),
+			zap.String("error", errcode.ErrCodeInternalError))
+		protocol.SetErrResponse(c, errcode.NewCustomError(errcode.ErrCodeInternalError, "第三方登录失败，请重试"))
+		return nil, false
+	}
+	profile, err := p.Profile(c.Request.Context(), token)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("provider", p.Name()),
+			zap.String("error", errcode.ErrCodeInternalError))
+		protocol.SetErrResponse(c, errcode.NewCustomError(errcode.ErrCodeInternalError, "获取第三方用户信息失败，请重试"))
+		return nil, false
+	}
+	if len(profile.Openid) == 0 {
+		protocol.SetErrResponse(c, protocol.ErrCodeInvalidAuthCode)
+		return nil, false
+	}
+	return profile, true
+}
@@ -0,0 +1,15 @@
+package handler
+
+import (
+	"net/http"
+
+	mw "ginfra/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+//JWKS 发布JWT校验公钥，下游服务按token header中的kid选择公钥
+func JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, mw.Keys().JWKS())
+}
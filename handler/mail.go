@@ -0,0 +1,170 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"ginfra/datasource"
+	"ginfra/errcode"
+	"ginfra/log"
+	"ginfra/mail"
+	"ginfra/models"
+	"ginfra/protocol"
+	"ginfra/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+func init() {
+	PasswordResetSender = sendPasswordResetMail
+}
+
+// sendPasswordResetMail 向邮箱身份发送找回密码邮件
+func sendPasswordResetMail(ctx context.Context, auth *models.UserAuth, token string) error {
+	if auth.IdentityType != models.IdentityTypeEmail {
+		return fmt.Errorf("identity type %d has no password reset channel", auth.IdentityType)
+	}
+	return mail.SendTemplate(ctx, []string{auth.Identifier}, mail.TemplateResetPassword, &mail.ResetPasswordData{
+		Identifier: auth.Identifier,
+		Link:       tokenLink(accountCfg.ResetURL, token),
+		Expires:    formatExpires(accountCfg.ResetExpires),
+	})
+}
+
+// sendVerifyEmail 生成邮箱验证凭证并发送验证邮件
+func sendVerifyEmail(ctx context.Context, db *gorm.DB, auth *models.UserAuth) error {
+	token, err := utils.RandomString(40)
+	if err != nil {
+		return err
+	}
+	v := &models.EmailVerification{
+		Uid:       auth.Uid,
+		Email:     auth.Identifier,
+		TokenHash: utils.SHA256Hex(token),
+		ExpiresAt: time.Now().Add(accountCfg.VerifyExpires),
+	}
+	if err := v.Insert(db); err != nil {
+		return err
+	}
+	return mail.SendTemplate(ctx, []string{auth.Identifier}, mail.TemplateVerifyEmail, &mail.VerifyEmailData{
+		Email:   auth.Identifier,
+		Link:    tokenLink(accountCfg.VerifyURL, token),
+		Expires: formatExpires(accountCfg.VerifyExpires),
+	})
+}
+
+// tokenLink 在页面地址上追加token参数
+func tokenLink(page, token string) string {
+	u, err := url.Parse(page)
+	if err != nil {
+		return page
+	}
+	q := u.Query()
+	q.Set("token", token)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// formatExpires 邮件中展示的有效期
+func formatExpires(d time.Duration) string {
+	if d >= time.Hour && d%time.Hour == 0 {
+		return fmt.Sprintf("%d小时", d/time.Hour)
+	}
+	return fmt.Sprintf("%d分钟", d/time.Minute)
+}
+
+//SendVerifyEmail 向当前用户绑定的邮箱发送验证邮件，已验证时直接返回成功，仅支持JWT鉴权
+func SendVerifyEmail(c *gin.Context) {
+	claims, ok := jwtClaims(c)
+	if !ok {
+		return
+	}
+	db, err := datasource.Gormv2(c.Request.Context())
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	auth, err := models.GetUserAuthByUidAndType(db, claims.Uid, models.IdentityTypeEmail)
+	if err == gorm.ErrRecordNotFound {
+		protocol.SetErrResponse(c, protocol.ErrCodeEmailNotLinked)
+		return
+	}
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	if auth.VerifiedAt != nil {
+		protocol.SetResponse(c, struct{}{})
+		return
+	}
+
+	if err := sendVerifyEmail(c.Request.Context(), db, auth); err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", errcode.ErrCodeInternalError))
+		protocol.SetErrResponse(c, errcode.NewCustomError(errcode.ErrCodeInternalError, "发送验证邮件失败"))
+		return
+	}
+	protocol.SetResponse(c, struct{}{})
+}
+
+//ConfirmEmailRequest 邮箱验证的请求参数
+type ConfirmEmailRequest struct {
+	Token string `binding:"required,max=64"`
+}
+
+//ConfirmEmail 使用验证邮件中的凭证完成邮箱验证，凭证仅能使用一次；邮箱已解绑时凭证无效
+func ConfirmEmail(c *gin.Context) {
+	var req ConfirmEmailRequest
+	err := protocol.Bind(c, &req)
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.InvalidParameter(err))
+		return
+	}
+
+	db, err := datasource.Gormv2(c.Request.Context())
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	v, err := models.GetEmailVerificationByHash(db, utils.SHA256Hex(req.Token))
+	if err == gorm.ErrRecordNotFound || (err == nil && (v.UsedAt != nil || v.ExpiresAt.Before(time.Now()))) {
+		protocol.SetErrResponse(c, protocol.ErrCodeInvalidVerifyToken)
+		return
+	}
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	auth, err := models.GetUserAuthByIdentifier(db, models.IdentityTypeEmail, v.Email)
+	if err == gorm.ErrRecordNotFound || (err == nil && auth.Uid != v.Uid) {
+		protocol.SetErrResponse(c, protocol.ErrCodeInvalidVerifyToken)
+		return
+	}
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+
+	ok, err := v.Consume(db)
+	if err == nil && ok {
+		err = auth.MarkVerified(db)
+	}
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	if !ok {
+		// 并发使用，已被其他请求使用
+		protocol.SetErrResponse(c, protocol.ErrCodeInvalidVerifyToken)
+		return
+	}
+	log.WithGinContext(c).Info("email verified", zap.Uint64("Uid", auth.Uid))
+	protocol.SetResponse(c, struct{}{})
+}
@@ -0,0 +1,477 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"ginfra/config"
+	"ginfra/datasource"
+	"ginfra/errcode"
+	"ginfra/log"
+	mw "ginfra/middleware"
+	"ginfra/models"
+	"ginfra/protocol"
+	"ginfra/sms"
+	"ginfra/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+//MFAConfig 二次验证配置
+type MFAConfig struct {
+	Issuer        string // 身份验证器中显示的发行方
+	EncryptionKey string // 加密保存TOTP密钥的AES-256密钥，hex编码；为空时不能绑定TOTP
+	Skew          int    // 校验时允许前后偏差的时间步数
+	RecoveryCodes int    // 生成的恢复码数量
+}
+
+var (
+	mfaCfg = MFAConfig{
+		Issuer:        "ginfra",
+		Skew:          1,
+		RecoveryCodes: 10,
+	}
+	mfaKey []byte
+)
+
+var errMFANotConfigured = errors.New("mfa.encryptionkey not configured")
+
+func init() {
+	cfg, err := config.Parse("")
+	if err != nil {
+		panic(err)
+	}
+	if err := cfg.UnmarshalKey("mfa", &mfaCfg); err != nil {
+		panic(err)
+	}
+	if len(mfaCfg.EncryptionKey) > 0 {
+		mfaKey, err = hex.DecodeString(mfaCfg.EncryptionKey)
+		if err != nil || len(mfaKey) != 32 {
+			panic("mfa.encryptionkey must be 32 bytes hex encoded")
+		}
+	}
+}
+
+// encryptTOTPSecret 加密TOTP密钥，返回base64编码的密文
+func encryptTOTPSecret(secret string) (string, error) {
+	if mfaKey == nil {
+		return "", errMFANotConfigured
+	}
+	b, err := utils.AesGCMEncrypt([]byte(secret), mfaKey)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// decryptTOTPSecret 解密TOTP密钥
+func decryptTOTPSecret(encrypted string) (string, error) {
+	if mfaKey == nil {
+		return "", errMFANotConfigured
+	}
+	b, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", err
+	}
+	secret, err := utils.AesGCMDecrypt(b, mfaKey)
+	if err != nil {
+		return "", err
+	}
+	return string(secret), nil
+}
+
+// newRecoveryCodes 生成恢复码并替换之前的恢复码，明文仅在生成时返回一次
+func newRecoveryCodes(db *gorm.DB, uid uint64) ([]string, error) {
+	codes := make([]string, mfaCfg.RecoveryCodes)
+	hashes := make([]string, mfaCfg.RecoveryCodes)
+	b := make([]byte, 10)
+	for i := range codes {
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		s := base32.StdEncoding.EncodeToString(b)
+		codes[i] = s[0:4] + "-" + s[4:8] + "-" + s[8:12] + "-" + s[12:16]
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+	if err := models.ReplaceRecoveryCodes(db, uid, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// hashRecoveryCode 恢复码哈希，忽略大小写及分隔符
+func hashRecoveryCode(code string) string {
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+	return utils.SHA256Hex(strings.ToUpper(code))
+}
+
+// enabledTOTP 查询用户已启用的TOTP，失败时设置错误响应
+func enabledTOTP(c *gin.Context, db *gorm.DB, uid uint64) (*models.UserTOTP, bool) {
+	t, err := models.GetUserTOTP(db, uid)
+	if err == gorm.ErrRecordNotFound || (err == nil && !t.Enabled()) {
+		protocol.SetErrResponse(c, protocol.ErrCodeMFANotEnabled)
+		return nil, false
+	}
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return nil, false
+	}
+	return t, true
+}
+
+// checkTOTPCode 校验TOTP验证码，返回匹配的时间步，失败时设置错误响应
+func checkTOTPCode(c *gin.Context, t *models.UserTOTP, code string) (int64, bool) {
+	secret, err := decryptTOTPSecret(t.Secret)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", errcode.ErrCodeInternalError))
+		protocol.SetErrResponse(c, errcode.NewCustomError(errcode.ErrCodeInternalError, "校验动态验证码失败"))
+		return 0, false
+	}
+	counter, ok := utils.ValidateTOTP(secret, code, time.Now(), mfaCfg.Skew)
+	if !ok || counter <= t.LastCounter {
+		mw.RecordCaptchaFailure(c)
+		protocol.SetErrResponse(c, protocol.ErrCodeInvalidMFACode)
+		return 0, false
+	}
+	return counter, true
+}
+
+//MFATokenResponse 通过二次验证后签发的access token，refresh token不变
+type MFATokenResponse struct {
+	AccessToken string
+	ExpiresIn   int64 // AccessToken有效期，单位秒
+}
+
+// mfaToken 签发通过二次验证的access token，沿用当前登录态的claims、登录时间及会话
+func mfaToken(c *gin.Context, claims *ClaimData) (*MFATokenResponse, bool) {
+	token, err := mw.GenerateMFAToken(claims, mw.AccessExpires, c.GetTime(protocol.CtxAuthTime),
+		c.GetString(protocol.CtxSessionID), mw.MFALevelTOTP)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", errcode.ErrCodeInternalError))
+		protocol.SetErrResponse(c, errcode.NewCustomError(errcode.ErrCodeInternalError, "生成登录态token失败"))
+		return nil, false
+	}
+	return &MFATokenResponse{AccessToken: token, ExpiresIn: mw.AccessExpires}, true
+}
+
+//DescribeMFAResponse 二次验证状态
+type DescribeMFAResponse struct {
+	TOTPEnabled   bool
+	RecoveryCodes int64 // 未使用的恢复码数量
+	Level         int   // 当前登录态的二次验证级别
+}
+
+//DescribeMFA 查询当前用户的二次验证状态，仅支持JWT鉴权
+func DescribeMFA(c *gin.Context) {
+	claims, ok := jwtClaims(c)
+	if !ok {
+		return
+	}
+	db, err := datasource.Gormv2(c.Request.Context())
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	resp := &DescribeMFAResponse{Level: c.GetInt(protocol.CtxMFALevel)}
+	t, err := models.GetUserTOTP(db, claims.Uid)
+	if err == nil {
+		resp.TOTPEnabled = t.Enabled()
+		resp.RecoveryCodes, err = models.CountRecoveryCodes(db, claims.Uid)
+	}
+	if err != nil && err != gorm.ErrRecordNotFound {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	protocol.SetResponse(c, resp)
+}
+
+//EnrollTOTPResponse 绑定TOTP的返回
+type EnrollTOTPResponse struct {
+	Secret string // base32编码的密钥，无法扫码时手动输入
+	URI    string // otpauth URI，生成二维码供身份验证器扫码添加
+}
+
+//EnrollTOTPRequest 绑定TOTP的请求参数，设置了密码的用户需重新输入密码，否则需通过X-Sms-Code携带短信验证码
+type EnrollTOTPRequest struct {
+	Password string `binding:"max=72"`
+}
+
+//EnrollTOTP 重新验证身份后生成TOTP密钥，通过ConfirmTOTP校验验证码后启用；未启用前重复调用会生成新的密钥，仅支持JWT鉴权
+// 只有通过重新验证才能拿到密钥，因此ConfirmTOTP不再单独验证身份
+func EnrollTOTP(c *gin.Context) {
+	var req EnrollTOTPRequest
+	err := protocol.Bind(c, &req)
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.InvalidParameter(err))
+		return
+	}
+	claims, ok := jwtClaims(c)
+	if !ok {
+		return
+	}
+	db, err := datasource.Gormv2(c.Request.Context())
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	if !reauthenticate(c, db, claims.Uid, req.Password) {
+		return
+	}
+	t, err := models.GetUserTOTP(db, claims.Uid)
+	if err == gorm.ErrRecordNotFound {
+		t, err = &models.UserTOTP{Uid: claims.Uid}, nil
+	}
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	if t.Enabled() {
+		protocol.SetErrResponse(c, protocol.ErrCodeMFAAlreadyEnabled)
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err == nil {
+		var encrypted string
+		encrypted, err = encryptTOTPSecret(secret)
+		if err == nil {
+			ok, err = t.SaveSecret(db, encrypted)
+		}
+	}
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", errcode.ErrCodeInternalError))
+		protocol.SetErrResponse(c, errcode.NewCustomError(errcode.ErrCodeInternalError, "生成TOTP密钥失败"))
+		return
+	}
+	if !ok {
+		// 并发确认，已被其他请求启用
+		protocol.SetErrResponse(c, protocol.ErrCodeMFAAlreadyEnabled)
+		return
+	}
+	protocol.SetResponse(c, &EnrollTOTPResponse{
+		Secret: secret,
+		URI:    utils.TOTPURI(mfaCfg.Issuer, claims.Identifier, secret),
+	})
+}
+
+// reauthenticate 重新验证身份：设置了密码的用户校验密码，否则校验发送到绑定手机号的短信验证码；失败时设置错误响应
+func reauthenticate(c *gin.Context, db *gorm.DB, uid uint64, password string) bool {
+	auth, err := models.GetPasswordUserAuthByUid(db, uid)
+	if err == gorm.ErrRecordNotFound {
+		phone, ok := linkedPhone(c, uid)
+		return ok && verifySmsCode(c, phone, sms.PurposeVerify, c.GetHeader(mw.HeaderSmsCode))
+	}
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return false
+	}
+	if len(password) == 0 {
+		// 未携带密码不计入错误次数
+		protocol.SetErrResponse(c, protocol.ErrCodeInvalidCredentials)
+		return false
+	}
+	if err := verifyPassword(c, db, auth, password); err != nil {
+		protocol.SetErrResponse(c, err)
+		return false
+	}
+	return true
+}
+
+//ConfirmTOTPRequest 确认启用TOTP的请求参数
+type ConfirmTOTPRequest struct {
+	Code string `binding:"required,len=6,numeric"`
+}
+
+//ConfirmTOTPResponse 启用TOTP的返回，恢复码仅返回一次
+type ConfirmTOTPResponse struct {
+	MFATokenResponse
+	RecoveryCodes []string
+}
+
+//ConfirmTOTP 校验身份验证器生成的验证码后启用TOTP，返回恢复码及通过二次验证的access token，仅支持JWT鉴权
+func ConfirmTOTP(c *gin.Context) {
+	var req ConfirmTOTPRequest
+	err := protocol.Bind(c, &req)
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.InvalidParameter(err))
+		return
+	}
+	claims, ok := jwtClaims(c)
+	if !ok {
+		return
+	}
+	db, err := datasource.Gormv2(c.Request.Context())
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	t, err := models.GetUserTOTP(db, claims.Uid)
+	if err == gorm.ErrRecordNotFound {
+		protocol.SetErrResponse(c, protocol.ErrCodeMFANotEnabled)
+		return
+	}
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	if t.Enabled() {
+		protocol.SetErrResponse(c, protocol.ErrCodeMFAAlreadyEnabled)
+		return
+	}
+	counter, ok := checkTOTPCode(c, t, req.Code)
+	if !ok {
+		return
+	}
+
+	ok, err = t.Enable(db, counter)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	if !ok {
+		protocol.SetErrResponse(c, protocol.ErrCodeMFAAlreadyEnabled)
+		return
+	}
+	codes, err := newRecoveryCodes(db, claims.Uid)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	mw.Audit(c, mw.AuditEventMFAEnabled, strconv.FormatUint(claims.Uid, 10))
+
+	token, ok := mfaToken(c, claims)
+	if !ok {
+		return
+	}
+	protocol.SetResponse(c, &ConfirmTOTPResponse{MFATokenResponse: *token, RecoveryCodes: codes})
+}
+
+//VerifyMFARequest 二次验证的请求参数，Code和RecoveryCode二选一
+type VerifyMFARequest struct {
+	Code         string `binding:"required_without=RecoveryCode,omitempty,len=6,numeric"`
+	RecoveryCode string `binding:"max=32"`
+}
+
+//VerifyMFA 使用TOTP验证码或恢复码完成二次验证，返回通过二次验证的access token，仅支持JWT鉴权
+// 每个验证码、恢复码只能使用一次，失败次数计入图形验证码的风险统计
+func VerifyMFA(c *gin.Context) {
+	var req VerifyMFARequest
+	err := protocol.Bind(c, &req)
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.InvalidParameter(err))
+		return
+	}
+	claims, ok := jwtClaims(c)
+	if !ok {
+		return
+	}
+	db, err := datasource.Gormv2(c.Request.Context())
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	t, ok := enabledTOTP(c, db, claims.Uid)
+	if !ok {
+		return
+	}
+
+	if len(req.Code) > 0 {
+		counter, ok := checkTOTPCode(c, t, req.Code)
+		if !ok {
+			return
+		}
+		ok, err = t.UseCounter(db, counter)
+		if err == nil && !ok {
+			// 并发使用同一验证码
+			protocol.SetErrResponse(c, protocol.ErrCodeInvalidMFACode)
+			return
+		}
+	} else {
+		ok, err = models.ConsumeRecoveryCode(db, claims.Uid, hashRecoveryCode(req.RecoveryCode))
+		if err == nil && !ok {
+			mw.RecordCaptchaFailure(c)
+			protocol.SetErrResponse(c, protocol.ErrCodeInvalidMFACode)
+			return
+		}
+		if err == nil {
+			mw.Audit(c, mw.AuditEventRecoveryCodeUsed, strconv.FormatUint(claims.Uid, 10))
+		}
+	}
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+
+	token, ok := mfaToken(c, claims)
+	if !ok {
+		return
+	}
+	protocol.SetResponse(c, token)
+}
+
+//RegenerateRecoveryCodesResponse 重新生成恢复码的返回
+type RegenerateRecoveryCodesResponse struct {
+	RecoveryCodes []string
+}
+
+//RegenerateRecoveryCodes 重新生成恢复码，之前的恢复码全部失效，需先完成二次验证
+func RegenerateRecoveryCodes(c *gin.Context) {
+	claims, ok := jwtClaims(c)
+	if !ok {
+		return
+	}
+	db, err := datasource.Gormv2(c.Request.Context())
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	if _, ok := enabledTOTP(c, db, claims.Uid); !ok {
+		return
+	}
+	codes, err := newRecoveryCodes(db, claims.Uid)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	protocol.SetResponse(c, &RegenerateRecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+//DisableTOTP 关闭TOTP并删除恢复码，需先完成二次验证
+func DisableTOTP(c *gin.Context) {
+	claims, ok := jwtClaims(c)
+	if !ok {
+		return
+	}
+	db, err := datasource.Gormv2(c.Request.Context())
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	if _, ok := enabledTOTP(c, db, claims.Uid); !ok {
+		return
+	}
+	if err := models.DeleteUserTOTP(db, claims.Uid); err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	mw.Audit(c, mw.AuditEventMFADisabled, strconv.FormatUint(claims.Uid, 10))
+	protocol.SetResponse(c, struct{}{})
+}
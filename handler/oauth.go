@@ -0,0 +1,457 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"ginfra/datasource"
+	"ginfra/log"
+	mw "ginfra/middleware"
+	"ginfra/models"
+	"ginfra/protocol"
+	"ginfra/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// OAuth2授权方式
+const (
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeClientCredentials = "client_credentials"
+)
+
+// OIDC内置的授权范围，email、phone返回用户绑定的邮箱、手机号
+var oidcScopes = []string{"openid", "email", "phone"}
+
+// OAuth2错误码，见RFC 6749 5.2
+const (
+	oauthErrInvalidRequest       = "invalid_request"
+	oauthErrInvalidClient        = "invalid_client"
+	oauthErrInvalidGrant         = "invalid_grant"
+	oauthErrUnauthorizedClient   = "unauthorized_client"
+	oauthErrUnsupportedGrantType = "unsupported_grant_type"
+	oauthErrUnsupportedResponse  = "unsupported_response_type"
+	oauthErrInvalidScope         = "invalid_scope"
+	oauthErrInvalidToken         = "invalid_token"
+	oauthErrInsufficientScope    = "insufficient_scope"
+	oauthErrServerError          = "server_error"
+)
+
+// oauthError OAuth2端点按RFC 6749返回错误，不使用protocol响应格式
+func oauthError(c *gin.Context, status int, code, description string) {
+	c.Header("Cache-Control", "no-store")
+	c.JSON(status, gin.H{"error": code, "error_description": description})
+}
+
+//OAuthTokenResponse token端点的响应，见RFC 6749 5.1
+type OAuthTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Scope       string `json:"scope,omitempty"`
+	IDToken     string `json:"id_token,omitempty"`
+}
+
+//OAuthAuthorize 授权端点，仅支持授权码模式且必须使用PKCE(S256)；已登录用户直接授权，不展示授权确认页
+// client_id、redirect_uri无效时直接返回错误，其他错误跳转回redirect_uri
+func OAuthAuthorize(c *gin.Context) {
+	clientID := c.Query("client_id")
+	redirectURI := c.Query("redirect_uri")
+
+	db, err := datasource.Gormv2(c.Request.Context())
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	client, err := models.GetOAuthClient(db, clientID)
+	if err != nil || client.Disabled {
+		protocol.SetErrResponse(c, protocol.ErrCodeOAuthClientNotFound)
+		return
+	}
+	redirectURIs := client.RedirectURIList()
+	if len(redirectURI) == 0 && len(redirectURIs) == 1 {
+		redirectURI = redirectURIs[0]
+	}
+	if !utils.StringInSlice(redirectURI, redirectURIs) {
+		protocol.SetErrResponse(c, protocol.ErrCodeInvalidRedirectURI)
+		return
+	}
+
+	state := c.Query("state")
+	redirect := func(params url.Values) {
+		if len(state) > 0 {
+			params.Set("state", state)
+		}
+		sep := "?"
+		if strings.Contains(redirectURI, "?") {
+			sep = "&"
+		}
+		c.Redirect(http.StatusFound, redirectURI+sep+params.Encode())
+	}
+	redirectError := func(code, description string) {
+		redirect(url.Values{"error": {code}, "error_description": {description}})
+	}
+
+	if c.Query("response_type") != "code" {
+		redirectError(oauthErrUnsupportedResponse, "only response_type=code is supported")
+		return
+	}
+	if !utils.StringInSlice(GrantTypeAuthorizationCode, client.GrantTypeList()) {
+		redirectError(oauthErrUnauthorizedClient, "authorization_code grant is not allowed")
+		return
+	}
+	challenge := c.Query("code_challenge")
+	method := c.Query("code_challenge_method")
+	if len(challenge) == 0 || method != "S256" {
+		redirectError(oauthErrInvalidRequest, "code_challenge with method S256 is required")
+		return
+	}
+	scope, ok := grantedScope(c.Query("scope"), client)
+	if !ok {
+		redirectError(oauthErrInvalidScope, "requested scope is not allowed")
+		return
+	}
+
+	// 授权码绑定当前登录用户
+	claims, ok := jwtClaims(c)
+	if !ok {
+		return
+	}
+	authTime, ok := c.Value(protocol.CtxAuthTime).(time.Time)
+	if !ok {
+		authTime = time.Now()
+	}
+	code, err := utils.RandomString(40)
+	if err != nil {
+		redirectError(oauthErrServerError, "generate code fail")
+		return
+	}
+	oauthCode := &models.OAuthCode{
+		CodeHash:            utils.SHA256Hex(code),
+		ClientID:            client.ClientID,
+		Uid:                 claims.Uid,
+		RedirectURI:         redirectURI,
+		Scope:               scope,
+		Nonce:               c.Query("nonce"),
+		CodeChallenge:       challenge,
+		CodeChallengeMethod: method,
+		AuthTime:            authTime,
+		ExpiresAt:           time.Now().Add(mw.OAuthCodeExpires),
+	}
+	err = oauthCode.Insert(db)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		redirectError(oauthErrServerError, "save code fail")
+		return
+	}
+	redirect(url.Values{"code": {code}})
+}
+
+// grantedScope 校验申请的授权范围，未申请时授予客户端的全部授权范围
+func grantedScope(requested string, client *models.OAuthClient) (string, bool) {
+	allowed := client.ScopeList()
+	scopes := strings.Fields(requested)
+	if len(scopes) == 0 {
+		return strings.Join(allowed, " "), true
+	}
+	for _, s := range scopes {
+		if !utils.StringInSlice(s, allowed) {
+			return "", false
+		}
+	}
+	return strings.Join(scopes, " "), true
+}
+
+// authenticateClient 校验客户端身份，支持client_secret_basic、client_secret_post，Public客户端只需client_id
+// 失败时返回invalid_client错误
+func authenticateClient(c *gin.Context, db *gorm.DB) (*models.OAuthClient, bool) {
+	clientID, secret, basic := c.Request.BasicAuth()
+	if basic {
+		// Basic认证的client_id、client_secret需先做form编码，见RFC 6749 2.3.1
+		clientID, _ = url.QueryUnescape(clientID)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		clientID = c.PostForm("client_id")
+		secret = c.PostForm("client_secret")
+	}
+
+	fail := func() (*models.OAuthClient, bool) {
+		if basic {
+			c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+		}
+		oauthError(c, http.StatusUnauthorized, oauthErrInvalidClient, "client authentication failed")
+		return nil, false
+	}
+	if len(clientID) == 0 {
+		return fail()
+	}
+	client, err := models.GetOAuthClient(db, clientID)
+	if err != nil || client.Disabled {
+		return fail()
+	}
+	if client.Public {
+		if len(secret) > 0 {
+			return fail()
+		}
+		return client, true
+	}
+	if subtle.ConstantTimeCompare([]byte(mw.HashSecretKey(secret)), []byte(client.SecretHash)) != 1 {
+		return fail()
+	}
+	return client, true
+}
+
+//OAuthToken token端点，支持授权码模式及客户端凭证模式
+func OAuthToken(c *gin.Context) {
+	c.Header("Pragma", "no-cache")
+	db, err := datasource.Gormv2(c.Request.Context())
+	if err != nil {
+		oauthError(c, http.StatusInternalServerError, oauthErrServerError, "database unavailable")
+		return
+	}
+	client, ok := authenticateClient(c, db)
+	if !ok {
+		return
+	}
+
+	grantType := c.PostForm("grant_type")
+	if grantType != GrantTypeAuthorizationCode && grantType != GrantTypeClientCredentials {
+		oauthError(c, http.StatusBadRequest, oauthErrUnsupportedGrantType, "unsupported grant_type")
+		return
+	}
+	if !utils.StringInSlice(grantType, client.GrantTypeList()) {
+		oauthError(c, http.StatusBadRequest, oauthErrUnauthorizedClient, grantType+" grant is not allowed")
+		return
+	}
+
+	if grantType == GrantTypeClientCredentials {
+		clientCredentialsGrant(c, client)
+		return
+	}
+	authorizationCodeGrant(c, db, client)
+}
+
+func authorizationCodeGrant(c *gin.Context, db *gorm.DB, client *models.OAuthClient) {
+	code, err := models.GetOAuthCodeByHash(db, utils.SHA256Hex(c.PostForm("code")))
+	if err != nil || code.ClientID != client.ClientID || code.UsedAt != nil || code.ExpiresAt.Before(time.Now()) {
+		oauthError(c, http.StatusBadRequest, oauthErrInvalidGrant, "invalid authorization code")
+		return
+	}
+	if code.RedirectURI != c.PostForm("redirect_uri") {
+		oauthError(c, http.StatusBadRequest, oauthErrInvalidGrant, "redirect_uri mismatch")
+		return
+	}
+	if !mw.VerifyPKCE(c.PostForm("code_verifier"), code.CodeChallenge, code.CodeChallengeMethod) {
+		oauthError(c, http.StatusBadRequest, oauthErrInvalidGrant, "invalid code_verifier")
+		return
+	}
+	ok, err := code.Consume(db)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		oauthError(c, http.StatusInternalServerError, oauthErrServerError, "consume code fail")
+		return
+	}
+	if !ok {
+		// 并发使用，已被其他请求使用
+		oauthError(c, http.StatusBadRequest, oauthErrInvalidGrant, "invalid authorization code")
+		return
+	}
+
+	subject := strconv.FormatUint(code.Uid, 10)
+	accessToken, _, err := mw.NewOAuthAccessToken(subject, client.ClientID, code.Scope, &code.AuthTime)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", oauthErrServerError))
+		oauthError(c, http.StatusInternalServerError, oauthErrServerError, "sign token fail")
+		return
+	}
+	resp := &OAuthTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(mw.OAuthAccessExpires / time.Second),
+		Scope:       code.Scope,
+	}
+	if utils.StringInSlice("openid", strings.Fields(code.Scope)) {
+		resp.IDToken, err = mw.NewIDToken(subject, client.ClientID, code.Nonce, code.AuthTime)
+		if err != nil {
+			log.WithGinContext(c).Error(err.Error(), zap.String("error", oauthErrServerError))
+			oauthError(c, http.StatusInternalServerError, oauthErrServerError, "sign token fail")
+			return
+		}
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, resp)
+}
+
+func clientCredentialsGrant(c *gin.Context, client *models.OAuthClient) {
+	if client.Public {
+		oauthError(c, http.StatusBadRequest, oauthErrUnauthorizedClient, "public client cannot use client_credentials")
+		return
+	}
+	scope, ok := grantedScope(c.PostForm("scope"), client)
+	if !ok {
+		oauthError(c, http.StatusBadRequest, oauthErrInvalidScope, "requested scope is not allowed")
+		return
+	}
+	// 客户端凭证没有用户，不授予OIDC授权范围
+	scopes := make([]string, 0)
+	for _, s := range strings.Fields(scope) {
+		if !utils.StringInSlice(s, oidcScopes) {
+			scopes = append(scopes, s)
+		}
+	}
+	scope = strings.Join(scopes, " ")
+
+	accessToken, _, err := mw.NewOAuthAccessToken(client.ClientID, client.ClientID, scope, nil)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", oauthErrServerError))
+		oauthError(c, http.StatusInternalServerError, oauthErrServerError, "sign token fail")
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, &OAuthTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(mw.OAuthAccessExpires / time.Second),
+		Scope:       scope,
+	})
+}
+
+//OAuthIntrospect token内省端点，见RFC 7662，调用方需进行客户端认证
+func OAuthIntrospect(c *gin.Context) {
+	db, err := datasource.Gormv2(c.Request.Context())
+	if err != nil {
+		oauthError(c, http.StatusInternalServerError, oauthErrServerError, "database unavailable")
+		return
+	}
+	if _, ok := authenticateClient(c, db); !ok {
+		return
+	}
+
+	claims, err := parseOAuthAccessToken(c, db, c.PostForm("token"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"active": false})
+		return
+	}
+	resp := gin.H{
+		"active":     true,
+		"token_type": "Bearer",
+		"client_id":  claims.ClientID,
+		"sub":        claims.Subject,
+		"scope":      claims.Scope,
+		"iss":        claims.Issuer,
+		"jti":        claims.ID,
+	}
+	if claims.ExpiresAt != nil {
+		resp["exp"] = claims.ExpiresAt.Unix()
+	}
+	if claims.IssuedAt != nil {
+		resp["iat"] = claims.IssuedAt.Unix()
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// parseOAuthAccessToken 解析access token，客户端已禁用时token无效
+func parseOAuthAccessToken(c *gin.Context, db *gorm.DB, token string) (*mw.OAuthClaims, error) {
+	claims, err := mw.ParseOAuthAccessToken(c.Request.Context(), token)
+	if err != nil {
+		return nil, err
+	}
+	client, err := models.GetOAuthClient(db, claims.ClientID)
+	if err != nil {
+		return nil, err
+	}
+	if client.Disabled {
+		return nil, errors.New("oauth client disabled")
+	}
+	return claims, nil
+}
+
+//OAuthUserInfo OIDC userinfo端点，需携带openid授权范围的access token
+func OAuthUserInfo(c *gin.Context) {
+	unauthorized := func(code, description string) {
+		c.Header("WWW-Authenticate", `Bearer error="`+code+`", error_description="`+description+`"`)
+		status := http.StatusUnauthorized
+		if code == oauthErrInsufficientScope {
+			status = http.StatusForbidden
+		}
+		oauthError(c, status, code, description)
+	}
+
+	auth := c.GetHeader("Authorization")
+	if len(auth) < 7 || !strings.EqualFold(auth[:7], "Bearer ") {
+		unauthorized(oauthErrInvalidToken, "bearer token is required")
+		return
+	}
+	db, err := datasource.Gormv2(c.Request.Context())
+	if err != nil {
+		oauthError(c, http.StatusInternalServerError, oauthErrServerError, "database unavailable")
+		return
+	}
+	claims, err := parseOAuthAccessToken(c, db, strings.TrimSpace(auth[7:]))
+	if err != nil {
+		unauthorized(oauthErrInvalidToken, "invalid access token")
+		return
+	}
+	scopes := claims.ScopeList()
+	if claims.ClientCredentials() || !utils.StringInSlice("openid", scopes) {
+		unauthorized(oauthErrInsufficientScope, "openid scope is required")
+		return
+	}
+
+	uid, err := strconv.ParseUint(claims.Subject, 10, 64)
+	if err != nil {
+		unauthorized(oauthErrInvalidToken, "invalid subject")
+		return
+	}
+	info := gin.H{"sub": claims.Subject}
+	for _, s := range []struct {
+		scope        string
+		claim        string
+		identityType int
+	}{
+		{"email", "email", models.IdentityTypeEmail},
+		{"phone", "phone_number", models.IdentityTypePhone},
+	} {
+		if !utils.StringInSlice(s.scope, scopes) {
+			continue
+		}
+		a, err := models.GetUserAuthByUidAndType(db, uid, s.identityType)
+		if err == nil {
+			info[s.claim] = a.Identifier
+		} else if err != gorm.ErrRecordNotFound {
+			log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+			oauthError(c, http.StatusInternalServerError, oauthErrServerError, "query user fail")
+			return
+		}
+	}
+	c.JSON(http.StatusOK, info)
+}
+
+//OpenIDConfiguration OIDC discovery，见OpenID Connect Discovery 1.0
+func OpenIDConfiguration(c *gin.Context) {
+	issuer := mw.OAuthIssuer
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.JSON(http.StatusOK, gin.H{
+		"issuer":                                issuer,
+		"authorization_endpoint":                issuer + "/oauth/authorize",
+		"token_endpoint":                        issuer + "/oauth/token",
+		"introspection_endpoint":                issuer + "/oauth/introspect",
+		"userinfo_endpoint":                     issuer + "/oauth/userinfo",
+		"jwks_uri":                              issuer + "/.well-known/jwks.json",
+		"response_types_supported":              []string{"code"},
+		"grant_types_supported":                 []string{GrantTypeAuthorizationCode, GrantTypeClientCredentials},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{mw.Keys().Algorithm()},
+		"scopes_supported":                      oidcScopes,
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post", "none"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"claims_supported":                      []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "email", "phone_number"},
+	})
+}
@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"net/url"
+	"strings"
+
+	"ginfra/datasource"
+	"ginfra/errcode"
+	"ginfra/log"
+	mw "ginfra/middleware"
+	"ginfra/models"
+	"ginfra/protocol"
+	"ginfra/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+//CreateOAuthClientRequest 登记OAuth客户端的请求参数
+type CreateOAuthClientRequest struct {
+	Name         string   `binding:"required,max=128"`
+	RedirectURIs []string `binding:"max=16"` // 授权码模式必填
+	Scopes       []string `binding:"required,min=1"`
+	GrantTypes   []string `binding:"required,min=1,dive,oneof=authorization_code client_credentials"`
+	Public       bool     // SPA、App等无法保存密钥的客户端，只能使用授权码模式+PKCE
+}
+
+//CreateOAuthClientResponse 登记OAuth客户端的响应参数，ClientSecret仅在此返回一次
+type CreateOAuthClientResponse struct {
+	ClientID     string
+	ClientSecret string `json:",omitempty"`
+}
+
+//CreateOAuthClient 管理员登记OAuth客户端
+func CreateOAuthClient(c *gin.Context) {
+	var req CreateOAuthClientRequest
+	err := protocol.Bind(c, &req)
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.InvalidParameter(err))
+		return
+	}
+	if req.Public && utils.StringInSlice(GrantTypeClientCredentials, req.GrantTypes) {
+		protocol.SetErrResponse(c, protocol.ErrCodeInvalidParameter)
+		return
+	}
+	if utils.StringInSlice(GrantTypeAuthorizationCode, req.GrantTypes) && len(req.RedirectURIs) == 0 {
+		protocol.SetErrResponse(c, protocol.ErrCodeInvalidRedirectURI)
+		return
+	}
+	for _, uri := range req.RedirectURIs {
+		// 回调地址需为绝对地址且不能包含fragment，见RFC 6749 3.1.2
+		u, err := url.Parse(uri)
+		if err != nil || !u.IsAbs() || len(u.Host) == 0 || len(u.Fragment) > 0 || strings.Contains(uri, ",") {
+			protocol.SetErrResponse(c, protocol.ErrCodeInvalidRedirectURI)
+			return
+		}
+	}
+	for _, s := range req.Scopes {
+		if len(s) == 0 || strings.ContainsAny(s, " ,") {
+			protocol.SetErrResponse(c, protocol.ErrCodeInvalidParameter)
+			return
+		}
+	}
+
+	clientID, err := utils.RandomString(32)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", errcode.ErrCodeInternalError))
+		protocol.SetErrResponse(c, errcode.NewCustomError(errcode.ErrCodeInternalError, "生成客户端ID失败"))
+		return
+	}
+	client := &models.OAuthClient{
+		ClientID:     clientID,
+		Name:         req.Name,
+		RedirectURIs: strings.Join(req.RedirectURIs, ","),
+		Scopes:       strings.Join(req.Scopes, ","),
+		GrantTypes:   strings.Join(req.GrantTypes, ","),
+		Public:       req.Public,
+	}
+	resp := &CreateOAuthClientResponse{ClientID: clientID}
+	if !req.Public {
+		resp.ClientSecret, err = utils.RandomString(40)
+		if err != nil {
+			log.WithGinContext(c).Error(err.Error(), zap.String("error", errcode.ErrCodeInternalError))
+			protocol.SetErrResponse(c, errcode.NewCustomError(errcode.ErrCodeInternalError, "生成客户端密钥失败"))
+			return
+		}
+		client.SecretHash = mw.HashSecretKey(resp.ClientSecret)
+	}
+
+	db, err := datasource.Gormv2(c.Request.Context())
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	err = client.Insert(db)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	log.WithGinContext(c).Info("create oauth client", zap.String("ClientID", clientID), zap.String("Name", req.Name))
+	protocol.SetResponse(c, resp)
+}
+
+//DescribeOAuthClientsResponse 查询OAuth客户端的响应参数
+type DescribeOAuthClientsResponse struct {
+	Clients []*models.OAuthClient
+}
+
+//DescribeOAuthClients 管理员查询OAuth客户端
+func DescribeOAuthClients(c *gin.Context) {
+	db, err := datasource.Gormv2(c.Request.Context())
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	clients, err := models.ListOAuthClients(db)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	protocol.SetResponse(c, &DescribeOAuthClientsResponse{Clients: clients})
+}
+
+//DisableOAuthClientRequest 禁用OAuth客户端的请求参数
+type DisableOAuthClientRequest struct {
+	ClientID string `binding:"required,max=64"`
+}
+
+//DisableOAuthClient 管理员禁用OAuth客户端，已签发的access token在内省及userinfo时视为无效
+func DisableOAuthClient(c *gin.Context) {
+	var req DisableOAuthClientRequest
+	err := protocol.Bind(c, &req)
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.InvalidParameter(err))
+		return
+	}
+
+	db, err := datasource.Gormv2(c.Request.Context())
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	client, err := models.GetOAuthClient(db, req.ClientID)
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.ErrCodeOAuthClientNotFound)
+		return
+	}
+	err = client.Disable(db)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	log.WithGinContext(c).Info("disable oauth client", zap.String("ClientID", req.ClientID))
+	protocol.SetResponse(c, struct{}{})
+}
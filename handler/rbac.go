@@ -0,0 +1,233 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+
+	"ginfra/datasource"
+	"ginfra/log"
+	mw "ginfra/middleware"
+	"ginfra/models"
+	"ginfra/protocol"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+//RoleRequest 创建及修改角色的请求参数
+type RoleRequest struct {
+	Name        string   `binding:"required,min=1,max=64"`
+	Description string   `binding:"max=256"`
+	Permissions []string `binding:"required,min=1"`
+}
+
+//CreateRole 创建角色，角色为全局定义，需在全局拥有role:write权限
+func CreateRole(c *gin.Context) {
+	var req RoleRequest
+	err := protocol.Bind(c, &req)
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.InvalidParameter(err))
+		return
+	}
+	if !mw.AuthorizeCustomer(c, "", "role:write") {
+		return
+	}
+
+	db, err := datasource.Gormv2(c.Request.Context())
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	role := &models.Role{
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: strings.Join(req.Permissions, ","),
+	}
+	err = role.Insert(db)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+
+	mw.Audit(c, mw.AuditEventRoleChanged, fmt.Sprintf("create role %s: %s", role.Name, role.Permissions))
+	protocol.SetResponse(c, role)
+}
+
+//UpdateRole 修改角色权限，角色可能分配在任意客户下，需在全局拥有role:write权限
+func UpdateRole(c *gin.Context) {
+	var req RoleRequest
+	err := protocol.Bind(c, &req)
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.InvalidParameter(err))
+		return
+	}
+	if !mw.AuthorizeCustomer(c, "", "role:write") {
+		return
+	}
+
+	db, err := datasource.Gormv2(c.Request.Context())
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	role, ok := getRole(c, db, req.Name)
+	if !ok {
+		return
+	}
+	err = role.UpdatePermissions(db, strings.Join(req.Permissions, ","))
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+
+	// 清除拥有该角色的用户的权限缓存，查询失败时等待缓存过期
+	uids, err := models.ListRoleUids(db, role.ID)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+	}
+	for _, uid := range uids {
+		mw.InvalidatePermissions(uid)
+	}
+
+	mw.Audit(c, mw.AuditEventRoleChanged, fmt.Sprintf("update role %s: %s", role.Name, role.Permissions))
+	protocol.SetResponse(c, role)
+}
+
+//DescribeRolesResponse 查询角色列表的响应参数
+type DescribeRolesResponse struct {
+	Roles []*models.Role
+}
+
+//DescribeRoles 查询角色列表
+func DescribeRoles(c *gin.Context) {
+	db, err := datasource.Gormv2(c.Request.Context())
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	roles, err := models.ListRoles(db)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	protocol.SetResponse(c, &DescribeRolesResponse{Roles: roles})
+}
+
+//UserRoleRequest 分配及取消角色的请求参数，CustomerID为空表示全局角色
+// 只能操作当前请求所属客户的角色，全局及其他客户的角色需在全局拥有role:write权限
+type UserRoleRequest struct {
+	Uid        uint64 `binding:"required,min=1"`
+	Role       string `binding:"required,min=1"`
+	CustomerID string `binding:"max=64"`
+}
+
+//AssignRole 为用户分配角色
+func AssignRole(c *gin.Context) {
+	req, role, db, ok := bindUserRole(c)
+	if !ok {
+		return
+	}
+
+	ur := &models.UserRole{Uid: req.Uid, RoleID: role.ID, CustomerID: req.CustomerID}
+	err := ur.Insert(db)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	mw.InvalidatePermissions(req.Uid)
+
+	mw.Audit(c, mw.AuditEventRoleAssigned,
+		fmt.Sprintf("uid=%d role=%s customer=%s", req.Uid, role.Name, req.CustomerID))
+	protocol.SetResponse(c, struct{}{})
+}
+
+//UnassignRole 取消用户的角色
+func UnassignRole(c *gin.Context) {
+	req, role, db, ok := bindUserRole(c)
+	if !ok {
+		return
+	}
+
+	_, err := models.DeleteUserRole(db, req.Uid, role.ID, req.CustomerID)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	mw.InvalidatePermissions(req.Uid)
+
+	mw.Audit(c, mw.AuditEventRoleUnassigned,
+		fmt.Sprintf("uid=%d role=%s customer=%s", req.Uid, role.Name, req.CustomerID))
+	protocol.SetResponse(c, struct{}{})
+}
+
+//DescribeUserRolesRequest 查询用户角色的请求参数
+type DescribeUserRolesRequest struct {
+	Uid uint64 `binding:"required,min=1"`
+}
+
+//DescribeUserRolesResponse 查询用户角色的响应参数
+type DescribeUserRolesResponse struct {
+	UserRoles []*models.UserRole
+}
+
+//DescribeUserRoles 查询用户的角色
+func DescribeUserRoles(c *gin.Context) {
+	var req DescribeUserRolesRequest
+	err := protocol.Bind(c, &req)
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.InvalidParameter(err))
+		return
+	}
+
+	db, err := datasource.Gormv2(c.Request.Context())
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	userRoles, err := models.ListUserRoles(db, req.Uid)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	protocol.SetResponse(c, &DescribeUserRolesResponse{UserRoles: userRoles})
+}
+
+func bindUserRole(c *gin.Context) (*UserRoleRequest, *models.Role, *gorm.DB, bool) {
+	var req UserRoleRequest
+	err := protocol.Bind(c, &req)
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.InvalidParameter(err))
+		return nil, nil, nil, false
+	}
+	if !mw.AuthorizeCustomer(c, req.CustomerID, "role:write") {
+		return nil, nil, nil, false
+	}
+
+	db, err := datasource.Gormv2(c.Request.Context())
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return nil, nil, nil, false
+	}
+	role, ok := getRole(c, db, req.Role)
+	if !ok {
+		return nil, nil, nil, false
+	}
+	return &req, role, db, true
+}
+
+func getRole(c *gin.Context, db *gorm.DB, name string) (*models.Role, bool) {
+	role, err := models.GetRoleByName(db, name)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("Role", name))
+		protocol.SetErrResponse(c, protocol.ErrCodeRoleNotFound)
+		return nil, false
+	}
+	return role, true
+}
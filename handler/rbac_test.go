@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ginfra/log"
+	mw "ginfra/middleware"
+	"ginfra/models"
+	"ginfra/protocol"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smartystreets/goconvey/convey"
+	"go.uber.org/zap"
+)
+
+func Test_TenantAdminEscalation(t *testing.T) {
+	oldLogger := log.ZLog
+	defer func() {
+		log.ZLog = oldLogger
+		mw.InvalidatePermissions(5)
+	}()
+	log.ZLog = zap.NewNop()
+	db, closeDB := newMigratedDB(t)
+	defer closeDB()
+
+	// 用户5是客户acme的管理员
+	admin := &models.Role{Name: "tenant-admin", Permissions: "role:write,apikey:write"}
+	root := &models.Role{Name: "root", Permissions: "*"}
+	for _, r := range []*models.Role{admin, root} {
+		if err := r.Insert(db); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := (&models.UserRole{Uid: 5, RoleID: admin.ID, CustomerID: "acme"}).Insert(db); err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("claims", &ClaimData{Uid: 5})
+		c.Set(protocol.CtxAuthType, protocol.AuthTypeJWT)
+		c.Set(protocol.CtxUserID, "5")
+		c.Set(protocol.CtxCustomerID, "acme")
+	})
+	r.POST("/AssignRole", mw.Require("role:write"), AssignRole)
+	r.POST("/CreateApiKey", mw.Require("apikey:write"), CreateApiKey)
+	do := func(path, body string) string {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+	unauthorized := protocol.ErrCodeUnAuthorized.Code
+
+	convey.Convey("客户管理员不能分配全局或其他客户的角色", t, func() {
+		convey.So(do("/AssignRole", `{"Uid":6,"Role":"root"}`), convey.ShouldContainSubstring, unauthorized)
+		convey.So(do("/AssignRole", `{"Uid":6,"Role":"root","CustomerID":"other"}`), convey.ShouldContainSubstring, unauthorized)
+		convey.So(do("/AssignRole", `{"Uid":6,"Role":"root","CustomerID":"acme"}`), convey.ShouldNotContainSubstring, `"Error"`)
+
+		roles, err := models.ListUserRoles(db, 6)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(len(roles), convey.ShouldEqual, 1)
+		convey.So(roles[0].CustomerID, convey.ShouldEqual, "acme")
+	})
+
+	convey.Convey("客户管理员不能创建其他客户的API密钥", t, func() {
+		convey.So(do("/CreateApiKey", `{"CustomerID":"other","Scopes":["*"]}`), convey.ShouldContainSubstring, unauthorized)
+		convey.So(do("/CreateApiKey", `{"CustomerID":"acme","Scopes":["*"]}`), convey.ShouldContainSubstring, "SecretKey")
+	})
+}
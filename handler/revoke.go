@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"strconv"
+	"time"
+
+	"ginfra/datasource"
+	"ginfra/errcode"
+	"ginfra/log"
+	mw "ginfra/middleware"
+	"ginfra/models"
+	"ginfra/protocol"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+//RevokeToken 注销当前登录态token，仅支持JWT鉴权
+func RevokeToken(c *gin.Context) {
+	jti := protocol.GetTokenId(c)
+	if protocol.GetAuthType(c) != protocol.AuthTypeJWT || len(jti) == 0 {
+		protocol.SetErrResponse(c, protocol.ErrCodeUnAuthorized)
+		return
+	}
+
+	expiresAt, ok := c.Value(protocol.CtxTokenExpiresAt).(time.Time)
+	if !ok {
+		expiresAt = time.Now().Add(time.Duration(mw.JWTExpires) * time.Second)
+	}
+	err := mw.RevokeToken(c.Request.Context(), jti, expiresAt)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", errcode.ErrCodeInternalError))
+		protocol.SetErrResponse(c, errcode.NewCustomError(errcode.ErrCodeInternalError, "注销登录态失败"))
+		return
+	}
+	protocol.SetResponse(c, struct{}{})
+}
+
+//RevokeAllTokens 注销当前用户在所有设备上的登录态，仅支持JWT鉴权
+func RevokeAllTokens(c *gin.Context) {
+	if protocol.GetAuthType(c) != protocol.AuthTypeJWT {
+		protocol.SetErrResponse(c, protocol.ErrCodeUnAuthorized)
+		return
+	}
+	claims, err := getClaimData(c)
+	if err != nil {
+		protocol.SetErrResponse(c, err)
+		return
+	}
+	revokeUserTokens(c, claims.Uid)
+}
+
+//RevokeUserTokensRequest 管理员注销用户登录态的请求参数
+type RevokeUserTokensRequest struct {
+	Uid uint64 `binding:"required,min=1"`
+}
+
+//RevokeUserTokens 管理员注销指定用户的全部登录态
+func RevokeUserTokens(c *gin.Context) {
+	var req RevokeUserTokensRequest
+	err := protocol.Bind(c, &req)
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.InvalidParameter(err))
+		return
+	}
+	log.WithGinContext(c).Info("admin revoke user tokens", zap.Uint64("Uid", req.Uid))
+	revokeUserTokens(c, req.Uid)
+}
+
+//RevokeTokenByIdRequest 管理员按jti注销登录态的请求参数
+type RevokeTokenByIdRequest struct {
+	Jti string `binding:"required,min=1,max=64"`
+}
+
+//RevokeTokenById 管理员按jti注销单个登录态token
+func RevokeTokenById(c *gin.Context) {
+	var req RevokeTokenByIdRequest
+	err := protocol.Bind(c, &req)
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.InvalidParameter(err))
+		return
+	}
+
+	// 不知道token的过期时间，按最长有效期保留吊销记录
+	expires := mw.JWTExpires
+	if mw.AccessExpires > expires {
+		expires = mw.AccessExpires
+	}
+	if mw.RenewWindow > 0 && int64(mw.RenewMaxAge/time.Second) > expires {
+		// 续期的token沿用jti，最长可到RenewMaxAge
+		expires = int64(mw.RenewMaxAge / time.Second)
+	}
+	log.WithGinContext(c).Info("admin revoke token", zap.String("jti", req.Jti))
+	err = mw.RevokeToken(c.Request.Context(), req.Jti, time.Now().Add(time.Duration(expires)*time.Second))
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", errcode.ErrCodeInternalError))
+		protocol.SetErrResponse(c, errcode.NewCustomError(errcode.ErrCodeInternalError, "注销登录态失败"))
+		return
+	}
+	protocol.SetResponse(c, struct{}{})
+}
+
+// revokeUserTokens 设置用户登录态水位，吊销全部refresh token并结束全部会话
+func revokeUserTokens(c *gin.Context, uid uint64) {
+	err := mw.RevokeUserTokens(c.Request.Context(), strconv.FormatUint(uid, 10))
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", errcode.ErrCodeInternalError))
+		protocol.SetErrResponse(c, errcode.NewCustomError(errcode.ErrCodeInternalError, "注销登录态失败"))
+		return
+	}
+
+	db, err := datasource.Gormv2(c.Request.Context())
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	err = models.RevokeUserSessionsByUid(db, uid)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	protocol.SetResponse(c, struct{}{})
+}
@@ -0,0 +1,196 @@
+package handler
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"ginfra/datasource"
+	"ginfra/errcode"
+	"ginfra/log"
+	mw "ginfra/middleware"
+	"ginfra/models"
+	"ginfra/protocol"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 保存的User-Agent最大长度
+const maxUserAgentLength = 256
+
+// newSession 登录签发token对时创建会话，hash为refresh token的哈希值
+func newSession(c *gin.Context, db *gorm.DB, auth *models.UserAuth, family, hash string, expiresAt time.Time) (*models.UserSession, error) {
+	ua := []rune(c.Request.UserAgent())
+	if len(ua) > maxUserAgentLength {
+		ua = ua[:maxUserAgentLength]
+	}
+	now := time.Now()
+	session := &models.UserSession{
+		SessionID:     uuid.New().String(),
+		Uid:           auth.Uid,
+		UserAuthID:    auth.ID,
+		IdentityType:  auth.IdentityType,
+		RefreshFamily: family,
+		RefreshToken:  hash,
+		Device:        deviceFromUserAgent(string(ua)),
+		UserAgent:     string(ua),
+		ClientIP:      c.ClientIP(),
+		LastSeenIP:    c.ClientIP(),
+		LastSeenAt:    now,
+		ExpiresAt:     expiresAt,
+	}
+	if err := session.Insert(db); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// deviceFromUserAgent 根据User-Agent识别设备，用于会话列表展示
+func deviceFromUserAgent(ua string) string {
+	switch {
+	case strings.Contains(ua, "miniProgram") || strings.Contains(ua, "MiniProgramEnv"):
+		return "微信小程序"
+	case strings.Contains(ua, "MicroMessenger"):
+		return "微信"
+	case strings.Contains(ua, "iPhone"):
+		return "iPhone"
+	case strings.Contains(ua, "iPad"):
+		return "iPad"
+	case strings.Contains(ua, "Android"):
+		return "Android"
+	case strings.Contains(ua, "Windows"):
+		return "Windows"
+	case strings.Contains(ua, "Macintosh"):
+		return "Mac"
+	case strings.Contains(ua, "Linux"):
+		return "Linux"
+	}
+	return "未知设备"
+}
+
+// endSession 结束会话并吊销会话签发的全部access token
+func endSession(ctx context.Context, db *gorm.DB, session *models.UserSession) error {
+	if _, err := session.Revoke(db); err != nil {
+		return err
+	}
+	return mw.RevokeSession(ctx, session.SessionID, session.ExpiresAt)
+}
+
+// endSessionByFamily 结束refresh token族对应的会话，会话不存在时视为已注销
+func endSessionByFamily(ctx context.Context, db *gorm.DB, family string) error {
+	session, err := models.GetUserSessionByFamily(db, family)
+	if err == gorm.ErrRecordNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return endSession(ctx, db, session)
+}
+
+//SessionInfo 登录会话信息
+type SessionInfo struct {
+	SessionID    string
+	IdentityType int // 登录使用的身份类型
+	Device       string
+	UserAgent    string
+	ClientIP     string // 登录时的IP
+	LastSeenIP   string
+	CreatedAt    time.Time
+	LastSeenAt   time.Time // 最近访问时间，延迟session.flushinterval写入
+	ExpiresAt    time.Time
+	Current      bool // 是否为当前请求使用的会话
+}
+
+//DescribeSessionsResponse 登录会话列表
+type DescribeSessionsResponse struct {
+	Sessions []*SessionInfo
+}
+
+//DescribeSessions 查询当前用户有效的登录会话，仅支持JWT鉴权
+func DescribeSessions(c *gin.Context) {
+	claims, ok := jwtClaims(c)
+	if !ok {
+		return
+	}
+	db, err := datasource.Gormv2(c.Request.Context())
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	now := time.Now()
+	sessions, err := models.ListActiveUserSessions(db, claims.Uid, now)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+
+	current := c.GetString(protocol.CtxSessionID)
+	resp := &DescribeSessionsResponse{Sessions: make([]*SessionInfo, 0, len(sessions))}
+	for _, s := range sessions {
+		info := &SessionInfo{
+			SessionID:    s.SessionID,
+			IdentityType: s.IdentityType,
+			Device:       s.Device,
+			UserAgent:    s.UserAgent,
+			ClientIP:     s.ClientIP,
+			LastSeenIP:   s.LastSeenIP,
+			CreatedAt:    s.CreatedAt,
+			LastSeenAt:   s.LastSeenAt,
+			ExpiresAt:    s.ExpiresAt,
+			Current:      s.SessionID == current,
+		}
+		if info.Current {
+			info.LastSeenAt, info.LastSeenIP = now, c.ClientIP()
+		}
+		resp.Sessions = append(resp.Sessions, info)
+	}
+	protocol.SetResponse(c, resp)
+}
+
+//RevokeSessionRequest 注销登录会话的请求参数
+type RevokeSessionRequest struct {
+	SessionID string `binding:"required,max=64"`
+}
+
+//RevokeSession 注销当前用户的指定会话，会话的refresh token及已签发的access token立即失效，仅支持JWT鉴权
+// 注销全部会话使用RevokeAllTokens
+func RevokeSession(c *gin.Context) {
+	var req RevokeSessionRequest
+	err := protocol.Bind(c, &req)
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.InvalidParameter(err))
+		return
+	}
+	claims, ok := jwtClaims(c)
+	if !ok {
+		return
+	}
+	db, err := datasource.Gormv2(c.Request.Context())
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	session, err := models.GetUserSession(db, req.SessionID)
+	if err == gorm.ErrRecordNotFound || (err == nil && session.Uid != claims.Uid) {
+		protocol.SetErrResponse(c, protocol.ErrCodeSessionNotFound)
+		return
+	}
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+
+	if err := endSession(c.Request.Context(), db, session); err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", errcode.ErrCodeInternalError))
+		protocol.SetErrResponse(c, errcode.NewCustomError(errcode.ErrCodeInternalError, "注销登录态失败"))
+		return
+	}
+	mw.Audit(c, mw.AuditEventSessionRevoked, session.SessionID)
+	protocol.SetResponse(c, struct{}{})
+}
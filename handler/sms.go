@@ -0,0 +1,212 @@
+package handler
+
+import (
+	"context"
+
+	"ginfra/datasource"
+	"ginfra/errcode"
+	"ginfra/log"
+	mw "ginfra/middleware"
+	"ginfra/models"
+	"ginfra/protocol"
+	"ginfra/sms"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+func init() {
+	// 绑定手机号使用bind用途的短信验证码
+	PhoneCodeVerifier = func(ctx context.Context, phone, code string) (bool, error) {
+		return sms.Verify(ctx, phone, sms.PurposeBind, code)
+	}
+}
+
+//SendSmsCodeRequest 发送短信验证码的请求参数，发送次数较多时需携带图形验证码票据
+type SendSmsCodeRequest struct {
+	Phone          string `binding:"required,phone"`
+	Purpose        string `binding:"required,oneof=login bind"`
+	CaptchaTicket  string `binding:"max=2048"`
+	CaptchaRandstr string `binding:"max=128"`
+}
+
+//SendSmsCode 发送登录或绑定手机号的短信验证码，按手机号及IP限频
+func SendSmsCode(c *gin.Context) {
+	var req SendSmsCodeRequest
+	err := protocol.Bind(c, &req)
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.InvalidParameter(err))
+		return
+	}
+	phone, ok := normalizeIdentifier(models.IdentityTypePhone, req.Phone)
+	if !ok {
+		protocol.SetErrResponse(c, protocol.ErrCodeInvalidParameter)
+		return
+	}
+	sendSmsCode(c, phone, req.Purpose, req.CaptchaTicket, req.CaptchaRandstr)
+}
+
+//SendVerifySmsCodeRequest 发送二次验证短信验证码的请求参数
+type SendVerifySmsCodeRequest struct {
+	CaptchaTicket  string `binding:"max=2048"`
+	CaptchaRandstr string `binding:"max=128"`
+}
+
+//SendVerifySmsCode 向当前用户绑定的手机号发送敏感操作二次验证的短信验证码，仅支持JWT鉴权
+func SendVerifySmsCode(c *gin.Context) {
+	claims, ok := jwtClaims(c)
+	if !ok {
+		return
+	}
+	var req SendVerifySmsCodeRequest
+	err := protocol.Bind(c, &req)
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.InvalidParameter(err))
+		return
+	}
+	phone, ok := linkedPhone(c, claims.Uid)
+	if !ok {
+		return
+	}
+	sendSmsCode(c, phone, sms.PurposeVerify, req.CaptchaTicket, req.CaptchaRandstr)
+}
+
+// sendSmsCode 发送次数较多时先校验图形验证码，再发送短信验证码
+func sendSmsCode(c *gin.Context, phone, purpose, ticket, randstr string) {
+	ctx := c.Request.Context()
+	required, err := sms.CaptchaRequired(ctx, phone, c.ClientIP())
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	if required {
+		if err := mw.VerifyCaptcha(c, ticket, randstr); err != nil {
+			protocol.SetErrResponse(c, err)
+			return
+		}
+	}
+
+	err = sms.Send(ctx, phone, purpose, c.ClientIP())
+	if err == sms.ErrTooFrequent {
+		protocol.SetErrResponse(c, protocol.ErrCodeSmsTooFrequent)
+		return
+	}
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", errcode.ErrCodeInternalError))
+		protocol.SetErrResponse(c, errcode.NewCustomError(errcode.ErrCodeInternalError, "发送验证码失败"))
+		return
+	}
+	protocol.SetResponse(c, struct{}{})
+}
+
+//SmsLoginRequest 短信验证码登录的请求参数
+type SmsLoginRequest struct {
+	Phone string `binding:"required,phone"`
+	Code  string `binding:"required,max=16"`
+}
+
+//SmsLogin 短信验证码登录，手机号未注册时自动注册
+func SmsLogin(c *gin.Context) {
+	var req SmsLoginRequest
+	err := protocol.Bind(c, &req)
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.InvalidParameter(err))
+		return
+	}
+	phone, ok := normalizeIdentifier(models.IdentityTypePhone, req.Phone)
+	if !ok {
+		protocol.SetErrResponse(c, protocol.ErrCodeInvalidParameter)
+		return
+	}
+	if !verifySmsCode(c, phone, sms.PurposeLogin, req.Code) {
+		return
+	}
+
+	db, err := datasource.Gormv2(c.Request.Context())
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	auth, err := models.GetUserAuthByIdentifier(db, models.IdentityTypePhone, phone)
+	if err == gorm.ErrRecordNotFound {
+		auth = &models.UserAuth{
+			Uid:          uint64(uidNode.Generate().Int64()),
+			IdentityType: models.IdentityTypePhone,
+			Identifier:   phone,
+		}
+		err = auth.Insert(db)
+		if err == nil {
+			log.WithGinContext(c).Info("account registered", zap.Uint64("Uid", auth.Uid), zap.String("provider", "sms"))
+		}
+	}
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+
+	pair, err := issueTokenPair(c, db, auth)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", errcode.ErrCodeInternalError))
+		protocol.SetErrResponse(c, errcode.NewCustomError(errcode.ErrCodeInternalError, "生成登录态token失败"))
+		return
+	}
+	protocol.SetResponse(c, pair)
+}
+
+//LinkedPhone 查询当前JWT用户绑定的手机号，供mw.RequireSmsCode校验二次验证的短信验证码
+func LinkedPhone(c *gin.Context) (string, error) {
+	claims, err := getClaimData(c)
+	if err != nil {
+		return "", err
+	}
+	return lookupLinkedPhone(c, claims.Uid)
+}
+
+// linkedPhone 查询用户绑定的手机号，失败时设置错误响应
+func linkedPhone(c *gin.Context, uid uint64) (string, bool) {
+	phone, err := lookupLinkedPhone(c, uid)
+	if err != nil {
+		protocol.SetErrResponse(c, err)
+		return "", false
+	}
+	return phone, true
+}
+
+func lookupLinkedPhone(c *gin.Context, uid uint64) (string, error) {
+	db, err := datasource.Gormv2(c.Request.Context())
+	if err != nil {
+		return "", protocol.ErrCodeDBException
+	}
+	auth, err := models.GetUserAuthByUidAndType(db, uid, models.IdentityTypePhone)
+	if err == gorm.ErrRecordNotFound {
+		return "", protocol.ErrCodePhoneNotLinked
+	}
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		return "", protocol.ErrCodeDBException
+	}
+	return auth.Identifier, nil
+}
+
+// verifySmsCode 校验短信验证码，失败时设置错误响应
+func verifySmsCode(c *gin.Context, phone, purpose, code string) bool {
+	if len(code) == 0 {
+		protocol.SetErrResponse(c, protocol.ErrCodeInvalidVerifyCode)
+		return false
+	}
+	ok, err := sms.Verify(c.Request.Context(), phone, purpose, code)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", errcode.ErrCodeInternalError))
+		protocol.SetErrResponse(c, errcode.NewCustomError(errcode.ErrCodeInternalError, "校验验证码失败"))
+		return false
+	}
+	if !ok {
+		mw.RecordCaptchaFailure(c)
+		protocol.SetErrResponse(c, protocol.ErrCodeInvalidVerifyCode)
+		return false
+	}
+	return true
+}
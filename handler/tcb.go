@@ -15,12 +15,11 @@ import (
 	"ginfra/utils"
 
 	"github.com/gin-gonic/gin"
-	"go.uber.org/zap"
 )
 
 //GetTicketRequest 获取TCB ticket的请求参数
 type GetTicketRequest struct {
-	EnvID string `binding:"required,min=1"`
+	EnvID string `binding:"required,envid"`
 }
 
 //GetTicketResponse 获取TCB ticket的响应参数
@@ -37,10 +36,9 @@ type TcbPrivateKeyData struct {
 //GetTicket 获取TCB ticket
 func GetTicket(c *gin.Context) {
 	var req GetTicketRequest
-	err := c.ShouldBindJSON(&req)
+	err := protocol.Bind(c, &req)
 	if err != nil {
-		log.WithGinContext(c).Error(err.Error(), zap.String("error", errcode.ErrInvalidParam))
-		protocol.SetErrResponse(c, protocol.ErrCodeInvalidParameter)
+		protocol.SetErrResponse(c, protocol.InvalidParameter(err))
 		return
 	}
 
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ginfra/log"
+	mw "ginfra/middleware"
+	"ginfra/protocol"
+	"ginfra/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smartystreets/goconvey/convey"
+	"go.uber.org/zap"
+)
+
+func Test_TenantIsolation(t *testing.T) {
+	oldLogger, oldDomains := log.ZLog, mw.TenantDomains
+	defer func() { log.ZLog, mw.TenantDomains = oldLogger, oldDomains }()
+	log.ZLog = zap.NewNop()
+	mw.TenantDomains = []string{"example.com"}
+
+	// 模拟JWTAuth：设置鉴权方式并由HandleClaims解析claims
+	jwtAuth := func(data *ClaimData) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			b, _ := json.Marshal(data)
+			c.Set(protocol.CtxAuthType, protocol.AuthTypeJWT)
+			if err := HandleClaims(c, &utils.CustomClaims{Data: b}); err != nil {
+				protocol.SetErrResponse(c, protocol.ErrCodeInvalidClaims)
+				c.Abort()
+			}
+		}
+	}
+	do := func(data *ClaimData, host string) string {
+		r := gin.New()
+		r.POST("/", jwtAuth(data), mw.Tenant(), func(c *gin.Context) {
+			c.String(http.StatusOK, "tenant="+protocol.GetCustomerId(c))
+		})
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Host = host
+		r.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+	unauthorized := protocol.ErrCodeUnAuthorized.Code
+
+	convey.Convey("跨租户访问", t, func() {
+		acme := &ClaimData{Uid: 1, CustomerID: "acme", Tenants: []string{"acme"}}
+		convey.So(do(acme, "acme.example.com"), convey.ShouldEqual, "tenant=acme")
+		convey.So(do(acme, "www.example.com"), convey.ShouldEqual, "tenant=acme")
+		convey.So(do(acme, "globex.example.com"), convey.ShouldContainSubstring, unauthorized)
+
+		// 未绑定任何租户的用户不能访问租户子域名
+		nobody := &ClaimData{Uid: 2}
+		convey.So(do(nobody, "acme.example.com"), convey.ShouldContainSubstring, unauthorized)
+		convey.So(do(nobody, "www.example.com"), convey.ShouldEqual, "tenant=")
+
+		// 属于多个租户时按子域名选择
+		multi := &ClaimData{Uid: 3, Tenants: []string{"acme", "globex"}}
+		convey.So(do(multi, "globex.example.com"), convey.ShouldEqual, "tenant=globex")
+		convey.So(do(multi, "initech.example.com"), convey.ShouldContainSubstring, unauthorized)
+	})
+}
@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"time"
+
+	"ginfra/datasource"
+	"ginfra/errcode"
+	"ginfra/log"
+	mw "ginfra/middleware"
+	"ginfra/models"
+	"ginfra/protocol"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+//TokenPair 登录态token对，AccessToken用于接口鉴权，RefreshToken用于换取新的token对
+type TokenPair struct {
+	AccessToken      string
+	ExpiresIn        int64 // AccessToken有效期，单位秒
+	RefreshToken     string
+	RefreshExpiresAt *time.Time
+}
+
+//issueTokenPair 登录成功后签发token对，每次登录创建新的会话及refresh token族，不影响其他设备的会话
+func issueTokenPair(c *gin.Context, db *gorm.DB, s *models.UserAuth) (*TokenPair, error) {
+	family, refreshToken, err := mw.NewRefreshToken("")
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := time.Now().Add(time.Duration(mw.RefreshExpires) * time.Second)
+	session, err := newSession(c, db, s, family, mw.HashRefreshToken(refreshToken), expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return newTokenPair(db, s, refreshToken, session)
+}
+
+// newTokenPair 签发属于会话的access token
+func newTokenPair(db *gorm.DB, s *models.UserAuth, refreshToken string, session *models.UserSession) (*TokenPair, error) {
+	accessToken, jti, err := generateToken(db, s, session.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if err := session.SetTokenID(db, jti); err != nil {
+		return nil, err
+	}
+	expiresAt := session.ExpiresAt
+	return &TokenPair{
+		AccessToken:      accessToken,
+		ExpiresIn:        mw.AccessExpires,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: &expiresAt,
+	}, nil
+}
+
+//RefreshTokenRequest 刷新及注销token的请求参数
+type RefreshTokenRequest struct {
+	RefreshToken string `binding:"required,min=1"`
+}
+
+//RefreshToken 使用refresh token换取新的token对，refresh token每次使用后轮换
+// 已轮换的旧refresh token再次使用时视为泄露，结束该token族所在的会话
+func RefreshToken(c *gin.Context) {
+	var req RefreshTokenRequest
+	err := protocol.Bind(c, &req)
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.InvalidParameter(err))
+		return
+	}
+
+	family, ok := mw.RefreshTokenFamily(req.RefreshToken)
+	if !ok {
+		protocol.SetErrResponse(c, protocol.ErrCodeInvalidRefreshToken)
+		return
+	}
+
+	db, err := datasource.Gormv2(c.Request.Context())
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	session, err := models.GetUserSessionByFamily(db, family)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeInvalidRefreshToken.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeInvalidRefreshToken)
+		return
+	}
+	if session.RevokedAt != nil {
+		protocol.SetErrResponse(c, protocol.ErrCodeInvalidRefreshToken)
+		return
+	}
+
+	hash := mw.HashRefreshToken(req.RefreshToken)
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(session.RefreshToken)) != 1 {
+		// 族标识正确但不是当前token，说明旧token被重放
+		log.WithGinContext(c).Warn("refresh token reused, end session",
+			zap.Uint64("Uid", session.Uid), zap.String("SessionID", session.SessionID))
+		if err := endSession(c.Request.Context(), db, session); err != nil {
+			log.WithGinContext(c).Error(err.Error(), zap.String("error", errcode.ErrCodeInternalError))
+		}
+		protocol.SetErrResponse(c, protocol.ErrCodeInvalidRefreshToken)
+		return
+	}
+	if !session.ExpiresAt.After(time.Now()) {
+		if err := endSession(c.Request.Context(), db, session); err != nil {
+			log.WithGinContext(c).Error(err.Error(), zap.String("error", errcode.ErrCodeInternalError))
+		}
+		protocol.SetErrResponse(c, protocol.ErrCodeExpiredRefreshToken)
+		return
+	}
+	auth, err := models.GetUserAuth(db, session.UserAuthID)
+	if err == gorm.ErrRecordNotFound || (err == nil && auth.Uid != session.Uid) {
+		// 登录使用的身份已解绑或已合并到其他账号
+		if err := endSession(c.Request.Context(), db, session); err != nil {
+			log.WithGinContext(c).Error(err.Error(), zap.String("error", errcode.ErrCodeInternalError))
+		}
+		protocol.SetErrResponse(c, protocol.ErrCodeInvalidRefreshToken)
+		return
+	}
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+
+	_, refreshToken, err := mw.NewRefreshToken(family)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", errcode.ErrCodeInternalError))
+		protocol.SetErrResponse(c, errcode.NewCustomError(errcode.ErrCodeInternalError, "生成refresh token失败"))
+		return
+	}
+	ok, err = session.RotateRefreshToken(db, hash, mw.HashRefreshToken(refreshToken))
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", protocol.ErrCodeDBException.Code))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	if !ok {
+		// 并发刷新，已被其他请求轮换
+		protocol.SetErrResponse(c, protocol.ErrCodeInvalidRefreshToken)
+		return
+	}
+
+	pair, err := newTokenPair(db, auth, refreshToken, session)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", errcode.ErrCodeInternalError))
+		protocol.SetErrResponse(c, errcode.NewCustomError(errcode.ErrCodeInternalError, "生成登录态token失败"))
+		return
+	}
+	protocol.SetResponse(c, pair)
+}
+
+//Logout 注销登录，结束refresh token族对应的会话，会话签发的access token同时失效，其他设备的会话不受影响
+func Logout(c *gin.Context) {
+	var req RefreshTokenRequest
+	err := protocol.Bind(c, &req)
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.InvalidParameter(err))
+		return
+	}
+
+	family, ok := mw.RefreshTokenFamily(req.RefreshToken)
+	if !ok {
+		protocol.SetErrResponse(c, protocol.ErrCodeInvalidRefreshToken)
+		return
+	}
+
+	db, err := datasource.Gormv2(c.Request.Context())
+	if err != nil {
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return
+	}
+	err = endSessionByFamily(c.Request.Context(), db, family)
+	if err != nil {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", errcode.ErrCodeInternalError))
+		protocol.SetErrResponse(c, errcode.NewCustomError(errcode.ErrCodeInternalError, "注销登录态失败"))
+		return
+	}
+	protocol.SetResponse(c, struct{}{})
+}
@@ -27,8 +27,7 @@ func Upload(c *gin.Context) {
 	var req UploadRequest
 	err := c.ShouldBind(&req)
 	if err != nil {
-		log.WithGinContext(c).Error(err.Error(), zap.String("error", errcode.ErrInvalidParam))
-		protocol.SetErrResponse(c, protocol.ErrCodeInvalidParameter)
+		protocol.SetErrResponse(c, protocol.InvalidParameter(err))
 		return
 	}
 
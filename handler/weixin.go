@@ -71,7 +71,7 @@ func WXMsgReceive(c *gin.Context) {
 	}
 	b, _ := json.Marshal(lb)
 
-	tencent.InsertDocuments("env-id", "test-collection", [][]byte{b})
+	tencent.InsertDocuments(c.Request.Context(), "env-id", "test-collection", [][]byte{b})
 }
 
 //LiveBullet 示例
@@ -108,7 +108,7 @@ func JsonMsgReceive(c *gin.Context) {
 	}
 	b, _ := json.Marshal(lb)
 
-	ids, err := tencent.InsertDocuments("env-id", "test-collection", [][]byte{b})
+	ids, err := tencent.InsertDocuments(c.Request.Context(), "env-id", "test-collection", [][]byte{b})
 	if err != nil {
 		protocol.SetErrResponse(c, err)
 		return
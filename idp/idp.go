@@ -0,0 +1,129 @@
+package idp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"ginfra/config"
+)
+
+//ErrInvalidCode 第三方登录code无效或已使用
+var ErrInvalidCode = errors.New("invalid authorization code")
+
+//Token 使用code换取的第三方登录态
+type Token struct {
+	AccessToken string
+	Openid      string
+	Unionid     string
+	ExpiresIn   int
+}
+
+//Profile 第三方平台的用户信息
+type Profile struct {
+	Openid   string
+	Unionid  string
+	Nickname string
+	Avatar   string
+}
+
+//IdentityProvider 第三方登录平台
+type IdentityProvider interface {
+	// Name 平台名称，对应 /auth/{provider}/callback
+	Name() string
+	// IdentityType 对应UserAuth.IdentityType
+	IdentityType() int
+	// Exchange 使用授权code换取第三方登录态，code无效时返回ErrInvalidCode
+	Exchange(ctx context.Context, code string) (*Token, error)
+	// Profile 获取用户信息
+	Profile(ctx context.Context, token *Token) (*Profile, error)
+}
+
+//Redirector 网页授权的第三方平台，需先跳转到授权页面，回调时校验state
+type Redirector interface {
+	AuthURL(state string) string
+}
+
+//ProviderConfig 第三方平台配置
+type ProviderConfig struct {
+	AppID       string
+	Secret      string
+	RedirectURI string // 网页授权回调地址，需与平台登记的一致
+	AuthURL     string // 网页授权页面地址，为空时使用平台默认地址
+	Scope       string
+}
+
+var (
+	mu        sync.RWMutex
+	providers = make(map[string]IdentityProvider)
+)
+
+// 配置名及对应的创建函数
+var factories = map[string]func(ProviderConfig) IdentityProvider{
+	"wxmini": NewWeixinMiniProvider,
+	"wxopen": NewWeixinOpenProvider,
+	"qq":     NewQQProvider,
+	"seewo":  NewSeewoProvider,
+}
+
+//Init 加载idp配置，注册已配置AppID的第三方平台
+func Init(cfg *config.Config) error {
+	var cfgs map[string]ProviderConfig
+	if err := cfg.UnmarshalKey("idp", &cfgs); err != nil {
+		return err
+	}
+	for name, pc := range cfgs {
+		factory, ok := factories[name]
+		if !ok {
+			return fmt.Errorf("unknown identity provider: %s", name)
+		}
+		if len(pc.AppID) == 0 {
+			continue
+		}
+		Register(factory(pc))
+	}
+	return nil
+}
+
+// authURL 拼接授权页面地址，未配置时使用平台默认地址
+func authURL(configured, def string, params url.Values) string {
+	if len(configured) == 0 {
+		configured = def
+	}
+	sep := "?"
+	if strings.Contains(configured, "?") {
+		sep = "&"
+	}
+	return configured + sep + params.Encode()
+}
+
+//Register 注册第三方平台，同名覆盖
+func Register(p IdentityProvider) {
+	mu.Lock()
+	defer mu.Unlock()
+	providers[p.Name()] = p
+}
+
+//Get 按名称获取第三方平台
+func Get(name string) (IdentityProvider, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	p, ok := providers[name]
+	return p, ok
+}
+
+//Names 已注册的第三方平台名称
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
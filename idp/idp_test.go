@@ -0,0 +1,53 @@
+package idp
+
+import (
+	"net/url"
+	"testing"
+
+	"ginfra/models"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func Test_AuthURL(t *testing.T) {
+	convey.Convey("qq AuthURL", t, func() {
+		p := NewQQProvider(ProviderConfig{AppID: "101", RedirectURI: "https://www.qq.com/auth/qq/callback"})
+		convey.So(p.IdentityType(), convey.ShouldEqual, models.IdentityTypeQQ)
+		u, err := url.Parse(p.(Redirector).AuthURL("s1"))
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(u.Host, convey.ShouldEqual, "graph.qq.com")
+		convey.So(u.Query().Get("client_id"), convey.ShouldEqual, "101")
+		convey.So(u.Query().Get("redirect_uri"), convey.ShouldEqual, "https://www.qq.com/auth/qq/callback")
+		convey.So(u.Query().Get("scope"), convey.ShouldEqual, "get_user_info")
+		convey.So(u.Query().Get("state"), convey.ShouldEqual, "s1")
+	})
+
+	convey.Convey("wxopen AuthURL", t, func() {
+		p := NewWeixinOpenProvider(ProviderConfig{AppID: "wx1", AuthURL: "https://example.com/auth?x=1"})
+		u, err := url.Parse(p.(Redirector).AuthURL("s2"))
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(u.Host, convey.ShouldEqual, "example.com")
+		convey.So(u.Fragment, convey.ShouldEqual, "wechat_redirect")
+		convey.So(u.Query().Get("x"), convey.ShouldEqual, "1")
+		convey.So(u.Query().Get("appid"), convey.ShouldEqual, "wx1")
+		convey.So(u.Query().Get("scope"), convey.ShouldEqual, "snsapi_login")
+	})
+
+	convey.Convey("wxmini is not Redirector", t, func() {
+		_, ok := NewWeixinMiniProvider(ProviderConfig{}).(Redirector)
+		convey.So(ok, convey.ShouldBeFalse)
+	})
+}
+
+func Test_Register(t *testing.T) {
+	convey.Convey("Register and Get", t, func() {
+		Register(NewSeewoProvider(ProviderConfig{AppID: "sw"}))
+		p, ok := Get("seewo")
+		convey.So(ok, convey.ShouldBeTrue)
+		convey.So(p.IdentityType(), convey.ShouldEqual, models.IdentityTypeSeewo)
+		convey.So(Names(), convey.ShouldContain, "seewo")
+
+		_, ok = Get("unknown")
+		convey.So(ok, convey.ShouldBeFalse)
+	})
+}
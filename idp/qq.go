@@ -0,0 +1,67 @@
+package idp
+
+import (
+	"context"
+	"net/url"
+
+	"ginfra/models"
+	"ginfra/tencent"
+)
+
+// qqProvider QQ互联网站应用登录
+type qqProvider struct {
+	cfg ProviderConfig
+}
+
+//NewQQProvider QQ互联网站应用登录
+func NewQQProvider(cfg ProviderConfig) IdentityProvider {
+	if len(cfg.Scope) == 0 {
+		cfg.Scope = "get_user_info"
+	}
+	return &qqProvider{cfg: cfg}
+}
+
+func (p *qqProvider) Name() string {
+	return "qq"
+}
+
+func (p *qqProvider) IdentityType() int {
+	return models.IdentityTypeQQ
+}
+
+func (p *qqProvider) AuthURL(state string) string {
+	return authURL(p.cfg.AuthURL, "https://graph.qq.com/oauth2.0/authorize", url.Values{
+		"client_id":     {p.cfg.AppID},
+		"redirect_uri":  {p.cfg.RedirectURI},
+		"response_type": {"code"},
+		"scope":         {p.cfg.Scope},
+		"state":         {state},
+	})
+}
+
+func (p *qqProvider) Exchange(ctx context.Context, code string) (*Token, error) {
+	// QQ互联code无效时只返回错误描述，统一按code无效处理
+	resp, err := tencent.QQConnectToken(ctx, p.cfg.AppID, p.cfg.Secret,
+		url.QueryEscape(p.cfg.RedirectURI), url.QueryEscape(code))
+	if err != nil {
+		return nil, ErrInvalidCode
+	}
+	openid, err := tencent.QQConnectOpenID(ctx, resp.AccessToken)
+	if err != nil {
+		return nil, err
+	}
+	return &Token{AccessToken: resp.AccessToken, Openid: openid.OpenID, Unionid: openid.UnionID}, nil
+}
+
+func (p *qqProvider) Profile(ctx context.Context, token *Token) (*Profile, error) {
+	info, err := tencent.QQConnectUserInfo(ctx, p.cfg.AppID, token.AccessToken, token.Openid)
+	if err != nil {
+		return nil, err
+	}
+	return &Profile{
+		Openid:   token.Openid,
+		Unionid:  token.Unionid,
+		Nickname: info.NickName,
+		Avatar:   info.FigureUrl,
+	}, nil
+}
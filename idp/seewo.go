@@ -0,0 +1,69 @@
+package idp
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"ginfra/models"
+	"ginfra/plugin/seewo"
+)
+
+// seewoProvider 希沃开放平台登录
+type seewoProvider struct {
+	cfg ProviderConfig
+}
+
+//NewSeewoProvider 希沃开放平台登录
+func NewSeewoProvider(cfg ProviderConfig) IdentityProvider {
+	return &seewoProvider{cfg: cfg}
+}
+
+func (p *seewoProvider) Name() string {
+	return "seewo"
+}
+
+func (p *seewoProvider) IdentityType() int {
+	return models.IdentityTypeSeewo
+}
+
+func (p *seewoProvider) AuthURL(state string) string {
+	// 授权页面地址需按开放平台文档配置
+	return authURL(p.cfg.AuthURL, "", url.Values{
+		"app_id":        {p.cfg.AppID},
+		"redirect_uri":  {p.cfg.RedirectURI},
+		"response_type": {"code"},
+		"scope":         {p.cfg.Scope},
+		"state":         {state},
+	})
+}
+
+func (p *seewoProvider) Exchange(ctx context.Context, code string) (*Token, error) {
+	resp, err := seewo.GetSeeWoAccessToken(ctx, p.cfg.AppID, p.cfg.Secret, url.QueryEscape(code))
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Body.AccessToken) == 0 || len(resp.Body.OpenId) == 0 {
+		if len(resp.Code) > 0 {
+			return nil, fmt.Errorf("%w: seewo %s %s", ErrInvalidCode, resp.Code, resp.Msg)
+		}
+		return nil, ErrInvalidCode
+	}
+	return &Token{
+		AccessToken: resp.Body.AccessToken,
+		Openid:      resp.Body.OpenId,
+		ExpiresIn:   resp.Body.ExpiresIn,
+	}, nil
+}
+
+func (p *seewoProvider) Profile(ctx context.Context, token *Token) (*Profile, error) {
+	info, err := seewo.GetSeeWoUserInfo(ctx, p.cfg.AppID, p.cfg.Secret, token.AccessToken, token.Openid)
+	if err != nil {
+		return nil, err
+	}
+	return &Profile{
+		Openid:   token.Openid,
+		Nickname: info.NickName,
+		Avatar:   info.PhotoUrl,
+	}, nil
+}
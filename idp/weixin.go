@@ -0,0 +1,122 @@
+package idp
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"ginfra/models"
+	"ginfra/tencent"
+)
+
+// 微信code无效、已使用或过期的错误码
+var wxInvalidCodeErrors = map[int]bool{40029: true, 40163: true, 41008: true}
+
+func wxError(errcode int, errmsg string) error {
+	if wxInvalidCodeErrors[errcode] {
+		return ErrInvalidCode
+	}
+	return fmt.Errorf("weixin error %d: %s", errcode, errmsg)
+}
+
+// weixinMiniProvider 微信小程序登录，code由wx.login获取
+type weixinMiniProvider struct {
+	cfg ProviderConfig
+}
+
+//NewWeixinMiniProvider 微信小程序登录
+func NewWeixinMiniProvider(cfg ProviderConfig) IdentityProvider {
+	return &weixinMiniProvider{cfg: cfg}
+}
+
+func (p *weixinMiniProvider) Name() string {
+	return "wxmini"
+}
+
+func (p *weixinMiniProvider) IdentityType() int {
+	return models.IdentityTypeWeixinMini
+}
+
+func (p *weixinMiniProvider) Exchange(ctx context.Context, code string) (*Token, error) {
+	resp, err := tencent.WxCode2Session(ctx, p.cfg.AppID, p.cfg.Secret, url.QueryEscape(code))
+	if err != nil {
+		return nil, err
+	}
+	if resp.ErrCode != 0 {
+		return nil, wxError(resp.ErrCode, resp.ErrMsg)
+	}
+	// session_key不下发也不保存
+	return &Token{Openid: resp.OpenId, Unionid: resp.UnionId}, nil
+}
+
+// Profile 小程序只能在客户端获取昵称头像
+func (p *weixinMiniProvider) Profile(ctx context.Context, token *Token) (*Profile, error) {
+	return &Profile{Openid: token.Openid, Unionid: token.Unionid}, nil
+}
+
+// weixinOpenProvider 微信开放平台网站应用登录
+type weixinOpenProvider struct {
+	cfg ProviderConfig
+}
+
+//NewWeixinOpenProvider 微信开放平台网站应用登录
+func NewWeixinOpenProvider(cfg ProviderConfig) IdentityProvider {
+	if len(cfg.Scope) == 0 {
+		cfg.Scope = "snsapi_login"
+	}
+	return &weixinOpenProvider{cfg: cfg}
+}
+
+func (p *weixinOpenProvider) Name() string {
+	return "wxopen"
+}
+
+func (p *weixinOpenProvider) IdentityType() int {
+	return models.IdentityTypeWeixin
+}
+
+func (p *weixinOpenProvider) AuthURL(state string) string {
+	return authURL(p.cfg.AuthURL, "https://open.weixin.qq.com/connect/qrconnect", url.Values{
+		"appid":         {p.cfg.AppID},
+		"redirect_uri":  {p.cfg.RedirectURI},
+		"response_type": {"code"},
+		"scope":         {p.cfg.Scope},
+		"state":         {state},
+	}) + "#wechat_redirect"
+}
+
+func (p *weixinOpenProvider) Exchange(ctx context.Context, code string) (*Token, error) {
+	resp, err := tencent.GetWxOpenAccessToken(ctx, p.cfg.AppID, p.cfg.Secret, url.QueryEscape(code))
+	if err != nil {
+		return nil, err
+	}
+	if resp.Errcode != 0 {
+		return nil, wxError(resp.Errcode, resp.Errmsg)
+	}
+	return &Token{
+		AccessToken: resp.Access_Token,
+		Openid:      resp.Openid,
+		Unionid:     resp.Unionid,
+		ExpiresIn:   resp.Expires_In,
+	}, nil
+}
+
+func (p *weixinOpenProvider) Profile(ctx context.Context, token *Token) (*Profile, error) {
+	info, err := tencent.GetWxOpenUserInfo(ctx, token.AccessToken, token.Openid)
+	if err != nil {
+		return nil, err
+	}
+	if info.Errcode != 0 {
+		return nil, wxError(info.Errcode, info.Errmsg)
+	}
+	unionid := info.Unionid
+	if len(unionid) == 0 {
+		unionid = token.Unionid
+	}
+	return &Profile{
+		Openid:   token.Openid,
+		Unionid:  unionid,
+		Nickname: info.Nickname,
+		Avatar:   info.Headimgurl,
+	}, nil
+}
@@ -0,0 +1,171 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	netmail "net/mail"
+	"strings"
+	"time"
+
+	"ginfra/config"
+	"ginfra/datasource"
+	"ginfra/log"
+	"ginfra/models"
+
+	"go.uber.org/zap"
+)
+
+//QueueConfig 发送队列配置
+type QueueConfig struct {
+	Interval    time.Duration // 扫描队列的间隔，0表示不启动发送协程
+	Batch       int           // 每次最多发送的邮件数
+	MaxAttempts int           // 最多投递次数，用完后标记为failed
+	Backoff     time.Duration // 首次重试的等待时间，之后每次翻倍
+	StaleAfter  time.Duration // 发送中超过该时间未完成(实例异常退出)时重新投递
+}
+
+//Config 邮件服务配置
+type Config struct {
+	Transport string // smtp投递，log仅记录日志用于开发测试
+	From      string // 默认发件人
+	SMTP      SMTPConfig
+	Queue     QueueConfig
+}
+
+var (
+	cfg = Config{
+		Transport: "smtp",
+		SMTP:      SMTPConfig{Port: 465, TLS: TLSImplicit, Timeout: 30 * time.Second},
+		Queue: QueueConfig{
+			Interval:    5 * time.Second,
+			Batch:       20,
+			MaxAttempts: 5,
+			Backoff:     30 * time.Second,
+			StaleAfter:  10 * time.Minute,
+		},
+	}
+	transport Transport
+)
+
+//Init 加载mail配置，创建投递方式并启动发送协程
+func Init(c *config.Config) error {
+	if err := c.UnmarshalKey("mail", &cfg); err != nil {
+		return err
+	}
+	switch cfg.Transport {
+	case "smtp":
+		t, err := NewSMTPTransport(cfg.SMTP)
+		if err != nil {
+			return err
+		}
+		transport = t
+	case "log":
+		transport = NewLogTransport()
+	default:
+		return fmt.Errorf("unknown mail transport: %s", cfg.Transport)
+	}
+	if cfg.Queue.Interval > 0 {
+		go func() {
+			for range time.Tick(cfg.Queue.Interval) {
+				ProcessQueue(context.Background())
+			}
+		}()
+	}
+	return nil
+}
+
+//SetTransport 替换投递方式，用于测试或接入其他邮件服务商
+func SetTransport(t Transport) {
+	transport = t
+}
+
+//Send 生成MIME邮件并加入发送队列，由发送协程异步投递及重试
+func Send(ctx context.Context, msg *Message) error {
+	if len(msg.From) == 0 {
+		msg.From = cfg.From
+	}
+	from, err := netmail.ParseAddress(msg.From)
+	if err != nil {
+		return fmt.Errorf("invalid from %q: %w", msg.From, err)
+	}
+	rcpts, err := msg.Recipients()
+	if err != nil {
+		return err
+	}
+	raw, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+	db, err := datasource.Gormv2(ctx)
+	if err != nil {
+		return err
+	}
+	job := &models.MailJob{
+		From:          from.Address,
+		Recipients:    strings.Join(rcpts, ","),
+		Subject:       msg.Subject,
+		Raw:           raw,
+		Status:        models.MailStatusPending,
+		NextAttemptAt: time.Now(),
+	}
+	return job.Insert(db)
+}
+
+//SendTemplate 渲染模板并加入发送队列
+func SendTemplate(ctx context.Context, to []string, name string, data interface{}) error {
+	content, err := Render(ctx, name, data)
+	if err != nil {
+		return err
+	}
+	return Send(ctx, &Message{
+		To:      to,
+		Subject: content.Subject,
+		Text:    content.Text,
+		HTML:    content.HTML,
+	})
+}
+
+//ProcessQueue 投递到期的邮件，失败时按指数退避重试，多实例部署时每封邮件只由一个实例投递
+func ProcessQueue(ctx context.Context) {
+	db, err := datasource.Gormv2(ctx)
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	staleBefore := now.Add(-cfg.Queue.StaleAfter)
+	jobs, err := models.ListDueMailJobs(db, now, staleBefore, cfg.Queue.Batch)
+	if err != nil {
+		log.WithContext(ctx).Error("list mail jobs fail", zap.String("error", err.Error()))
+		return
+	}
+	for _, job := range jobs {
+		claimed, err := job.Claim(db, staleBefore)
+		if err != nil {
+			log.WithContext(ctx).Error("claim mail job fail", zap.Uint("id", job.ID), zap.String("error", err.Error()))
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		sendErr := transport.Send(ctx, job.From, strings.Split(job.Recipients, ","), job.Raw)
+		if sendErr == nil {
+			err = job.MarkSent(db)
+		} else {
+			log.WithContext(ctx).Warn("send mail fail", zap.Uint("id", job.ID), zap.Int("attempts", job.Attempts+1),
+				zap.String("error", sendErr.Error()))
+			err = job.MarkFailed(db, sendErr, nextAttempt(time.Now(), job.Attempts+1))
+		}
+		if err != nil {
+			log.WithContext(ctx).Error("update mail job fail", zap.Uint("id", job.ID), zap.String("error", err.Error()))
+		}
+	}
+}
+
+// nextAttempt 第attempts次投递失败后的重试时间，次数用完时返回零值
+func nextAttempt(now time.Time, attempts int) time.Time {
+	if attempts >= cfg.Queue.MaxAttempts {
+		return time.Time{}
+	}
+	return now.Add(cfg.Queue.Backoff << uint(attempts-1))
+}
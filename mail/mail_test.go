@@ -0,0 +1,334 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"io/ioutil"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"net"
+	netmail "net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+// smtpServer 测试用的SMTP服务器，支持STARTTLS、implicit TLS及AUTH PLAIN
+type smtpServer struct {
+	ln        net.Listener
+	tlsConfig *tls.Config
+	implicit  bool
+
+	mu     sync.Mutex
+	from   string
+	rcpts  []string
+	data   []byte
+	authed bool
+	tls    bool
+}
+
+func newSMTPServer(t *testing.T, tlsConfig *tls.Config, implicit bool) *smtpServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if implicit {
+		ln = tls.NewListener(ln, tlsConfig)
+	}
+	s := &smtpServer{ln: ln, tlsConfig: tlsConfig, implicit: implicit}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.handle(conn)
+		}
+	}()
+	return s
+}
+
+func (s *smtpServer) port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+func (s *smtpServer) handle(conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	isTLS := s.implicit
+	tp.PrintfLine("220 localhost ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		s.mu.Lock()
+		switch strings.ToUpper(fields[0]) {
+		case "EHLO", "HELO":
+			tp.PrintfLine("250-localhost")
+			if !isTLS && s.tlsConfig != nil {
+				tp.PrintfLine("250-STARTTLS")
+			}
+			tp.PrintfLine("250 AUTH PLAIN")
+		case "STARTTLS":
+			tp.PrintfLine("220 ready")
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				s.mu.Unlock()
+				return
+			}
+			conn, tp, isTLS = tlsConn, textproto.NewConn(tlsConn), true
+		case "AUTH":
+			b, _ := base64.StdEncoding.DecodeString(fields[len(fields)-1])
+			if string(b) == "\x00user\x00secret" {
+				s.authed = true
+				tp.PrintfLine("235 ok")
+			} else {
+				tp.PrintfLine("535 auth failed")
+			}
+		case "MAIL":
+			s.from = strings.Trim(strings.TrimPrefix(line[5:], "FROM:"), "<>")
+			s.tls = isTLS
+			tp.PrintfLine("250 ok")
+		case "RCPT":
+			s.rcpts = append(s.rcpts, strings.Trim(strings.TrimPrefix(line[5:], "TO:"), "<>"))
+			tp.PrintfLine("250 ok")
+		case "DATA":
+			tp.PrintfLine("354 go ahead")
+			s.mu.Unlock()
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.data = data
+			tp.PrintfLine("250 ok")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			s.mu.Unlock()
+			return
+		default:
+			tp.PrintfLine("250 ok")
+		}
+		s.mu.Unlock()
+	}
+}
+
+// selfSignedTLS 生成127.0.0.1的自签名证书，返回服务端配置及信任该证书的客户端配置
+func selfSignedTLS(t *testing.T) (*tls.Config, *tls.Config) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	server := &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	client := &tls.Config{ServerName: "127.0.0.1", RootCAs: pool}
+	return server, client
+}
+
+func testMessage() *Message {
+	return &Message{
+		From:    "发件人 <noreply@example.com>",
+		To:      []string{"a@example.com", "张三 <b@example.com>"},
+		Bcc:     []string{"c@example.com"},
+		Subject: "验证邮箱",
+		Text:    "hello text",
+		HTML:    "<p>hello html</p>",
+		Attachments: []Attachment{
+			{Filename: "报告.txt", Data: bytes.Repeat([]byte("0123456789"), 20)},
+		},
+	}
+}
+
+func Test_MessageBytes(t *testing.T) {
+	convey.Convey("multipart with attachment", t, func() {
+		m := testMessage()
+		raw, err := m.Bytes()
+		convey.So(err, convey.ShouldBeNil)
+
+		msg, err := netmail.ReadMessage(bytes.NewReader(raw))
+		convey.So(err, convey.ShouldBeNil)
+		subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+		convey.So(subject, convey.ShouldEqual, "验证邮箱")
+		convey.So(msg.Header.Get("Bcc"), convey.ShouldBeEmpty)
+		to, err := msg.Header.AddressList("To")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(to[1].Name, convey.ShouldEqual, "张三")
+
+		mediaType, params, _ := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+		convey.So(mediaType, convey.ShouldEqual, "multipart/mixed")
+		mr := multipart.NewReader(msg.Body, params["boundary"])
+
+		body, err := mr.NextPart()
+		convey.So(err, convey.ShouldBeNil)
+		mediaType, params, _ = mime.ParseMediaType(body.Header.Get("Content-Type"))
+		convey.So(mediaType, convey.ShouldEqual, "multipart/alternative")
+		alt := multipart.NewReader(body, params["boundary"])
+		text, _ := alt.NextPart()
+		b, _ := ioutil.ReadAll(text)
+		convey.So(string(b), convey.ShouldEqual, "hello text")
+		html, _ := alt.NextPart()
+		b, _ = ioutil.ReadAll(html)
+		convey.So(string(b), convey.ShouldEqual, "<p>hello html</p>")
+
+		att, err := mr.NextPart()
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(att.FileName(), convey.ShouldEqual, "报告.txt")
+		encoded, _ := ioutil.ReadAll(att)
+		for _, line := range strings.Split(strings.TrimSpace(string(encoded)), "\r\n") {
+			convey.So(len(line), convey.ShouldBeLessThanOrEqualTo, 76)
+		}
+		data, err := base64.StdEncoding.DecodeString(strings.Replace(string(encoded), "\r\n", "", -1))
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(data, convey.ShouldResemble, m.Attachments[0].Data)
+
+		rcpts, err := m.Recipients()
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(rcpts, convey.ShouldResemble, []string{"a@example.com", "b@example.com", "c@example.com"})
+	})
+
+	convey.Convey("single part", t, func() {
+		raw, err := (&Message{From: "noreply@example.com", To: []string{"a@example.com"}, Subject: "s", Text: "only text"}).Bytes()
+		convey.So(err, convey.ShouldBeNil)
+		msg, _ := netmail.ReadMessage(bytes.NewReader(raw))
+		convey.So(msg.Header.Get("Content-Type"), convey.ShouldEqual, "text/plain; charset=UTF-8")
+	})
+
+	convey.Convey("header injection", t, func() {
+		raw, err := (&Message{From: "noreply@example.com", To: []string{"a@example.com"},
+			Subject: "s\r\nBcc: evil@example.com", Text: "t"}).Bytes()
+		convey.So(err, convey.ShouldBeNil)
+		msg, _ := netmail.ReadMessage(bytes.NewReader(raw))
+		convey.So(msg.Header.Get("Bcc"), convey.ShouldBeEmpty)
+	})
+
+	convey.Convey("invalid", t, func() {
+		_, err := (&Message{From: "noreply@example.com", To: []string{"a@example.com"}}).Bytes()
+		convey.So(err, convey.ShouldNotBeNil)
+		_, err = (&Message{From: "noreply@example.com"}).Recipients()
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+}
+
+func Test_SMTPTransport(t *testing.T) {
+	serverTLS, clientTLS := selfSignedTLS(t)
+	raw, err := testMessage().Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	rcpts := []string{"a@example.com", "b@example.com"}
+
+	for _, mode := range []string{TLSNone, TLSStartTLS, TLSImplicit} {
+		mode := mode
+		convey.Convey("tls "+mode, t, func() {
+			var s *smtpServer
+			switch mode {
+			case TLSNone:
+				s = newSMTPServer(t, nil, false)
+			case TLSStartTLS:
+				s = newSMTPServer(t, serverTLS, false)
+			case TLSImplicit:
+				s = newSMTPServer(t, serverTLS, true)
+			}
+			defer s.ln.Close()
+
+			tr, err := NewSMTPTransport(SMTPConfig{Host: "127.0.0.1", Port: s.port(), Username: "user",
+				Password: "secret", TLS: mode, Timeout: 5 * time.Second})
+			convey.So(err, convey.ShouldBeNil)
+			tr.(*smtpTransport).tlsConfig = clientTLS
+			convey.So(tr.Send(ctx, "noreply@example.com", rcpts, raw), convey.ShouldBeNil)
+
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			convey.So(s.authed, convey.ShouldBeTrue)
+			convey.So(s.tls, convey.ShouldEqual, mode != TLSNone)
+			convey.So(s.from, convey.ShouldEqual, "noreply@example.com")
+			convey.So(s.rcpts, convey.ShouldResemble, rcpts)
+			convey.So(string(s.data), convey.ShouldContainSubstring, "hello html")
+		})
+	}
+
+	convey.Convey("starttls required", t, func() {
+		s := newSMTPServer(t, nil, false)
+		defer s.ln.Close()
+		tr, _ := NewSMTPTransport(SMTPConfig{Host: "127.0.0.1", Port: s.port(), TLS: TLSStartTLS})
+		convey.So(tr.Send(ctx, "noreply@example.com", rcpts, raw), convey.ShouldNotBeNil)
+	})
+
+	convey.Convey("unknown tls mode", t, func() {
+		_, err := NewSMTPTransport(SMTPConfig{TLS: "ssl"})
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+
+	convey.Convey("connection refused", t, func() {
+		ln, _ := net.Listen("tcp", "127.0.0.1:0")
+		port := ln.Addr().(*net.TCPAddr).Port
+		ln.Close()
+		tr, _ := NewSMTPTransport(SMTPConfig{Host: "127.0.0.1", Port: port, TLS: TLSNone})
+		err := tr.Send(ctx, "noreply@example.com", rcpts, raw)
+		convey.So(err, convey.ShouldNotBeNil)
+		convey.So(err.Error(), convey.ShouldContainSubstring, strconv.Itoa(port))
+	})
+}
+
+func Test_Render(t *testing.T) {
+	ctx := context.Background()
+	convey.Convey("builtin templates", t, func() {
+		content, err := Render(ctx, TemplateResetPassword, &ResetPasswordData{
+			Identifier: "<a@example.com>", Link: "https://www.qq.com/reset?token=x&y=1", Expires: "30分钟"})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(content.Subject, convey.ShouldEqual, "找回密码")
+		convey.So(content.Text, convey.ShouldContainSubstring, "https://www.qq.com/reset?token=x&y=1")
+		convey.So(content.HTML, convey.ShouldContainSubstring, "&lt;a@example.com&gt;")
+		convey.So(content.HTML, convey.ShouldContainSubstring, `href="https://www.qq.com/reset?token=x&amp;y=1"`)
+
+		_, err = Render(ctx, TemplateVerifyEmail, &VerifyEmailData{Email: "a@example.com", Link: "l", Expires: "24小时"})
+		convey.So(err, convey.ShouldBeNil)
+	})
+	convey.Convey("missing template", t, func() {
+		_, err := Render(ctx, "not_exist", nil)
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+}
+
+func Test_NextAttempt(t *testing.T) {
+	now := time.Now()
+	convey.Convey("exponential backoff", t, func() {
+		convey.So(nextAttempt(now, 1), convey.ShouldEqual, now.Add(cfg.Queue.Backoff))
+		convey.So(nextAttempt(now, 3), convey.ShouldEqual, now.Add(4*cfg.Queue.Backoff))
+		convey.So(nextAttempt(now, cfg.Queue.MaxAttempts).IsZero(), convey.ShouldBeTrue)
+	})
+}
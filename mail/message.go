@@ -0,0 +1,280 @@
+package mail
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	netmail "net/mail"
+	"net/textproto"
+	"sort"
+	"strings"
+	"time"
+)
+
+//Attachment 邮件附件
+type Attachment struct {
+	Filename    string
+	ContentType string // 为空时按文件扩展名推断
+	Data        []byte
+}
+
+//Message 邮件，Text、HTML至少需要一个，同时提供时客户端优先展示HTML
+type Message struct {
+	From        string // 为空时使用mail.from配置
+	To          []string
+	Cc          []string
+	Bcc         []string // 仅用于投递，不写入邮件头
+	ReplyTo     string
+	Subject     string
+	Text        string
+	HTML        string
+	Attachments []Attachment
+	Headers     map[string]string // 自定义邮件头
+}
+
+//Recipients 投递的全部收件人地址，不含显示名称
+func (m *Message) Recipients() ([]string, error) {
+	var rcpts []string
+	for _, list := range [][]string{m.To, m.Cc, m.Bcc} {
+		for _, a := range list {
+			addr, err := netmail.ParseAddress(a)
+			if err != nil {
+				return nil, fmt.Errorf("invalid recipient %q: %w", a, err)
+			}
+			rcpts = append(rcpts, addr.Address)
+		}
+	}
+	if len(rcpts) == 0 {
+		return nil, errors.New("no recipients")
+	}
+	return rcpts, nil
+}
+
+// formatAddressList 格式化地址列表，非ASCII显示名称按RFC 2047编码
+func formatAddressList(list []string) (string, error) {
+	formatted := make([]string, 0, len(list))
+	for _, a := range list {
+		addr, err := netmail.ParseAddress(a)
+		if err != nil {
+			return "", fmt.Errorf("invalid address %q: %w", a, err)
+		}
+		formatted = append(formatted, addr.String())
+	}
+	return strings.Join(formatted, ", "), nil
+}
+
+//Bytes 生成MIME邮件，有附件时为multipart/mixed，同时有Text及HTML时为multipart/alternative
+func (m *Message) Bytes() ([]byte, error) {
+	if len(m.Text) == 0 && len(m.HTML) == 0 {
+		return nil, errors.New("empty mail body")
+	}
+	from, err := netmail.ParseAddress(m.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid from %q: %w", m.From, err)
+	}
+
+	var buf bytes.Buffer
+	writeHeader(&buf, "From", from.String())
+	for _, h := range []struct {
+		key  string
+		list []string
+	}{{"To", m.To}, {"Cc", m.Cc}} {
+		if len(h.list) == 0 {
+			continue
+		}
+		v, err := formatAddressList(h.list)
+		if err != nil {
+			return nil, err
+		}
+		writeHeader(&buf, h.key, v)
+	}
+	if len(m.ReplyTo) > 0 {
+		v, err := formatAddressList([]string{m.ReplyTo})
+		if err != nil {
+			return nil, err
+		}
+		writeHeader(&buf, "Reply-To", v)
+	}
+	writeHeader(&buf, "Subject", mime.BEncoding.Encode("UTF-8", m.Subject))
+	writeHeader(&buf, "Date", time.Now().Format(time.RFC1123Z))
+	writeHeader(&buf, "Message-ID", messageID(from.Address))
+	writeHeader(&buf, "MIME-Version", "1.0")
+	keys := make([]string, 0, len(m.Headers))
+	for k := range m.Headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		writeHeader(&buf, textproto.CanonicalMIMEHeaderKey(k), mime.QEncoding.Encode("UTF-8", m.Headers[k]))
+	}
+
+	header, body, err := m.body()
+	if err != nil {
+		return nil, err
+	}
+	if len(m.Attachments) == 0 {
+		writeMIMEHeader(&buf, header)
+		buf.WriteString("\r\n")
+		buf.Write(body)
+		return buf.Bytes(), nil
+	}
+
+	var parts bytes.Buffer
+	mw := multipart.NewWriter(&parts)
+	w, err := mw.CreatePart(header)
+	if err != nil {
+		return nil, err
+	}
+	w.Write(body)
+	for _, a := range m.Attachments {
+		if err := writeAttachment(mw, a); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	writeHeader(&buf, "Content-Type", mime.FormatMediaType("multipart/mixed", map[string]string{"boundary": mw.Boundary()}))
+	buf.WriteString("\r\n")
+	buf.Write(parts.Bytes())
+	return buf.Bytes(), nil
+}
+
+// body 生成正文部分的MIME头及内容
+func (m *Message) body() (textproto.MIMEHeader, []byte, error) {
+	if len(m.Text) == 0 || len(m.HTML) == 0 {
+		if len(m.HTML) > 0 {
+			return textPart("text/html", m.HTML)
+		}
+		return textPart("text/plain", m.Text)
+	}
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for _, p := range []struct{ contentType, content string }{
+		{"text/plain", m.Text},
+		{"text/html", m.HTML},
+	} {
+		header, body, err := textPart(p.contentType, p.content)
+		if err != nil {
+			return nil, nil, err
+		}
+		w, err := mw.CreatePart(header)
+		if err != nil {
+			return nil, nil, err
+		}
+		w.Write(body)
+	}
+	if err := mw.Close(); err != nil {
+		return nil, nil, err
+	}
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", mime.FormatMediaType("multipart/alternative", map[string]string{"boundary": mw.Boundary()}))
+	return header, buf.Bytes(), nil
+}
+
+// textPart 使用quoted-printable编码的文本正文
+func textPart(contentType, content string) (textproto.MIMEHeader, []byte, error) {
+	var buf bytes.Buffer
+	qp := quotedprintable.NewWriter(&buf)
+	if _, err := qp.Write([]byte(content)); err != nil {
+		return nil, nil, err
+	}
+	if err := qp.Close(); err != nil {
+		return nil, nil, err
+	}
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", mime.FormatMediaType(contentType, map[string]string{"charset": "UTF-8"}))
+	header.Set("Content-Transfer-Encoding", "quoted-printable")
+	return header, buf.Bytes(), nil
+}
+
+// writeAttachment 写入base64编码的附件，非ASCII文件名按RFC 2231编码
+func writeAttachment(mw *multipart.Writer, a Attachment) error {
+	contentType := a.ContentType
+	if len(contentType) == 0 {
+		if i := strings.LastIndex(a.Filename, "."); i >= 0 {
+			contentType = mime.TypeByExtension(a.Filename[i:])
+		}
+		if len(contentType) == 0 {
+			contentType = "application/octet-stream"
+		}
+	}
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename}))
+	header.Set("Content-Transfer-Encoding", "base64")
+	w, err := mw.CreatePart(header)
+	if err != nil {
+		return err
+	}
+	enc := base64.NewEncoder(base64.StdEncoding, &lineWriter{w: w})
+	if _, err := enc.Write(a.Data); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// lineWriter 每76个字符换行，见RFC 2045 6.8
+type lineWriter struct {
+	w   io.Writer
+	col int
+}
+
+func (l *lineWriter) Write(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		chunk := 76 - l.col
+		if chunk > len(p) {
+			chunk = len(p)
+		}
+		if _, err := l.w.Write(p[:chunk]); err != nil {
+			return n, err
+		}
+		n += chunk
+		l.col += chunk
+		p = p[chunk:]
+		if l.col == 76 {
+			if _, err := l.w.Write([]byte("\r\n")); err != nil {
+				return n, err
+			}
+			l.col = 0
+		}
+	}
+	return n, nil
+}
+
+func writeHeader(buf *bytes.Buffer, key, value string) {
+	buf.WriteString(key + ": " + value + "\r\n")
+}
+
+func writeMIMEHeader(buf *bytes.Buffer, header textproto.MIMEHeader) {
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range header[k] {
+			writeHeader(buf, k, v)
+		}
+	}
+}
+
+// messageID 生成Message-ID，域名取发件地址的域名
+func messageID(from string) string {
+	domain := "localhost"
+	if i := strings.LastIndex(from, "@"); i >= 0 {
+		domain = from[i+1:]
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	return "<" + hex.EncodeToString(b) + "@" + domain + ">"
+}
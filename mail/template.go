@@ -0,0 +1,121 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	htmltemplate "html/template"
+	"text/template"
+
+	"ginfra/datasource"
+	"ginfra/models"
+
+	"gorm.io/gorm"
+)
+
+// 内置模板名称
+const (
+	TemplateVerifyEmail   = "verify_email"   // 邮箱验证，数据为VerifyEmailData
+	TemplateResetPassword = "reset_password" // 找回密码，数据为ResetPasswordData
+)
+
+//VerifyEmailData 邮箱验证模板数据
+type VerifyEmailData struct {
+	Email   string
+	Link    string
+	Expires string
+}
+
+//ResetPasswordData 找回密码模板数据
+type ResetPasswordData struct {
+	Identifier string
+	Link       string
+	Expires    string
+}
+
+// 内置模板，数据库中存在同名模板时使用数据库中的模板
+var builtinTemplates = map[string]*models.MailTemplate{
+	TemplateVerifyEmail: {
+		Subject: "请验证您的邮箱",
+		Text:    "您好，\n\n请在{{.Expires}}内打开以下链接完成邮箱 {{.Email}} 的验证：\n{{.Link}}\n\n如非本人操作请忽略本邮件。\n",
+		HTML: `<p>您好，</p>
+<p>请在{{.Expires}}内点击以下链接完成邮箱 {{.Email}} 的验证：</p>
+<p><a href="{{.Link}}">{{.Link}}</a></p>
+<p>如非本人操作请忽略本邮件。</p>`,
+	},
+	TemplateResetPassword: {
+		Subject: "找回密码",
+		Text:    "您好，\n\n账号 {{.Identifier}} 申请了找回密码，请在{{.Expires}}内打开以下链接设置新密码：\n{{.Link}}\n\n如非本人操作请忽略本邮件，您的密码不会改变。\n",
+		HTML: `<p>您好，</p>
+<p>账号 {{.Identifier}} 申请了找回密码，请在{{.Expires}}内点击以下链接设置新密码：</p>
+<p><a href="{{.Link}}">{{.Link}}</a></p>
+<p>如非本人操作请忽略本邮件，您的密码不会改变。</p>`,
+	},
+}
+
+//Content 模板渲染结果
+type Content struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// loadTemplate 优先使用数据库中的模板，数据库未初始化或不存在同名模板时使用内置模板
+func loadTemplate(ctx context.Context, name string) (*models.MailTemplate, error) {
+	if db, err := datasource.Gormv2(ctx); err == nil {
+		tpl, err := models.GetMailTemplate(db, name)
+		if err == nil {
+			return tpl, nil
+		}
+		if err != gorm.ErrRecordNotFound {
+			return nil, err
+		}
+	}
+	tpl, ok := builtinTemplates[name]
+	if !ok {
+		return nil, fmt.Errorf("mail template not found: %s", name)
+	}
+	return tpl, nil
+}
+
+//Render 渲染模板，HTML使用html/template转义
+func Render(ctx context.Context, name string, data interface{}) (*Content, error) {
+	tpl, err := loadTemplate(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return renderTemplate(tpl, data)
+}
+
+func renderTemplate(tpl *models.MailTemplate, data interface{}) (*Content, error) {
+	content := &Content{}
+	for _, t := range []struct {
+		text string
+		out  *string
+	}{{tpl.Subject, &content.Subject}, {tpl.Text, &content.Text}} {
+		if len(t.text) == 0 {
+			continue
+		}
+		parsed, err := template.New(tpl.Name).Option("missingkey=error").Parse(t.text)
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if err := parsed.Execute(&buf, data); err != nil {
+			return nil, err
+		}
+		*t.out = buf.String()
+	}
+	if len(tpl.HTML) > 0 {
+		parsed, err := htmltemplate.New(tpl.Name).Option("missingkey=error").Parse(tpl.HTML)
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if err := parsed.Execute(&buf, data); err != nil {
+			return nil, err
+		}
+		content.HTML = buf.String()
+	}
+	return content, nil
+}
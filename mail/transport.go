@@ -0,0 +1,136 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+
+	"ginfra/log"
+
+	"go.uber.org/zap"
+)
+
+// SMTP加密方式
+const (
+	TLSNone     = "none"     // 不加密，仅用于本地测试
+	TLSStartTLS = "starttls" // 明文连接后升级，一般为587端口
+	TLSImplicit = "implicit" // 直接建立TLS连接，一般为465端口
+)
+
+//Transport 邮件投递方式，raw为已生成的MIME邮件
+type Transport interface {
+	Send(ctx context.Context, from string, to []string, raw []byte) error
+}
+
+//SMTPConfig SMTP服务器配置
+type SMTPConfig struct {
+	Host               string
+	Port               int
+	Username           string // 为空时不认证
+	Password           string
+	TLS                string        // none、starttls、implicit
+	InsecureSkipVerify bool          // 不校验服务器证书，仅用于测试
+	Timeout            time.Duration // 单封邮件的投递超时
+}
+
+// smtpTransport 通过SMTP投递
+type smtpTransport struct {
+	cfg       SMTPConfig
+	tlsConfig *tls.Config
+}
+
+//NewSMTPTransport 通过SMTP投递，支持STARTTLS及implicit TLS，配置用户名时使用PLAIN认证
+func NewSMTPTransport(cfg SMTPConfig) (Transport, error) {
+	switch cfg.TLS {
+	case TLSNone, TLSStartTLS, TLSImplicit:
+	default:
+		return nil, fmt.Errorf("unknown smtp tls mode: %s", cfg.TLS)
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	return &smtpTransport{
+		cfg:       cfg,
+		tlsConfig: &tls.Config{ServerName: cfg.Host, InsecureSkipVerify: cfg.InsecureSkipVerify},
+	}, nil
+}
+
+func (t *smtpTransport) Send(ctx context.Context, from string, to []string, raw []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, t.cfg.Timeout)
+	defer cancel()
+
+	addr := net.JoinHostPort(t.cfg.Host, strconv.Itoa(t.cfg.Port))
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	if t.cfg.TLS == TLSImplicit {
+		tlsConn := tls.Client(conn, t.tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return err
+		}
+		conn = tlsConn
+	}
+
+	c, err := smtp.NewClient(conn, t.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if t.cfg.TLS == TLSStartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return errors.New("smtp server does not support STARTTLS")
+		}
+		if err := c.StartTLS(t.tlsConfig); err != nil {
+			return err
+		}
+	}
+	if len(t.cfg.Username) > 0 {
+		if err := c.Auth(smtp.PlainAuth("", t.cfg.Username, t.cfg.Password, t.cfg.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(raw); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// logTransport 开发测试使用，邮件仅记录到日志
+type logTransport struct{}
+
+//NewLogTransport 邮件仅记录到日志，不实际投递，用于开发测试
+func NewLogTransport() Transport {
+	return logTransport{}
+}
+
+func (logTransport) Send(ctx context.Context, from string, to []string, raw []byte) error {
+	log.WithContext(ctx).Info("mail", zap.String("from", from), zap.Strings("to", to), zap.ByteString("raw", raw))
+	return nil
+}
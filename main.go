@@ -6,26 +6,40 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
-	"strings"
 	"syscall"
 	"time"
 
 	"ginfra/config"
 	"ginfra/datasource"
+	"ginfra/errcode"
+	"ginfra/feature"
+	"ginfra/idp"
 	"ginfra/log"
+	"ginfra/mail"
 	mw "ginfra/middleware"
 	"ginfra/models"
 	"ginfra/router"
+	"ginfra/sms"
+	"ginfra/tracing"
 	"gorm.io/gorm/logger"
 
-	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/spf13/pflag"
 )
 
+var errcodes = pflag.String("errcodes", "", "输出错误码文档后退出: markdown | json")
+
 func main() {
 	pflag.Parse()
 
+	if len(*errcodes) > 0 {
+		if err := dumpErrcodes(*errcodes); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// init config
 	cfg, err := config.Parse("")
 	if err != nil {
@@ -44,11 +58,24 @@ func main() {
 		if err != nil {
 			panic(err)
 		}
-		db.Set("gorm:table_options", "ENGINE=InnoDB").AutoMigrate(
-			&models.Post{},
-			&models.Tag{},
-			&models.PostTag{},
-		)
+		if err := models.AutoMigrate(db.Set("gorm:table_options", "ENGINE=InnoDB")); err != nil {
+			panic(err)
+		}
+	}
+
+	// feature flags
+	if err := feature.Init(cfg); err != nil {
+		panic(err)
+	}
+
+	// 第三方登录平台
+	if err := idp.Init(cfg); err != nil {
+		panic(err)
+	}
+
+	// 短信验证码
+	if err := sms.Init(cfg); err != nil {
+		panic(err)
 	}
 
 	// Set gin mode.
@@ -62,6 +89,40 @@ func main() {
 	log.ZLog = logger
 	defer logger.Sync()
 
+	// 邮件服务，发送协程依赖日志
+	if err := mail.Init(cfg); err != nil {
+		panic(err)
+	}
+
+	// 中间件后台任务：写入会话访问时间、清理验证码票据及过期的吊销记录，依赖DB及日志
+	background, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	mw.Start(background)
+
+	// tracing
+	tracingOpts, err := tracing.LoadOptions(cfg)
+	if err != nil {
+		panic(err)
+	}
+	shutdownTracing, err := tracing.Init(context.Background(), tracingOpts)
+	if err != nil {
+		panic(err)
+	}
+
+	// cors & security headers
+	corsDefault, corsGroups, err := mw.LoadCorsPolicies(cfg)
+	if err != nil {
+		panic(err)
+	}
+	corsHandler, err := mw.Cors(corsDefault, corsGroups)
+	if err != nil {
+		panic(err)
+	}
+	secureHeaders, err := mw.LoadSecureHeadersConfig(cfg)
+	if err != nil {
+		panic(err)
+	}
+
 	// Create the Gin engine.
 	g := router.New(
 		// gin.Context to context
@@ -70,20 +131,16 @@ func main() {
 		mw.RequestId(),
 		// Middlwares. Customize logger, should behind RequestId
 		mw.ContextLogger(logger),
+		// Middlwares. Tracing, should behind ContextLogger
+		mw.Tracing(tracingOpts.ServiceName),
+		// Middlwares. Shadow traffic, should behind RequestId
+		mw.Shadow(),
 		// Middlwares. Request time out
 		mw.Timeout(cfg.GetDuration("timeout")),
+		// Middlwares. Security headers
+		mw.SecureHeaders(secureHeaders),
 		// cors
-		cors.New(cors.Config{
-			AllowOrigins:     cfg.GetStringSlice("cors.origins"),
-			AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD"},
-			AllowHeaders:     []string{"*"},
-			ExposeHeaders:    []string{"Content-Length"},
-			AllowCredentials: true,
-			AllowOriginFunc: func(origin string) bool {
-				return strings.HasSuffix(origin, "qq.com")
-			},
-			MaxAge: 12 * time.Hour,
-		}),
+		corsHandler,
 	)
 
 	srv := &http.Server{
@@ -117,9 +174,25 @@ func main() {
 	if err := srv.Shutdown(ctx); err != nil {
 		logger.Fatal(err.Error())
 	}
+	stopBackground()
+	mw.FlushSessions(ctx)
+	if err := shutdownTracing(ctx); err != nil {
+		logger.Error(err.Error())
+	}
 	// catching ctx.Done(). timeout of 5 seconds.
 	select {
 	case <-ctx.Done():
 	}
 	logger.Info("Server exiting")
 }
+
+// dumpErrcodes 输出全部错误码的HTTP状态码、是否可重试及各语言消息
+func dumpErrcodes(format string) error {
+	switch format {
+	case "markdown":
+		return errcode.WriteMarkdown(os.Stdout, []string{errcode.LangZH, errcode.LangEN})
+	case "json":
+		return errcode.WriteJSON(os.Stdout)
+	}
+	return fmt.Errorf("unknown errcodes format: %s", format)
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"ginfra/config"
+	"ginfra/log"
+	"ginfra/protocol"
+	"ginfra/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+var adminCfg *config.Config
+
+func init() {
+	var err error
+	adminCfg, err = config.Parse("")
+	if err != nil {
+		panic(err)
+	}
+}
+
+// RequireAdmin 中间件，管理接口仅允许JWT登录态访问，具体权限由各路由的Require校验，需放在JWTAuth之后
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if protocol.GetAuthType(c) != protocol.AuthTypeJWT || len(protocol.GetUserId(c)) == 0 {
+			log.WithGinContext(c).Error("RequireAdmin denied")
+			protocol.SetErrResponse(c, protocol.ErrCodeUnAuthorized)
+			c.Abort()
+			return
+		}
+	}
+}
+
+//IsSuperAdmin 是否为配置admin.uids中的超级管理员，拥有全部权限，用于分配最初的管理角色
+func IsSuperAdmin(uid string) bool {
+	return len(uid) > 0 && utils.StringInSlice(uid, adminCfg.GetStringSlice("admin.uids"))
+}
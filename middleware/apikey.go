@@ -0,0 +1,167 @@
+package middleware
+
+import (
+	"context"
+	"crypto/subtle"
+	"time"
+
+	"ginfra/config"
+	"ginfra/datasource"
+	"ginfra/errcode"
+	"ginfra/log"
+	"ginfra/models"
+	"ginfra/protocol"
+	"ginfra/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+var (
+	HeaderSecretIdName  string
+	HeaderSecretKeyName string
+
+	// 最近使用时间的更新间隔，避免每次请求都写DB
+	apiKeyTouchInterval = time.Minute
+)
+
+func init() {
+	cfg, err := config.Parse("")
+	if err != nil {
+		panic(err)
+	}
+
+	cfg.SetDefault("apikey.headersecretid", "X-Secret-Id")
+	cfg.SetDefault("apikey.headersecretkey", "X-Secret-Key")
+	HeaderSecretIdName = cfg.GetString("apikey.headersecretid")
+	HeaderSecretKeyName = cfg.GetString("apikey.headersecretkey")
+}
+
+type HandleApiKeyFunc func(c *gin.Context, key *models.ApiKey) error
+
+//HashSecretKey 计算SecretKey的哈希值，DB中只保存哈希
+func HashSecretKey(secretKey string) string {
+	return utils.SHA256Hex(secretKey)
+}
+
+//GenerateApiKey 生成一对SecretId/SecretKey
+func GenerateApiKey() (secretId, secretKey string, err error) {
+	secretId, err = utils.RandomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	secretKey, err = utils.RandomString(40)
+	if err != nil {
+		return "", "", err
+	}
+	return "AKID" + secretId, secretKey, nil
+}
+
+// Authenticate 中间件，同时支持JWT和API密钥两种鉴权方式
+// 请求头携带SecretId时按API密钥鉴权，否则按JWT鉴权
+func Authenticate(claimHandler HandleClaimFunc, keyHandler HandleApiKeyFunc) gin.HandlerFunc {
+	jwtAuth := JWTAuth(claimHandler)
+	return func(c *gin.Context) {
+		if len(c.Request.Header.Get(HeaderSecretIdName)) == 0 {
+			jwtAuth(c)
+			return
+		}
+
+		key, err := verifyApiKey(c.Request.Context(),
+			c.Request.Header.Get(HeaderSecretIdName), c.Request.Header.Get(HeaderSecretKeyName))
+		if err != nil {
+			log.WithGinContext(c).Error("Authenticate verifyApiKey fail", zap.String("error", err.Error()))
+			protocol.SetErrResponse(c, err)
+			c.Abort()
+			return
+		}
+
+		err = keyHandler(c, key)
+		if err != nil {
+			log.WithGinContext(c).Error("Authenticate HandleApiKeyFunc exception", zap.String("error", err.Error()))
+			protocol.SetErrResponse(c, errcode.NewCustomError(errcode.ErrInvalidApiKey, err.Error()))
+			c.Abort()
+			return
+		}
+
+		c.Set(protocol.CtxAuthType, protocol.AuthTypeApiKey)
+		c.Set(protocol.CtxCustomerID, key.CustomerID)
+		c.Set(protocol.CtxScopes, key.ScopeList())
+		log.Logger(c).Set(protocol.CtxCustomerID, key.CustomerID)
+
+		touchApiKey(key)
+	}
+}
+
+// RequireScope 中间件，API密钥鉴权的请求需要具备全部scope；JWT鉴权的请求不受限制
+func RequireScope(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if protocol.GetAuthType(c) != protocol.AuthTypeApiKey {
+			return
+		}
+
+		granted := protocol.GetScopes(c)
+		if utils.StringInSlice("*", granted) {
+			return
+		}
+		for _, scope := range scopes {
+			if !utils.StringInSlice(scope, granted) {
+				log.WithGinContext(c).Error("RequireScope denied", zap.String("scope", scope))
+				protocol.SetErrResponse(c, errcode.NewCustomError(errcode.ErrInsufficientScope,
+					"API密钥未授权该操作: "+scope))
+				c.Abort()
+				return
+			}
+		}
+	}
+}
+
+func verifyApiKey(ctx context.Context, secretId, secretKey string) (*models.ApiKey, error) {
+	db, err := datasource.Gormv2(ctx)
+	if err != nil {
+		return nil, errcode.NewCustomError(errcode.ErrCodeInternalError, err.Error())
+	}
+
+	key, err := models.GetApiKeyBySecretId(db, secretId)
+	if err != nil {
+		return nil, errcode.NewCustomError(errcode.ErrInvalidApiKey, "invalid api key")
+	}
+	if err := checkApiKey(key, secretKey); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// checkApiKey 校验SecretKey及密钥状态，先比较哈希，避免泄露密钥是否被禁用或过期
+func checkApiKey(key *models.ApiKey, secretKey string) error {
+	hash := HashSecretKey(secretKey)
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(key.SecretKeyHash)) != 1 {
+		return errcode.NewCustomError(errcode.ErrInvalidApiKey, "invalid api key")
+	}
+	if key.Disabled {
+		return errcode.NewCustomError(errcode.ErrInvalidApiKey, "api key disabled")
+	}
+	if key.Expired() {
+		return errcode.NewCustomError(errcode.ErrExpiredApiKey, "expired api key")
+	}
+	return nil
+}
+
+// touchApiKey 异步更新密钥最近使用时间
+func touchApiKey(key *models.ApiKey) {
+	now := time.Now()
+	if key.LastUsedAt != nil && now.Sub(*key.LastUsedAt) < apiKeyTouchInterval {
+		return
+	}
+
+	go func() {
+		db, err := datasource.Gormv2(context.Background())
+		if err != nil {
+			return
+		}
+		if err := key.UpdateLastUsed(db, now); err != nil {
+			log.WithContext(context.Background()).Error("update api key last used fail",
+				zap.String("SecretId", key.SecretId), zap.String("error", err.Error()))
+		}
+	}()
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ginfra/errcode"
+	"ginfra/log"
+	"ginfra/models"
+	"ginfra/protocol"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smartystreets/goconvey/convey"
+	"go.uber.org/zap"
+)
+
+func Test_CheckApiKey(t *testing.T) {
+	secretId, secretKey, err := GenerateApiKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKey := func() *models.ApiKey {
+		return &models.ApiKey{SecretId: secretId, SecretKeyHash: HashSecretKey(secretKey)}
+	}
+
+	convey.Convey("checkApiKey", t, func() {
+		convey.So(newKey().SecretKeyHash, convey.ShouldNotEqual, secretKey)
+		convey.So(checkApiKey(newKey(), secretKey), convey.ShouldBeNil)
+		convey.So(errcode.ErrorCode(checkApiKey(newKey(), secretKey+"x")), convey.ShouldEqual, errcode.ErrInvalidApiKey)
+		convey.So(errcode.ErrorCode(checkApiKey(newKey(), "")), convey.ShouldEqual, errcode.ErrInvalidApiKey)
+
+		disabled := newKey()
+		disabled.Disabled = true
+		err := checkApiKey(disabled, secretKey)
+		convey.So(errcode.ErrorCode(err), convey.ShouldEqual, errcode.ErrInvalidApiKey)
+		convey.So(err.Error(), convey.ShouldEqual, "api key disabled")
+
+		expired, past, future := newKey(), time.Now().Add(-time.Minute), time.Now().Add(time.Minute)
+		expired.ExpiresAt = &past
+		convey.So(errcode.ErrorCode(checkApiKey(expired, secretKey)), convey.ShouldEqual, errcode.ErrExpiredApiKey)
+		expired.ExpiresAt = &future
+		convey.So(checkApiKey(expired, secretKey), convey.ShouldBeNil)
+
+		// SecretKey错误时不返回密钥状态
+		disabled.ExpiresAt = &past
+		err = checkApiKey(disabled, "wrong")
+		convey.So(err.Error(), convey.ShouldEqual, "invalid api key")
+	})
+}
+
+func Test_RequireScope(t *testing.T) {
+	oldLogger := log.ZLog
+	defer func() { log.ZLog = oldLogger }()
+	log.ZLog = zap.NewNop()
+
+	gin.SetMode(gin.TestMode)
+	do := func(authType string, granted []string, scopes ...string) *httptest.ResponseRecorder {
+		g := gin.New()
+		g.Use(func(c *gin.Context) {
+			c.Set(protocol.CtxAuthType, authType)
+			c.Set(protocol.CtxScopes, granted)
+		})
+		g.GET("/", RequireScope(scopes...), func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		return w
+	}
+
+	convey.Convey("RequireScope", t, func() {
+		convey.So(do(protocol.AuthTypeJWT, nil, "upload").Body.String(), convey.ShouldEqual, "ok")
+		convey.So(do(protocol.AuthTypeApiKey, []string{"upload"}, "upload").Body.String(), convey.ShouldEqual, "ok")
+		convey.So(do(protocol.AuthTypeApiKey, []string{"*"}, "upload", "admin").Body.String(), convey.ShouldEqual, "ok")
+
+		w := do(protocol.AuthTypeApiKey, []string{"read"}, "upload")
+		convey.So(w.Body.String(), convey.ShouldContainSubstring, errcode.ErrInsufficientScope)
+		w = do(protocol.AuthTypeApiKey, []string{"upload"}, "upload", "admin")
+		convey.So(w.Body.String(), convey.ShouldContainSubstring, errcode.ErrInsufficientScope)
+		convey.So(do(protocol.AuthTypeApiKey, nil, "upload").Body.String(), convey.ShouldContainSubstring, errcode.ErrInsufficientScope)
+	})
+}
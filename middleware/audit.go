@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"context"
+
+	"ginfra/datasource"
+	"ginfra/log"
+	"ginfra/models"
+	"ginfra/protocol"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// 审计事件
+const (
+	AuditEventPermissionDenied = "PermissionDenied"
+	AuditEventRoleAssigned     = "RoleAssigned"
+	AuditEventRoleUnassigned   = "RoleUnassigned"
+	AuditEventRoleChanged      = "RoleChanged"
+	AuditEventTenantMismatch   = "TenantMismatch"
+	AuditEventAccountLocked    = "AccountLocked"
+	AuditEventPasswordChanged  = "PasswordChanged"
+	AuditEventIdentityLinked   = "IdentityLinked"
+	AuditEventIdentityUnlinked = "IdentityUnlinked"
+	AuditEventAccountsMerged   = "AccountsMerged"
+	AuditEventMFAEnabled       = "MFAEnabled"
+	AuditEventMFADisabled      = "MFADisabled"
+	AuditEventRecoveryCodeUsed = "RecoveryCodeUsed"
+	AuditEventSessionRevoked   = "SessionRevoked"
+)
+
+//Audit 记录审计日志，写日志的同时异步落DB，落DB失败不影响请求
+func Audit(c *gin.Context, event, detail string) {
+	entry := &models.AuditLog{
+		Event:      event,
+		Uid:        protocol.GetUserId(c),
+		CustomerID: protocol.GetCustomerId(c),
+		AuthType:   protocol.GetAuthType(c),
+		Method:     c.Request.Method,
+		Path:       c.Request.URL.Path,
+		ClientIP:   c.ClientIP(),
+		RequestId:  protocol.GetRequestId(c),
+		Detail:     detail,
+	}
+	logger := log.WithGinContext(c)
+	logger.Warn("audit",
+		zap.String("event", entry.Event),
+		zap.String("uid", entry.Uid),
+		zap.String("path", entry.Path),
+		zap.String("detail", entry.Detail),
+	)
+
+	go func() {
+		db, err := datasource.Gormv2(context.Background())
+		if err != nil {
+			return
+		}
+		if err := entry.Insert(db); err != nil {
+			logger.Error("insert audit log fail", zap.String("error", err.Error()))
+		}
+	}()
+}
@@ -0,0 +1,265 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"ginfra/config"
+	"ginfra/log"
+	"ginfra/protocol"
+	"ginfra/tencent"
+	"ginfra/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// 验证码票据请求头，未携带时从JSON请求体的CaptchaTicket、CaptchaRandstr字段读取
+const (
+	HeaderCaptchaTicket  = "X-Captcha-Ticket"
+	HeaderCaptchaRandstr = "X-Captcha-Randstr"
+)
+
+// 路由的验证码要求
+const (
+	CaptchaModeAlways = "always" // 每次请求都需要验证码
+	CaptchaModeRisk   = "risk"   // IP或用户失败次数达到阈值后需要验证码
+)
+
+// 读取请求体中票据的最大长度
+const captchaMaxBodySize = 1 << 20
+
+//CaptchaRoute 需要验证码的路由
+type CaptchaRoute struct {
+	Path     string // 完整路由，如 /api/v2/account/Login
+	Mode     string // always、risk
+	Failures int    // risk模式下窗口内失败达到该次数后需要验证码
+}
+
+//CaptchaConfig 验证码配置
+type CaptchaConfig struct {
+	TicketTTL     time.Duration // 已使用票据的缓存时间，缓存期间票据不能重复使用
+	FailureWindow time.Duration // 失败次数统计窗口
+	Routes        []CaptchaRoute
+}
+
+//CaptchaVerifier 校验验证码票据，默认使用腾讯云验证码
+var CaptchaVerifier = tencent.DescribeCaptchaResult
+
+var (
+	captchaCfg      atomic.Value
+	captchaTickets  = &usedTickets{entries: make(map[string]time.Time)}
+	captchaFailures = &failureCounter{counts: make(map[string]*failureCount)}
+)
+
+func init() {
+	cfg, err := config.Parse("")
+	if err != nil {
+		panic(err)
+	}
+
+	if err := loadCaptchaConfig(cfg); err != nil {
+		panic(err)
+	}
+	cfg.OnChange(func() {
+		if err := loadCaptchaConfig(cfg); err != nil {
+			log.WithContext(context.Background()).Error("reload captcha config fail",
+				zap.String("error", err.Error()))
+		}
+	})
+}
+
+func loadCaptchaConfig(cfg *config.Config) error {
+	cc := &CaptchaConfig{
+		TicketTTL:     10 * time.Minute,
+		FailureWindow: 10 * time.Minute,
+	}
+	if err := cfg.UnmarshalKey("captcha", cc); err != nil {
+		return err
+	}
+	captchaCfg.Store(cc)
+	return nil
+}
+
+// Captcha 中间件，按captcha.routes配置要求请求携带有效的验证码票据；需放在鉴权中间件之后，risk模式才能按用户统计
+// always模式每次请求都校验，risk模式在IP或用户失败次数达到阈值后校验；失败次数由handler通过RecordCaptchaFailure记录
+func Captcha() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cc := captchaCfg.Load().(*CaptchaConfig)
+		route := cc.route(c.FullPath())
+		if route == nil {
+			return
+		}
+		if route.Mode == CaptchaModeRisk && !captchaRisky(c, route.Failures) {
+			return
+		}
+
+		ticket, randstr := captchaFromRequest(c)
+		if err := VerifyCaptcha(c, ticket, randstr); err != nil {
+			protocol.SetErrResponse(c, err)
+			c.Abort()
+			return
+		}
+	}
+}
+
+func (cc *CaptchaConfig) route(path string) *CaptchaRoute {
+	for i := range cc.Routes {
+		if cc.Routes[i].Path == path {
+			return &cc.Routes[i]
+		}
+	}
+	return nil
+}
+
+// captchaFromRequest 优先从请求头读取票据，否则从JSON请求体读取并还原请求体
+func captchaFromRequest(c *gin.Context) (string, string) {
+	ticket, randstr := c.GetHeader(HeaderCaptchaTicket), c.GetHeader(HeaderCaptchaRandstr)
+	if len(ticket) > 0 || c.Request.Body == nil || !strings.HasPrefix(c.ContentType(), gin.MIMEJSON) {
+		return ticket, randstr
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(c.Request.Body, captchaMaxBodySize))
+	c.Request.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(body), c.Request.Body))
+	if err != nil {
+		return "", ""
+	}
+	var req struct {
+		CaptchaTicket  string
+		CaptchaRandstr string
+	}
+	json.Unmarshal(body, &req)
+	return req.CaptchaTicket, req.CaptchaRandstr
+}
+
+//VerifyCaptcha 校验验证码票据，票据校验通过后在TicketTTL内不能重复使用；未携带返回CaptchaRequired，校验失败返回CaptchaFailed
+func VerifyCaptcha(c *gin.Context, ticket, randstr string) error {
+	if len(ticket) == 0 || len(randstr) == 0 {
+		return protocol.ErrCodeCaptchaRequired
+	}
+	key := utils.SHA256Hex(ticket)
+	if captchaTickets.isUsed(key, time.Now()) {
+		log.WithGinContext(c).Info("captcha ticket reused")
+		return protocol.ErrCodeCaptchaFailed
+	}
+	if err := CaptchaVerifier(c.Request.Context(), ticket, randstr, c.ClientIP()); err != nil {
+		log.WithGinContext(c).Info("captcha verify fail", zap.String("error", err.Error()))
+		return protocol.ErrCodeCaptchaFailed
+	}
+	cc := captchaCfg.Load().(*CaptchaConfig)
+	if !captchaTickets.use(key, time.Now().Add(cc.TicketTTL)) {
+		// 并发使用同一票据
+		return protocol.ErrCodeCaptchaFailed
+	}
+	return nil
+}
+
+//RecordCaptchaFailure 记录一次失败(如密码错误)，risk模式的路由按IP及用户的失败次数要求验证码
+func RecordCaptchaFailure(c *gin.Context) {
+	cc := captchaCfg.Load().(*CaptchaConfig)
+	now := time.Now()
+	for _, key := range captchaRiskKeys(c) {
+		captchaFailures.incr(key, now, cc.FailureWindow)
+	}
+}
+
+// captchaRisky IP或用户在窗口内的失败次数是否达到阈值
+func captchaRisky(c *gin.Context, failures int) bool {
+	now := time.Now()
+	for _, key := range captchaRiskKeys(c) {
+		if captchaFailures.get(key, now) >= failures {
+			return true
+		}
+	}
+	return false
+}
+
+func captchaRiskKeys(c *gin.Context) []string {
+	keys := []string{"ip:" + c.ClientIP()}
+	if uid := protocol.GetUserId(c); len(uid) > 0 {
+		keys = append(keys, "uid:"+uid)
+	}
+	return keys
+}
+
+// usedTickets 已使用的票据哈希及过期时间，多实例部署时各实例独立缓存
+type usedTickets struct {
+	sync.Mutex
+	entries map[string]time.Time
+}
+
+func (t *usedTickets) isUsed(key string, now time.Time) bool {
+	t.Lock()
+	defer t.Unlock()
+	expiresAt, ok := t.entries[key]
+	return ok && now.Before(expiresAt)
+}
+
+// use 记录票据已使用，票据已被使用时返回false
+func (t *usedTickets) use(key string, expiresAt time.Time) bool {
+	t.Lock()
+	defer t.Unlock()
+	if old, ok := t.entries[key]; ok && time.Now().Before(old) {
+		return false
+	}
+	t.entries[key] = expiresAt
+	return true
+}
+
+func (t *usedTickets) cleanup(now time.Time) {
+	t.Lock()
+	defer t.Unlock()
+	for key, expiresAt := range t.entries {
+		if !now.Before(expiresAt) {
+			delete(t.entries, key)
+		}
+	}
+}
+
+type failureCount struct {
+	count     int
+	expiresAt time.Time
+}
+
+// failureCounter 固定窗口失败计数，窗口从第一次失败开始
+type failureCounter struct {
+	sync.Mutex
+	counts map[string]*failureCount
+}
+
+func (f *failureCounter) incr(key string, now time.Time, window time.Duration) {
+	f.Lock()
+	defer f.Unlock()
+	fc, ok := f.counts[key]
+	if !ok || !now.Before(fc.expiresAt) {
+		fc = &failureCount{expiresAt: now.Add(window)}
+		f.counts[key] = fc
+	}
+	fc.count++
+}
+
+func (f *failureCounter) get(key string, now time.Time) int {
+	f.Lock()
+	defer f.Unlock()
+	fc, ok := f.counts[key]
+	if !ok || !now.Before(fc.expiresAt) {
+		return 0
+	}
+	return fc.count
+}
+
+func (f *failureCounter) cleanup(now time.Time) {
+	f.Lock()
+	defer f.Unlock()
+	for key, fc := range f.counts {
+		if !now.Before(fc.expiresAt) {
+			delete(f.counts, key)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ginfra/log"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smartystreets/goconvey/convey"
+	"go.uber.org/zap"
+)
+
+func Test_Captcha(t *testing.T) {
+	oldLogger, oldVerifier, oldCfg := log.ZLog, CaptchaVerifier, captchaCfg.Load()
+	defer func() {
+		log.ZLog, CaptchaVerifier = oldLogger, oldVerifier
+		captchaCfg.Store(oldCfg)
+	}()
+	log.ZLog = zap.NewNop()
+	var verifiedIP string
+	CaptchaVerifier = func(ctx context.Context, ticket, randstr, clientIP string) error {
+		verifiedIP = clientIP
+		if randstr != "ok" {
+			return errors.New("invalid ticket")
+		}
+		return nil
+	}
+	captchaCfg.Store(&CaptchaConfig{
+		TicketTTL:     time.Minute,
+		FailureWindow: time.Minute,
+		Routes: []CaptchaRoute{
+			{Path: "/always", Mode: CaptchaModeAlways},
+			{Path: "/risk", Mode: CaptchaModeRisk, Failures: 2},
+		},
+	})
+
+	gin.SetMode(gin.TestMode)
+	g := gin.New()
+	g.Use(Captcha())
+	echo := func(c *gin.Context) {
+		b, _ := ioutil.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, string(b))
+	}
+	g.POST("/always", echo)
+	g.POST("/risk", func(c *gin.Context) {
+		RecordCaptchaFailure(c)
+		c.String(http.StatusOK, "failed")
+	})
+	g.POST("/open", echo)
+
+	do := func(path, body string, header map[string]string) (string, string) {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = "10.0.0.1:1234"
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, req)
+		var resp struct {
+			Response struct {
+				Error struct{ Code string }
+			}
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp.Response.Error.Code, w.Body.String()
+	}
+
+	convey.Convey("always", t, func() {
+		code, _ := do("/always", "{}", nil)
+		convey.So(code, convey.ShouldEqual, "CaptchaRequired")
+
+		code, _ = do("/always", "{}", map[string]string{HeaderCaptchaTicket: "t1", HeaderCaptchaRandstr: "bad"})
+		convey.So(code, convey.ShouldEqual, "CaptchaFailed")
+
+		code, body := do("/always", "{}", map[string]string{HeaderCaptchaTicket: "t1", HeaderCaptchaRandstr: "ok"})
+		convey.So(code, convey.ShouldBeEmpty)
+		convey.So(body, convey.ShouldEqual, "{}")
+		convey.So(verifiedIP, convey.ShouldEqual, "10.0.0.1")
+
+		// 票据不能重复使用
+		code, _ = do("/always", "{}", map[string]string{HeaderCaptchaTicket: "t1", HeaderCaptchaRandstr: "ok"})
+		convey.So(code, convey.ShouldEqual, "CaptchaFailed")
+	})
+
+	convey.Convey("ticket in body", t, func() {
+		reqBody := `{"CaptchaTicket":"t2","CaptchaRandstr":"ok","Name":"a"}`
+		code, body := do("/always", reqBody, nil)
+		convey.So(code, convey.ShouldBeEmpty)
+		convey.So(body, convey.ShouldEqual, reqBody)
+	})
+
+	convey.Convey("risk", t, func() {
+		_, body := do("/risk", "{}", nil)
+		convey.So(body, convey.ShouldEqual, "failed")
+		_, body = do("/risk", "{}", nil)
+		convey.So(body, convey.ShouldEqual, "failed")
+		code, _ := do("/risk", "{}", nil)
+		convey.So(code, convey.ShouldEqual, "CaptchaRequired")
+		code, _ = do("/risk", "{}", map[string]string{HeaderCaptchaTicket: "t3", HeaderCaptchaRandstr: "ok"})
+		convey.So(code, convey.ShouldBeEmpty)
+	})
+
+	convey.Convey("unconfigured route", t, func() {
+		code, _ := do("/open", "{}", nil)
+		convey.So(code, convey.ShouldBeEmpty)
+	})
+}
+
+func Test_FailureCounter(t *testing.T) {
+	f := &failureCounter{counts: make(map[string]*failureCount)}
+	now := time.Now()
+	convey.Convey("fixed window", t, func() {
+		f.incr("ip:1", now, time.Minute)
+		f.incr("ip:1", now.Add(30*time.Second), time.Minute)
+		convey.So(f.get("ip:1", now.Add(59*time.Second)), convey.ShouldEqual, 2)
+		convey.So(f.get("ip:1", now.Add(time.Minute)), convey.ShouldEqual, 0)
+		f.cleanup(now.Add(time.Minute))
+		convey.So(f.counts, convey.ShouldBeEmpty)
+	})
+}
@@ -0,0 +1,221 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"ginfra/config"
+	"ginfra/utils"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+//CorsPolicy 跨域策略配置
+type CorsPolicy struct {
+	Prefix        string        // 路由前缀，仅分组策略使用
+	Origins       []string      // 允许的Origin，支持精确匹配和 https://*.qq.com 形式的子域名匹配
+	Methods       []string      // 允许的Method
+	Headers       []string      // 允许的请求头
+	ExposeHeaders []string      // 暴露给前端的响应头
+	Credentials   *bool         // 是否允许携带Cookie
+	MaxAge        time.Duration // 预检请求缓存时间
+}
+
+// defaultCorsPolicy 默认跨域策略，每次返回新的切片
+func defaultCorsPolicy() *CorsPolicy {
+	return &CorsPolicy{
+		Methods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD"},
+		Headers: []string{"Origin", "Content-Type", "Content-Length", "Accept", "Authorization", "X-Request-Id",
+			HeaderTokenName, HeaderSecretIdName, HeaderSecretKeyName},
+		ExposeHeaders: []string{"Content-Length", "X-Request-Id", HeaderRenewedTokenName},
+		MaxAge:        12 * time.Hour,
+	}
+}
+
+//LoadCorsPolicies 从配置文件加载默认跨域策略(cors)及分组策略(cors.groups)
+// 配置的列表替换默认值而不是追加，未配置的字段使用默认值
+func LoadCorsPolicies(cfg *config.Config) (*CorsPolicy, []*CorsPolicy, error) {
+	// 解码到零值，mapstructure会将配置合并到已有的切片中
+	var def CorsPolicy
+	if err := cfg.UnmarshalKey("cors", &def); err != nil {
+		return nil, nil, err
+	}
+	def.inherit(defaultCorsPolicy())
+
+	var groups []*CorsPolicy
+	if err := cfg.UnmarshalKey("cors.groups", &groups); err != nil {
+		return nil, nil, err
+	}
+	for _, g := range groups {
+		if len(g.Prefix) == 0 {
+			return nil, nil, errors.New("cors group prefix is empty")
+		}
+		g.inherit(&def)
+	}
+	return &def, groups, nil
+}
+
+// inherit 分组策略未配置的字段继承默认策略
+func (p *CorsPolicy) inherit(def *CorsPolicy) {
+	if len(p.Origins) == 0 {
+		p.Origins = def.Origins
+	}
+	if len(p.Methods) == 0 {
+		p.Methods = def.Methods
+	}
+	if len(p.Headers) == 0 {
+		p.Headers = def.Headers
+	}
+	if len(p.ExposeHeaders) == 0 {
+		p.ExposeHeaders = def.ExposeHeaders
+	}
+	if p.Credentials == nil {
+		p.Credentials = def.Credentials
+	}
+	if p.MaxAge == 0 {
+		p.MaxAge = def.MaxAge
+	}
+}
+
+func (p *CorsPolicy) allowCredentials() bool {
+	return p.Credentials != nil && *p.Credentials
+}
+
+// handler 根据策略生成gin-contrib/cors中间件
+func (p *CorsPolicy) handler() (gin.HandlerFunc, error) {
+	matcher, err := NewOriginMatcher(p.Origins)
+	if err != nil {
+		return nil, err
+	}
+	if matcher.any && p.allowCredentials() {
+		return nil, fmt.Errorf("cors %s: origin * is not allowed with credentials", p.Prefix)
+	}
+	if utils.StringInSlice("*", p.Headers) && p.allowCredentials() {
+		return nil, fmt.Errorf("cors %s: header * is not allowed with credentials", p.Prefix)
+	}
+
+	return cors.New(cors.Config{
+		AllowOriginFunc:  matcher.Match,
+		AllowMethods:     p.Methods,
+		AllowHeaders:     p.Headers,
+		ExposeHeaders:    p.ExposeHeaders,
+		AllowCredentials: p.allowCredentials(),
+		MaxAge:           p.MaxAge,
+	}), nil
+}
+
+// Cors 中间件，按请求路径最长前缀选择分组策略，未命中时使用默认策略
+func Cors(def *CorsPolicy, groups []*CorsPolicy) (gin.HandlerFunc, error) {
+	defHandler, err := def.handler()
+	if err != nil {
+		return nil, err
+	}
+
+	// 前缀长的优先匹配
+	sorted := make([]*CorsPolicy, len(groups))
+	copy(sorted, groups)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(sorted[i].Prefix) > len(sorted[j].Prefix)
+	})
+	handlers := make([]gin.HandlerFunc, len(sorted))
+	for i, g := range sorted {
+		handlers[i], err = g.handler()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		for i, g := range sorted {
+			if strings.HasPrefix(path, g.Prefix) {
+				handlers[i](c)
+				return
+			}
+		}
+		defHandler(c)
+	}, nil
+}
+
+//OriginMatcher Origin匹配器
+type OriginMatcher struct {
+	any       bool
+	exact     map[string]bool
+	wildcards []wildcardOrigin
+}
+
+type wildcardOrigin struct {
+	scheme string
+	suffix string // 以 . 开头的域名后缀，如 .qq.com
+	port   string
+}
+
+//NewOriginMatcher 创建Origin匹配器
+// 支持三种写法：* 表示任意Origin；https://www.qq.com 精确匹配；https://*.qq.com 匹配qq.com的任意子域名(不含qq.com本身)
+func NewOriginMatcher(origins []string) (*OriginMatcher, error) {
+	m := &OriginMatcher{exact: make(map[string]bool)}
+	for _, o := range origins {
+		o = strings.TrimSpace(o)
+		if o == "*" {
+			m.any = true
+			continue
+		}
+
+		u, err := url.Parse(strings.TrimSuffix(o, "/"))
+		if err != nil || len(u.Scheme) == 0 || len(u.Host) == 0 {
+			return nil, fmt.Errorf("invalid cors origin: %s", o)
+		}
+		if len(u.Path) > 0 || len(u.RawQuery) > 0 {
+			return nil, fmt.Errorf("cors origin should not contain path: %s", o)
+		}
+
+		scheme := strings.ToLower(u.Scheme)
+		host := strings.ToLower(u.Hostname())
+		if strings.HasPrefix(host, "*.") {
+			if strings.Contains(host[2:], "*") || !strings.Contains(host[2:], ".") {
+				return nil, fmt.Errorf("invalid cors wildcard origin: %s", o)
+			}
+			m.wildcards = append(m.wildcards, wildcardOrigin{
+				scheme: scheme,
+				suffix: host[1:],
+				port:   u.Port(),
+			})
+			continue
+		}
+		if strings.Contains(host, "*") {
+			return nil, fmt.Errorf("invalid cors wildcard origin: %s", o)
+		}
+		m.exact[scheme+"://"+strings.ToLower(u.Host)] = true
+	}
+	return m, nil
+}
+
+//Match 判断Origin是否允许跨域
+func (m *OriginMatcher) Match(origin string) bool {
+	if m.any {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil || len(u.Scheme) == 0 || len(u.Host) == 0 {
+		return false
+	}
+	scheme := strings.ToLower(u.Scheme)
+	if m.exact[scheme+"://"+strings.ToLower(u.Host)] {
+		return true
+	}
+
+	host := strings.ToLower(u.Hostname())
+	for _, w := range m.wildcards {
+		if w.scheme == scheme && w.port == u.Port() &&
+			len(host) > len(w.suffix) && strings.HasSuffix(host, w.suffix) {
+			return true
+		}
+	}
+	return false
+}
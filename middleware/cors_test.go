@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"strings"
+	"testing"
+
+	"ginfra/config"
+
+	"github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/viper"
+)
+
+// testConfig 从yaml内容创建配置，用于测试
+func testConfig(t *testing.T, content string) *config.Config {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	if err := v.ReadConfig(strings.NewReader(content)); err != nil {
+		t.Fatal(err)
+	}
+	return &config.Config{Viper: v}
+}
+
+func Test_OriginMatcher(t *testing.T) {
+	m, err := NewOriginMatcher([]string{"https://www.qq.com/", "https://*.qq.com", "http://localhost:8080"})
+	convey.Convey("NewOriginMatcher", t, func() {
+		convey.So(err, convey.ShouldBeNil)
+	})
+
+	convey.Convey("OriginMatcher.Match", t, func() {
+		convey.So(m.Match("https://www.qq.com"), convey.ShouldBeTrue)
+		convey.So(m.Match("https://a.b.qq.com"), convey.ShouldBeTrue)
+		convey.So(m.Match("http://localhost:8080"), convey.ShouldBeTrue)
+
+		convey.So(m.Match("https://qq.com"), convey.ShouldBeFalse)
+		convey.So(m.Match("https://evilqq.com"), convey.ShouldBeFalse)
+		convey.So(m.Match("https://qq.com.evil.com"), convey.ShouldBeFalse)
+		convey.So(m.Match("http://www.qq.com"), convey.ShouldBeFalse)
+		convey.So(m.Match("https://www.qq.com:8443"), convey.ShouldBeFalse)
+		convey.So(m.Match("http://localhost:8081"), convey.ShouldBeFalse)
+		convey.So(m.Match("null"), convey.ShouldBeFalse)
+	})
+
+	convey.Convey("NewOriginMatcher invalid", t, func() {
+		_, err := NewOriginMatcher([]string{"https://*"})
+		convey.So(err, convey.ShouldNotBeNil)
+		_, err = NewOriginMatcher([]string{"https://a.*.com"})
+		convey.So(err, convey.ShouldNotBeNil)
+		_, err = NewOriginMatcher([]string{"www.qq.com"})
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+}
+
+func Test_CorsCredentialsWildcard(t *testing.T) {
+	credentials := true
+	_, err := Cors(&CorsPolicy{Origins: []string{"*"}, Credentials: &credentials}, nil)
+	convey.Convey("Cors rejects * origin with credentials", t, func() {
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+}
+
+func Test_LoadCorsPolicies(t *testing.T) {
+	convey.Convey("configured lists replace defaults", t, func() {
+		def, groups, err := LoadCorsPolicies(testConfig(t, `
+cors:
+  origins: [https://www.qq.com]
+  methods: [GET]
+  groups:
+    - prefix: /api
+      headers: [Content-Type]
+`))
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(def.Methods, convey.ShouldResemble, []string{"GET"})
+		convey.So(def.Headers, convey.ShouldContain, HeaderTokenName)
+		convey.So(groups, convey.ShouldHaveLength, 1)
+		convey.So(groups[0].Methods, convey.ShouldResemble, []string{"GET"})
+		convey.So(groups[0].Headers, convey.ShouldResemble, []string{"Content-Type"})
+
+		// 默认值不被修改
+		def, _, err = LoadCorsPolicies(testConfig(t, "cors:\n  origins: [https://www.qq.com]\n"))
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(def.Methods, convey.ShouldResemble, defaultCorsPolicy().Methods)
+	})
+}
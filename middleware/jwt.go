@@ -1,11 +1,10 @@
 package middleware
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
-	"path/filepath"
 	"time"
 
 	"ginfra/config"
@@ -16,13 +15,14 @@ import (
 
 	"github.com/dgrijalva/jwt-go/v4"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
 
 var (
-	RS256PublicKey  []byte = []byte{}
-	RS256PrivateKey []byte = []byte{}
 	JWTExpires      int64
+	AccessExpires   int64
+	RefreshExpires  int64
 	JWTIssuer       string
 	HeaderTokenName string
 	CookieTokenName string
@@ -34,21 +34,21 @@ func init() {
 		panic(err)
 	}
 
-	// init
-	RS256KeyDir := cfg.GetString("jwt.RS256KeyDir")
-	privateKeyFile := filepath.Join(RS256KeyDir, "rs256.key")
-	RS256PrivateKey, err = ioutil.ReadFile(privateKeyFile)
-	if utils.Exists(privateKeyFile) && err != nil {
-		panic(fmt.Errorf("read private key file %s error:%s", privateKeyFile, err.Error()))
-	}
-
-	publicKeyFile := filepath.Join(RS256KeyDir, "rs256.key.pub")
-	RS256PublicKey, err = ioutil.ReadFile(publicKeyFile)
-	if utils.Exists(publicKeyFile) && err != nil {
-		panic(fmt.Errorf("read public key file %s error:%s", publicKeyFile, err.Error()))
+	// 签名密钥，支持热加载以便轮换
+	if err := loadKeys(cfg); err != nil {
+		panic(err)
 	}
+	cfg.OnChange(func() {
+		if err := loadKeys(cfg); err != nil {
+			log.WithContext(context.Background()).Error("reload jwt keys fail", zap.String("error", err.Error()))
+		}
+	})
 
 	JWTExpires = cfg.GetInt64("jwt.jwtexpires")
+	cfg.SetDefault("jwt.accessexpires", 900)
+	cfg.SetDefault("jwt.refreshexpires", 30*24*3600)
+	AccessExpires = cfg.GetInt64("jwt.accessexpires")
+	RefreshExpires = cfg.GetInt64("jwt.refreshexpires")
 	JWTIssuer = cfg.GetString("jwt.jwtissuer")
 	cfg.SetDefault("jwt.headername", "token")
 	cfg.SetDefault("jwt.cookiename", "token")
@@ -63,8 +63,10 @@ func JWTAuth(claimHandler HandleClaimFunc) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var err error
 		var token string
+		fromCookie := false
 		token = c.Request.Header.Get(HeaderTokenName)
 		if token == "" {
+			fromCookie = true
 			token, err = c.Cookie(CookieTokenName)
 			if err != nil {
 				log.WithGinContext(c).Error("JWTAuth no token")
@@ -89,6 +91,21 @@ func JWTAuth(claimHandler HandleClaimFunc) gin.HandlerFunc {
 			return
 		}
 
+		// 检查token是否已吊销
+		if err := checkRevoked(c, claims.ID); err != nil {
+			protocol.SetErrResponse(c, err)
+			c.Abort()
+			return
+		}
+		// 检查所属会话是否已结束
+		if len(claims.SessionID) > 0 {
+			if err := checkRevoked(c, sessionRevocationKey(claims.SessionID)); err != nil {
+				protocol.SetErrResponse(c, err)
+				c.Abort()
+				return
+			}
+		}
+
 		// 解析到具体的claims相关信息
 		//c.Set("claims", claims)
 		err = claimHandler(c, claims)
@@ -98,9 +115,68 @@ func JWTAuth(claimHandler HandleClaimFunc) gin.HandlerFunc {
 			c.Abort()
 			return
 		}
+
+		// 检查用户登录态水位，需在claimHandler设置用户ID之后
+		if err := checkWatermark(c, claims.IssuedAt); err != nil {
+			protocol.SetErrResponse(c, err)
+			c.Abort()
+			return
+		}
+		c.Set(protocol.CtxAuthType, protocol.AuthTypeJWT)
+		c.Set(protocol.CtxTokenID, claims.ID)
+		if expiresAt := tokenIDExpiresAt(claims); !expiresAt.IsZero() {
+			c.Set(protocol.CtxTokenExpiresAt, expiresAt)
+		}
+		if claims.AuthTime != nil {
+			c.Set(protocol.CtxAuthTime, claims.AuthTime.Time)
+		}
+		c.Set(protocol.CtxMFALevel, claims.MFA)
+		if claims.MFATime != nil {
+			c.Set(protocol.CtxMFATime, claims.MFATime.Time)
+		}
+		if len(claims.SessionID) > 0 {
+			c.Set(protocol.CtxSessionID, claims.SessionID)
+			TouchSession(claims.SessionID, c.ClientIP(), time.Now())
+		}
+
+		// 临近过期时续期
+		renewToken(c, claims, fromCookie)
 	}
 }
 
+func checkRevoked(c *gin.Context, jti string) error {
+	if len(jti) == 0 {
+		return nil
+	}
+	revoked, err := TokenRevocation.IsRevoked(c.Request.Context(), jti)
+	if err != nil {
+		log.WithGinContext(c).Error("JWTAuth check revoked token fail", zap.String("error", err.Error()))
+		return errcode.NewCustomError(errcode.ErrCodeInternalError, "check auth token fail")
+	}
+	if revoked {
+		log.WithGinContext(c).Info("JWTAuth revoked token", zap.String("jti", jti))
+		return errcode.NewCustomError(errcode.ErrRevokedAuthToken, "revoked auth token")
+	}
+	return nil
+}
+
+func checkWatermark(c *gin.Context, issuedAt *jwt.Time) error {
+	uid := protocol.GetUserId(c)
+	if len(uid) == 0 {
+		return nil
+	}
+	watermark, err := TokenRevocation.Watermark(c.Request.Context(), uid)
+	if err != nil {
+		log.WithGinContext(c).Error("JWTAuth check token watermark fail", zap.String("error", err.Error()))
+		return errcode.NewCustomError(errcode.ErrCodeInternalError, "check auth token fail")
+	}
+	if issuedBeforeWatermark(issuedAt, watermark) {
+		log.WithGinContext(c).Info("JWTAuth token issued before watermark", zap.Time("watermark", watermark))
+		return errcode.NewCustomError(errcode.ErrRevokedAuthToken, "revoked auth token")
+	}
+	return nil
+}
+
 //GenerateToken 生成登录态token
 func GenerateToken(claims interface{}, expires int64) (string, error) {
 	b, err := json.Marshal(claims)
@@ -111,10 +187,7 @@ func GenerateToken(claims interface{}, expires int64) (string, error) {
 	// 构造用户claims信息(负荷)
 
 	// 根据claims生成token对象
-	token, err := utils.CreateJWTTokenWithRS256(
-		RS256PrivateKey,
-		NewCustomClaims(b, expires),
-	)
+	token, err := Keys().Sign(NewCustomClaims(b, expires))
 	if err != nil {
 		return "", err
 	}
@@ -123,15 +196,12 @@ func GenerateToken(claims interface{}, expires int64) (string, error) {
 
 //ParseToken 解析登录态Token
 func ParseToken(token string) (claims *utils.CustomClaims, err error) {
-	return utils.ParseJWTTokenWithRS256(RS256PublicKey, token)
+	return Keys().Parse(token)
 }
 
 //GenerateSignature 生成签名串
 func GenerateSignature(b []byte, expires int64) (string, error) {
-	sig, err := utils.CreateJWTTokenWithRS256(
-		RS256PrivateKey,
-		NewCustomClaims(b, expires),
-	)
+	sig, err := Keys().Sign(NewCustomClaims(b, expires))
 	if err != nil {
 		return "", err
 	}
@@ -140,7 +210,7 @@ func GenerateSignature(b []byte, expires int64) (string, error) {
 
 //VerifySignature 校验签名串
 func VerifySignature(sig string) ([]byte, error) {
-	claims, err := utils.ParseJWTTokenWithRS256(RS256PublicKey, sig)
+	claims, err := Keys().Parse(sig)
 	if err != nil {
 		return []byte{}, err
 	}
@@ -164,8 +234,11 @@ func NewCustomClaims(data []byte, expires int64) *utils.CustomClaims {
 		expires = JWTExpires
 	}
 	return &utils.CustomClaims{
-		Data: data,
+		Data:     data,
+		AuthTime: jwt.Now(), // 登录时间
 		StandardClaims: jwt.StandardClaims{
+			ID:        uuid.New().String(),                                          // 唯一标识，用于吊销
+			IssuedAt:  jwt.Now(),                                                    // 签发时间，用于用户登录态水位
 			NotBefore: jwt.At(time.Now().Add(-1 * time.Hour)),                       // 签名生效时间
 			ExpiresAt: jwt.At(time.Now().Add(time.Duration(expires) * time.Second)), // 签名过期时间
 			Issuer:    JWTIssuer,                                                    // 签名颁发者
@@ -216,26 +289,28 @@ func (j *JWT) ParserToken(tokenString string) (*utils.CustomClaims, error) {
 }
 
 // 更新Token
+// 不修改全局的jwt.TimeFunc，跳过库的claims校验后以当前时间显式校验
 func (j *JWT) UpdateToken(tokenString string) (string, error) {
-	// TimeFunc为一个默认值是time.Now的当前时间变量,用来解析token后进行过期时间验证
-	// 可以使用其他的时间值来覆盖
-	jwt.TimeFunc = func() time.Time {
-		return time.Unix(0, 0)
-	}
-
 	// 拿到token基础数据
 	token, err := jwt.ParseWithClaims(tokenString, &utils.CustomClaims{}, func(token *jwt.Token) (interface{}, error) {
 		return j.SigningKey, nil
-
-	})
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithoutClaimsValidation())
+	if err != nil {
+		return "", fmt.Errorf("token获取失败:%v", err)
+	}
 
 	// 校验token当前还有效
-	if claims, ok := token.Claims.(*utils.CustomClaims); ok && token.Valid {
-		jwt.TimeFunc = time.Now
-		// 修改Claims的过期时间(int64)
-		// https://gowalker.org/github.com/dgrijalva/jwt-go#StandardClaims
-		claims.StandardClaims.ExpiresAt = jwt.At(time.Now().Add(1 * time.Hour))
-		return j.CreateToken(*claims)
+	claims, ok := token.Claims.(*utils.CustomClaims)
+	if !ok || !token.Valid {
+		return "", errors.New("Token无效")
 	}
-	return "", fmt.Errorf("token获取失败:%v", err)
+	now := time.Now()
+	if err := utils.ValidateTimeClaims(&claims.StandardClaims, now, 0); err != nil {
+		return "", fmt.Errorf("token获取失败:%v", err)
+	}
+
+	// 修改Claims的过期时间
+	// https://gowalker.org/github.com/dgrijalva/jwt-go#StandardClaims
+	claims.StandardClaims.ExpiresAt = jwt.At(now.Add(1 * time.Hour))
+	return j.CreateToken(*claims)
 }
@@ -0,0 +1,307 @@
+package middleware
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"ginfra/config"
+	"ginfra/utils"
+
+	"github.com/dgrijalva/jwt-go/v4"
+)
+
+// 密钥状态
+const (
+	KeyStatusActive = "active" // 用于签发及校验，有且只有一个
+	KeyStatusVerify = "verify" // 仅用于校验，轮换前后的密钥
+)
+
+// 未配置jwt.keys时兼容单密钥rs256.key，kid为rs256
+const legacyKid = "rs256"
+
+// 默认允许的签名算法
+var defaultAlgorithms = []string{"RS256", "ES256", "EdDSA"}
+
+//KeyConfig 签名密钥配置，密钥文件为 密钥目录/<kid>.key 及 <kid>.key.pub
+// 签名算法按密钥类型选择：RSA为RS256，EC P-256为ES256，Ed25519为EdDSA，可通过Alg指定同类型的其他算法如RS512
+type KeyConfig struct {
+	Kid    string
+	Status string
+	Alg    string
+}
+
+//SigningKey 签名密钥
+type SigningKey struct {
+	Kid        string
+	Status     string
+	Method     jwt.SigningMethod
+	PrivateKey interface{} // 仅active密钥需要
+	PublicKey  interface{}
+}
+
+//KeySet 签名密钥集合，按kid选择校验密钥
+type KeySet struct {
+	keys       map[string]*SigningKey
+	active     *SigningKey
+	algorithms []string // 允许的签名算法，防止alg混淆
+}
+
+var jwtKeys atomic.Value
+
+//Keys 当前使用的签名密钥集合
+func Keys() *KeySet {
+	return jwtKeys.Load().(*KeySet)
+}
+
+func loadKeys(cfg *config.Config) error {
+	var keys []KeyConfig
+	if err := cfg.UnmarshalKey("jwt.keys", &keys); err != nil {
+		return err
+	}
+	cfg.SetDefault("jwt.algorithms", defaultAlgorithms)
+	ks, err := LoadKeySet(cfg.GetString("jwt.RS256KeyDir"), keys, cfg.GetStringSlice("jwt.algorithms"))
+	if err != nil {
+		return err
+	}
+	jwtKeys.Store(ks)
+	return nil
+}
+
+//LoadKeySet 从密钥目录加载密钥，keys为空时加载rs256.key单密钥；algorithms为允许的签名算法
+func LoadKeySet(dir string, keys []KeyConfig, algorithms []string) (*KeySet, error) {
+	ks := &KeySet{keys: make(map[string]*SigningKey), algorithms: algorithms}
+	if len(keys) == 0 {
+		// 单密钥文件不存在时返回空集合，签发token时报错
+		if !utils.Exists(filepath.Join(dir, legacyKid+".key")) &&
+			!utils.Exists(filepath.Join(dir, legacyKid+".key.pub")) {
+			return ks, nil
+		}
+		keys = []KeyConfig{{Kid: legacyKid, Status: KeyStatusActive}}
+	}
+
+	for _, kc := range keys {
+		if len(kc.Kid) == 0 {
+			return nil, errors.New("jwt key kid is empty")
+		}
+		if _, ok := ks.keys[kc.Kid]; ok {
+			return nil, fmt.Errorf("jwt key %s duplicated", kc.Kid)
+		}
+		key, err := loadSigningKey(dir, kc)
+		if err != nil {
+			return nil, err
+		}
+		if !utils.StringInSlice(key.Method.Alg(), algorithms) {
+			return nil, fmt.Errorf("jwt key %s algorithm %s is not allowed", key.Kid, key.Method.Alg())
+		}
+		if key.Status == KeyStatusActive {
+			if ks.active != nil {
+				return nil, fmt.Errorf("jwt key %s and %s are both active", ks.active.Kid, key.Kid)
+			}
+			ks.active = key
+		}
+		ks.keys[key.Kid] = key
+	}
+	if ks.active == nil {
+		return nil, errors.New("no active jwt key")
+	}
+	return ks, nil
+}
+
+func loadSigningKey(dir string, kc KeyConfig) (*SigningKey, error) {
+	key := &SigningKey{Kid: kc.Kid, Status: kc.Status}
+	if key.Status != KeyStatusActive && key.Status != KeyStatusVerify {
+		return nil, fmt.Errorf("jwt key %s invalid status: %s", kc.Kid, kc.Status)
+	}
+
+	privateKeyFile := filepath.Join(dir, kc.Kid+".key")
+	if utils.Exists(privateKeyFile) {
+		b, err := ioutil.ReadFile(privateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read private key file %s error:%s", privateKeyFile, err.Error())
+		}
+		privateKey, err := utils.ParsePrivateKeyFromPEM(b)
+		if err != nil {
+			return nil, fmt.Errorf("parse private key file %s error:%s", privateKeyFile, err.Error())
+		}
+		key.PrivateKey = privateKey
+		key.PublicKey = privateKey.Public()
+	} else if key.Status == KeyStatusActive {
+		return nil, fmt.Errorf("active jwt key %s has no private key file", kc.Kid)
+	}
+
+	publicKeyFile := filepath.Join(dir, kc.Kid+".key.pub")
+	if key.PublicKey == nil && utils.Exists(publicKeyFile) {
+		b, err := ioutil.ReadFile(publicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read public key file %s error:%s", publicKeyFile, err.Error())
+		}
+		publicKey, err := utils.ParsePublicKeyFromPEM(b)
+		if err != nil {
+			return nil, fmt.Errorf("parse public key file %s error:%s", publicKeyFile, err.Error())
+		}
+		key.PublicKey = publicKey
+	}
+	if key.PublicKey == nil {
+		return nil, fmt.Errorf("jwt key %s has no key file", kc.Kid)
+	}
+
+	method, err := utils.SigningMethodForKey(key.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("jwt key %s: %s", kc.Kid, err.Error())
+	}
+	if len(kc.Alg) > 0 && kc.Alg != method.Alg() {
+		// 指定算法需与密钥类型一致，如RSA密钥可指定RS384、RS512、PS256
+		m := jwt.GetSigningMethod(kc.Alg)
+		if m == nil || !compatibleMethod(m, method) {
+			return nil, fmt.Errorf("jwt key %s algorithm %s does not match key type", kc.Kid, kc.Alg)
+		}
+		method = m
+	}
+	key.Method = method
+	return key, nil
+}
+
+func compatibleMethod(m, base jwt.SigningMethod) bool {
+	switch base.(type) {
+	case *jwt.SigningMethodRSA:
+		switch m.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+			return true
+		}
+	}
+	// EC曲线与算法一一对应，Ed25519只有EdDSA
+	return false
+}
+
+//Sign 使用active密钥签发token，header中携带kid
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	return ks.SignWithType(claims, "")
+}
+
+//SignWithType 使用active密钥签发token，typ不为空时写入header，用于区分token用途，如OAuth access token为at+jwt
+func (ks *KeySet) SignWithType(claims jwt.Claims, typ string) (string, error) {
+	if ks.active == nil {
+		return "", errors.New("no active jwt key")
+	}
+	token := jwt.NewWithClaims(ks.active.Method, claims)
+	token.Header["kid"] = ks.active.Kid
+	if len(typ) > 0 {
+		token.Header["typ"] = typ
+	}
+	return token.SignedString(ks.active.PrivateKey)
+}
+
+//Algorithm active密钥的签名算法
+func (ks *KeySet) Algorithm() string {
+	if ks.active == nil {
+		return ""
+	}
+	return ks.active.Method.Alg()
+}
+
+//Keyfunc 按token header中的kid选择校验密钥，未携带kid的旧token使用active密钥
+func (ks *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	key := ks.active
+	if kid, ok := token.Header["kid"].(string); ok {
+		key = ks.keys[kid]
+	}
+	if key == nil {
+		return nil, fmt.Errorf("unknown jwt key: %v", token.Header["kid"])
+	}
+	// 防止alg混淆，token的签名算法必须与密钥一致
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected jwt signing method: %s", token.Method.Alg())
+	}
+	return key.PublicKey, nil
+}
+
+//Parse 解析并校验token
+func (ks *KeySet) Parse(tokenStr string) (*utils.CustomClaims, error) {
+	return ks.ParseAt(tokenStr, time.Now())
+}
+
+//ParseAt 解析token，并以now为当前时间校验exp、nbf
+func (ks *KeySet) ParseAt(tokenStr string, now time.Time) (*utils.CustomClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenStr, &utils.CustomClaims{}, ks.Keyfunc,
+		jwt.WithValidMethods(ks.algorithms), jwt.WithoutClaimsValidation())
+	if err != nil {
+		return nil, err
+	}
+	claims, ok := token.Claims.(*utils.CustomClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("Token无效")
+	}
+	if err := utils.ValidateTimeClaims(&claims.StandardClaims, now, 0); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+//JSONWebKey JWKS中的公钥，见RFC 7517
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+//JSONWebKeySet JWKS
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+//JWKS 导出全部公钥，供下游服务校验token
+func (ks *KeySet) JWKS() *JSONWebKeySet {
+	set := &JSONWebKeySet{Keys: make([]JSONWebKey, 0, len(ks.keys))}
+	for _, key := range ks.keys {
+		jwk := JSONWebKey{Kid: key.Kid, Use: "sig", Alg: key.Method.Alg()}
+		switch pub := key.PublicKey.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case *ecdsa.PublicKey:
+			// 坐标按曲线长度补齐，见RFC 7518 6.2.1
+			size := (pub.Curve.Params().BitSize + 7) / 8
+			jwk.Kty = "EC"
+			jwk.Crv = pub.Curve.Params().Name
+			jwk.X = base64.RawURLEncoding.EncodeToString(leftPad(pub.X.Bytes(), size))
+			jwk.Y = base64.RawURLEncoding.EncodeToString(leftPad(pub.Y.Bytes(), size))
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	sort.Slice(set.Keys, func(i, j int) bool {
+		return set.Keys[i].Kid < set.Keys[j].Kid
+	})
+	return set
+}
+
+func leftPad(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b
+	}
+	padded := make([]byte, size)
+	copy(padded[size-len(b):], b)
+	return padded
+}
@@ -0,0 +1,139 @@
+package middleware
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"ginfra/utils"
+
+	"github.com/dgrijalva/jwt-go/v4"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func writeRSAKey(t *testing.T, dir, kid string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := ioutil.WriteFile(filepath.Join(dir, kid+".key"), b, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writePKCS8Key(t *testing.T, dir, kid string, key interface{}) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := ioutil.WriteFile(filepath.Join(dir, kid+".key"), b, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_KeySetAlgorithms(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jwtkeys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	writePKCS8Key(t, dir, "ec", ecKey)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	writePKCS8Key(t, dir, "ed", edKey)
+
+	convey.Convey("ES256 and EdDSA", t, func() {
+		for kid, alg := range map[string]string{"ec": "ES256", "ed": "EdDSA"} {
+			keys, err := LoadKeySet(dir, []KeyConfig{{Kid: kid, Status: KeyStatusActive}}, defaultAlgorithms)
+			convey.So(err, convey.ShouldBeNil)
+			token, err := keys.Sign(NewCustomClaims([]byte("{}"), 60))
+			convey.So(err, convey.ShouldBeNil)
+			parsed, _ := jwt.Parse(token, keys.Keyfunc)
+			convey.So(parsed.Header["alg"], convey.ShouldEqual, alg)
+			_, err = keys.Parse(token)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(keys.JWKS().Keys[0].X, convey.ShouldNotBeEmpty)
+		}
+	})
+
+	convey.Convey("algorithm allowlist", t, func() {
+		_, err := LoadKeySet(dir, []KeyConfig{{Kid: "ed", Status: KeyStatusActive}}, []string{"RS256"})
+		convey.So(err, convey.ShouldNotBeNil)
+		_, err = LoadKeySet(dir, []KeyConfig{{Kid: "ec", Status: KeyStatusActive, Alg: "RS256"}}, defaultAlgorithms)
+		convey.So(err, convey.ShouldNotBeNil)
+
+		// 以ec的kid声明EdDSA算法，应被拒绝
+		keys, _ := LoadKeySet(dir, []KeyConfig{
+			{Kid: "ec", Status: KeyStatusActive},
+			{Kid: "ed", Status: KeyStatusVerify},
+		}, defaultAlgorithms)
+		forged := jwt.NewWithClaims(utils.SigningMethodEd25519, NewCustomClaims([]byte("{}"), 60))
+		forged.Header["kid"] = "ec"
+		token, _ := forged.SignedString(edKey)
+		_, err = keys.Parse(token)
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+}
+
+func Test_KeySet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jwtkeys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeRSAKey(t, dir, "old")
+	writeRSAKey(t, dir, "new")
+
+	oldKeys, err := LoadKeySet(dir, []KeyConfig{{Kid: "old", Status: KeyStatusActive}}, defaultAlgorithms)
+	convey.Convey("LoadKeySet", t, func() {
+		convey.So(err, convey.ShouldBeNil)
+	})
+	oldToken, _ := oldKeys.Sign(NewCustomClaims([]byte("{}"), 60))
+
+	keys, err := LoadKeySet(dir, []KeyConfig{
+		{Kid: "new", Status: KeyStatusActive},
+		{Kid: "old", Status: KeyStatusVerify},
+	}, defaultAlgorithms)
+	convey.Convey("KeySet rotation", t, func() {
+		convey.So(err, convey.ShouldBeNil)
+
+		token, err := keys.Sign(NewCustomClaims([]byte("{}"), 60))
+		convey.So(err, convey.ShouldBeNil)
+		parsed, _ := jwt.Parse(token, keys.Keyfunc)
+		convey.So(parsed.Header["kid"], convey.ShouldEqual, "new")
+
+		_, err = keys.Parse(token)
+		convey.So(err, convey.ShouldBeNil)
+		_, err = keys.Parse(oldToken)
+		convey.So(err, convey.ShouldBeNil)
+
+		jwks := keys.JWKS()
+		convey.So(len(jwks.Keys), convey.ShouldEqual, 2)
+		convey.So(jwks.Keys[0].Kid, convey.ShouldEqual, "new")
+		convey.So(jwks.Keys[0].E, convey.ShouldEqual, "AQAB")
+	})
+
+	convey.Convey("KeySet invalid", t, func() {
+		_, err := LoadKeySet(dir, []KeyConfig{{Kid: "old", Status: KeyStatusVerify}}, defaultAlgorithms)
+		convey.So(err, convey.ShouldNotBeNil)
+		_, err = LoadKeySet(dir, []KeyConfig{
+			{Kid: "new", Status: KeyStatusActive},
+			{Kid: "old", Status: KeyStatusActive},
+		}, defaultAlgorithms)
+		convey.So(err, convey.ShouldNotBeNil)
+
+		newOnly, _ := LoadKeySet(dir, []KeyConfig{{Kid: "new", Status: KeyStatusActive}}, defaultAlgorithms)
+		_, err = newOnly.Parse(oldToken)
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+}
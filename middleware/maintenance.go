@@ -0,0 +1,141 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"ginfra/config"
+	"ginfra/errcode"
+	"ginfra/feature"
+	"ginfra/log"
+	"ginfra/protocol"
+	"ginfra/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	MaintenanceModeOff      = ""            // 正常服务
+	MaintenanceModeReadOnly = "readonly"    // 只读，仅放行ReadOnlyMethods及ReadOnlyRoutes
+	MaintenanceModeFull     = "maintenance" // 维护中，全部拒绝
+)
+
+//MaintenanceConfig 维护模式配置
+type MaintenanceConfig struct {
+	Mode            string
+	Message         string   // 返回给前端的提示
+	RetryAfter      int      // Retry-After响应头，单位秒，0表示不下发
+	ReadOnlyMethods []string // 只读模式下放行的Method
+	ReadOnlyRoutes  []string // 只读模式下放行的读接口路由，如/api/v2/DescribeSessions，接口均为POST时按路由放行
+	AllowRoutes     []string // 放行的路由前缀
+	AllowUsers      []string // 放行的用户
+}
+
+var maintenanceCfg atomic.Value
+
+func init() {
+	cfg, err := config.Parse("")
+	if err != nil {
+		panic(err)
+	}
+
+	if err := loadMaintenanceConfig(cfg); err != nil {
+		panic(err)
+	}
+	cfg.OnChange(func() {
+		if err := loadMaintenanceConfig(cfg); err != nil {
+			log.WithContext(context.Background()).Error("reload maintenance config fail",
+				zap.String("error", err.Error()))
+		}
+	})
+}
+
+func loadMaintenanceConfig(cfg *config.Config) error {
+	// 解码到零值后再设置默认值，mapstructure会将配置追加到已有的切片中
+	mc := &MaintenanceConfig{}
+	if err := cfg.UnmarshalKey("maintenance", mc); err != nil {
+		return err
+	}
+	if len(mc.ReadOnlyMethods) == 0 {
+		mc.ReadOnlyMethods = []string{"GET", "HEAD", "OPTIONS"}
+	}
+	maintenanceCfg.Store(mc)
+	return nil
+}
+
+// readOnlyAllowed 只读模式下请求的Method或路由是否放行，路由按注册的完整路由(含:param)匹配
+func (mc *MaintenanceConfig) readOnlyAllowed(c *gin.Context) bool {
+	if utils.StringInSlice(c.Request.Method, mc.ReadOnlyMethods) {
+		return true
+	}
+	route := c.FullPath()
+	if len(route) == 0 {
+		route = c.Request.URL.Path
+	}
+	return utils.StringInSlice(route, mc.ReadOnlyRoutes)
+}
+
+// Maintenance 中间件，维护模式或只读模式下返回ServiceUnavailable
+// 放在鉴权中间件之后时，AllowUsers中的用户不受限制
+func Maintenance() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		mc := maintenanceCfg.Load().(*MaintenanceConfig)
+		if mc.Mode == MaintenanceModeOff {
+			return
+		}
+		if mc.Mode == MaintenanceModeReadOnly && mc.readOnlyAllowed(c) {
+			return
+		}
+		rejectMaintenance(c, mc)
+	}
+}
+
+// Writes 中间件，标记会写入数据的接口，只读模式下即使Method或路由被放行也拒绝
+// 用于第三方登录回调、OAuth授权等会写库的GET接口，需放在路由的第一个handler
+func Writes() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		mc := maintenanceCfg.Load().(*MaintenanceConfig)
+		if mc.Mode == MaintenanceModeOff {
+			return
+		}
+		rejectMaintenance(c, mc)
+	}
+}
+
+// rejectMaintenance 维护或只读模式下拒绝请求，AllowRoutes及AllowUsers除外
+func rejectMaintenance(c *gin.Context, mc *MaintenanceConfig) {
+	for _, prefix := range mc.AllowRoutes {
+		if len(prefix) > 0 && strings.HasPrefix(c.Request.URL.Path, prefix) {
+			return
+		}
+	}
+	if uid := protocol.GetUserId(c); len(uid) > 0 && utils.StringInSlice(uid, mc.AllowUsers) {
+		return
+	}
+
+	if mc.RetryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(mc.RetryAfter))
+	}
+	err := protocol.ErrCodeServiceUnavailable
+	if len(mc.Message) > 0 {
+		err = errcode.NewCustomError(err.Code, mc.Message)
+	}
+	protocol.SetErrResponse(c, err)
+	c.Abort()
+}
+
+// RequireFeature 中间件，功能开关对当前用户关闭时，按路由不存在处理，用于灰度发布
+// 需放在鉴权中间件之后
+func RequireFeature(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if feature.EnabledFor(c, name) {
+			return
+		}
+		c.String(http.StatusNotFound, "Not Found.")
+		c.Abort()
+	}
+}
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func Test_MaintenanceReadOnly(t *testing.T) {
+	saved := maintenanceCfg.Load()
+	defer maintenanceCfg.Store(saved)
+
+	gin.SetMode(gin.TestMode)
+	g := gin.New()
+	g.Use(Maintenance())
+	ok := func(c *gin.Context) { c.String(http.StatusOK, "ok") }
+	g.GET("/api/v2/Ping", ok)
+	g.HEAD("/api/v2/Ping", ok)
+	g.POST("/api/v2/DescribeSessions", ok)
+	g.POST("/api/v2/ChangePassword", ok)
+	g.POST("/auth/:provider/callback", ok)
+	do := func(method, path string) string {
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w.Body.String()
+	}
+
+	convey.Convey("configured lists replace defaults", t, func() {
+		convey.So(loadMaintenanceConfig(testConfig(t, `
+maintenance:
+  mode: readonly
+  readonlymethods: [GET]
+  readonlyroutes: [/api/v2/DescribeSessions, /auth/:provider/callback]
+`)), convey.ShouldBeNil)
+		mc := maintenanceCfg.Load().(*MaintenanceConfig)
+		convey.So(mc.ReadOnlyMethods, convey.ShouldResemble, []string{"GET"})
+
+		convey.So(do(http.MethodGet, "/api/v2/Ping"), convey.ShouldEqual, "ok")
+		convey.So(do(http.MethodHead, "/api/v2/Ping"), convey.ShouldNotEqual, "ok")
+		convey.So(do(http.MethodPost, "/api/v2/DescribeSessions"), convey.ShouldEqual, "ok")
+		convey.So(do(http.MethodPost, "/auth/github/callback"), convey.ShouldEqual, "ok")
+		convey.So(do(http.MethodPost, "/api/v2/ChangePassword"), convey.ShouldContainSubstring, "ServiceUnavailable")
+	})
+
+	convey.Convey("write routes are rejected in readonly mode", t, func() {
+		g := gin.New()
+		g.Use(Maintenance())
+		g.GET("/auth/:provider/callback", Writes(), ok)
+		convey.So(loadMaintenanceConfig(testConfig(t, "maintenance:\n  mode: readonly\n")), convey.ShouldBeNil)
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/auth/github/callback?code=x", nil))
+		convey.So(w.Body.String(), convey.ShouldContainSubstring, "ServiceUnavailable")
+
+		convey.So(loadMaintenanceConfig(testConfig(t, "maintenance:\n  mode: \"\"\n")), convey.ShouldBeNil)
+		w = httptest.NewRecorder()
+		g.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/auth/github/callback?code=x", nil))
+		convey.So(w.Body.String(), convey.ShouldEqual, "ok")
+	})
+
+	convey.Convey("default methods", t, func() {
+		convey.So(loadMaintenanceConfig(testConfig(t, "maintenance:\n  mode: readonly\n")), convey.ShouldBeNil)
+		convey.So(maintenanceCfg.Load().(*MaintenanceConfig).ReadOnlyMethods, convey.ShouldResemble, []string{"GET", "HEAD", "OPTIONS"})
+		convey.So(do(http.MethodPost, "/api/v2/DescribeSessions"), convey.ShouldContainSubstring, "ServiceUnavailable")
+	})
+}
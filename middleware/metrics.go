@@ -42,6 +42,8 @@ func init() {
 	GRegistry = prometheus.NewRegistry()
 	GRegistry.Register(httpRequestCount)
 	GRegistry.Register(httpRequestDuration)
+	GRegistry.Register(shadowRequestCount)
+	GRegistry.Register(shadowMismatchCount)
 	// GRegistry.Register(prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
 	// GRegistry.Register(prometheus.NewGoCollector())
 
@@ -97,7 +99,7 @@ func Metric() gin.HandlerFunc {
 
 		// 上报ATTA
 		if enableATTA {
-			atta.ReportBackendRequestStatus(attaID, attaToken, protocol.GetUserId(c),
+			atta.ReportBackendRequestStatus(c.Request.Context(), attaID, attaToken, protocol.GetUserId(c),
 				path, protocol.GetResponseCode(c), c.Writer.Status(), int(latency/time.Millisecond))
 		}
 
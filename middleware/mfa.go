@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"encoding/json"
+	"time"
+
+	"ginfra/config"
+	"ginfra/log"
+	"ginfra/protocol"
+
+	"github.com/dgrijalva/jwt-go/v4"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// 二次验证级别，登录态token的mfa claim
+const (
+	MFALevelNone = 0 // 仅登录
+	MFALevelTOTP = 1 // 已通过TOTP或恢复码验证
+)
+
+//MFAMaxAge 二次验证的有效时间，超过后敏感操作需重新验证，0表示在token有效期内一直有效
+var MFAMaxAge time.Duration
+
+func init() {
+	cfg, err := config.Parse("")
+	if err != nil {
+		panic(err)
+	}
+
+	cfg.SetDefault("mfa.maxage", 30*time.Minute)
+	MFAMaxAge = cfg.GetDuration("mfa.maxage")
+}
+
+// RequireMFA 中间件，要求JWT登录态的二次验证级别不低于level且在mfa.maxage内，需放在JWTAuth之后
+// 未满足时返回MFARequired，客户端完成二次验证换取新token后重试
+func RequireMFA(level int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if protocol.GetAuthType(c) != protocol.AuthTypeJWT {
+			log.WithGinContext(c).Error("RequireMFA denied, not jwt")
+			protocol.SetErrResponse(c, protocol.ErrCodeUnAuthorized)
+			c.Abort()
+			return
+		}
+		if c.GetInt(protocol.CtxMFALevel) < level {
+			log.WithGinContext(c).Info("RequireMFA step-up required", zap.Int("level", level))
+			protocol.SetErrResponse(c, protocol.ErrCodeMFARequired)
+			c.Abort()
+			return
+		}
+		if MFAMaxAge > 0 && time.Since(c.GetTime(protocol.CtxMFATime)) > MFAMaxAge {
+			log.WithGinContext(c).Info("RequireMFA step-up expired", zap.Int("level", level))
+			protocol.SetErrResponse(c, protocol.ErrCodeMFARequired)
+			c.Abort()
+			return
+		}
+	}
+}
+
+//GenerateMFAToken 生成通过二次验证的登录态token，沿用原登录时间及会话，authTime为零值时以当前时间为登录时间
+func GenerateMFAToken(claims interface{}, expires int64, authTime time.Time, sid string, level int) (string, error) {
+	b, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	custom := NewCustomClaims(b, expires)
+	if !authTime.IsZero() {
+		custom.AuthTime = jwt.At(authTime)
+	}
+	custom.SessionID = sid
+	custom.MFA = level
+	custom.MFATime = jwt.Now()
+	return Keys().Sign(custom)
+}
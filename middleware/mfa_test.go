@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"ginfra/log"
+	"ginfra/protocol"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smartystreets/goconvey/convey"
+	"go.uber.org/zap"
+)
+
+func Test_RequireMFA(t *testing.T) {
+	oldLogger, oldMaxAge := log.ZLog, MFAMaxAge
+	defer func() { log.ZLog, MFAMaxAge = oldLogger, oldMaxAge }()
+	log.ZLog = zap.NewNop()
+	MFAMaxAge = 10 * time.Minute
+
+	gin.SetMode(gin.TestMode)
+	do := func(authType string, level int, mfaTime time.Time) string {
+		g := gin.New()
+		g.Use(func(c *gin.Context) {
+			c.Set(protocol.CtxAuthType, authType)
+			c.Set(protocol.CtxMFALevel, level)
+			if !mfaTime.IsZero() {
+				c.Set(protocol.CtxMFATime, mfaTime)
+			}
+		}, RequireMFA(MFALevelTOTP))
+		g.POST("/admin", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin", nil))
+		var resp struct {
+			Response struct {
+				Error struct{ Code string }
+			}
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if len(resp.Response.Error.Code) == 0 {
+			return w.Body.String()
+		}
+		return resp.Response.Error.Code
+	}
+
+	convey.Convey("RequireMFA", t, func() {
+		now := time.Now()
+		convey.So(do(protocol.AuthTypeJWT, MFALevelTOTP, now), convey.ShouldEqual, "ok")
+		convey.So(do(protocol.AuthTypeJWT, MFALevelNone, time.Time{}), convey.ShouldEqual, "MFARequired")
+		convey.So(do(protocol.AuthTypeJWT, MFALevelTOTP, now.Add(-time.Hour)), convey.ShouldEqual, "MFARequired")
+		convey.So(do(protocol.AuthTypeApiKey, MFALevelTOTP, now), convey.ShouldEqual, "UnauthorizedOperation")
+	})
+}
+
+func Test_GenerateMFAToken(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jwtkeys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeRSAKey(t, dir, "mfa")
+	keys, _ := LoadKeySet(dir, []KeyConfig{{Kid: "mfa", Status: KeyStatusActive}}, defaultAlgorithms)
+	saved := Keys()
+	jwtKeys.Store(keys)
+	defer jwtKeys.Store(saved)
+
+	RenewWindow, RenewMaxAge = 5*time.Minute, time.Hour
+	defer func() { RenewWindow, RenewMaxAge = 0, 7*24*time.Hour }()
+
+	convey.Convey("GenerateMFAToken", t, func() {
+		authTime := time.Now().Add(-10 * time.Minute).Truncate(time.Second)
+		token, err := GenerateMFAToken(map[string]uint64{"Uid": 1}, 60, authTime, "s1", MFALevelTOTP)
+		convey.So(err, convey.ShouldBeNil)
+		claims, err := ParseToken(token)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(claims.MFA, convey.ShouldEqual, MFALevelTOTP)
+		convey.So(claims.MFATime, convey.ShouldNotBeNil)
+		convey.So(claims.AuthTime.Time, convey.ShouldHappenWithin, time.Millisecond, authTime)
+
+		// 续期保留二次验证级别及时间
+		renewed, _, ok, err := RenewClaims(claims, time.Now())
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(ok, convey.ShouldBeTrue)
+		rc, err := ParseToken(renewed)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(rc.MFA, convey.ShouldEqual, MFALevelTOTP)
+		convey.So(rc.SessionID, convey.ShouldEqual, "s1")
+		convey.So(rc.MFATime.Time, convey.ShouldHappenWithin, time.Millisecond, claims.MFATime.Time)
+	})
+}
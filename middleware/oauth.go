@@ -0,0 +1,165 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"ginfra/config"
+
+	"github.com/dgrijalva/jwt-go/v4"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+//OAuthAccessTokenType OAuth access token的header typ，见RFC 9068，用于区分登录态token及id token
+const OAuthAccessTokenType = "at+jwt"
+
+var (
+	OAuthIssuer         string        // 授权服务地址，OIDC要求为https URL，也是各端点的前缀
+	OAuthLoginURL       string        // 授权时未登录跳转的登录页，登录后跳回授权地址
+	OAuthCodeExpires    time.Duration // 授权码有效期
+	OAuthAccessExpires  time.Duration // access token有效期
+	OAuthIDTokenExpires time.Duration // id token有效期
+)
+
+func init() {
+	cfg, err := config.Parse("")
+	if err != nil {
+		panic(err)
+	}
+
+	cfg.SetDefault("oauth.codeexpires", time.Minute)
+	cfg.SetDefault("oauth.accessexpires", time.Hour)
+	cfg.SetDefault("oauth.idtokenexpires", time.Hour)
+	OAuthIssuer = strings.TrimSuffix(cfg.GetString("oauth.issuer"), "/")
+	OAuthLoginURL = cfg.GetString("oauth.loginurl")
+	OAuthCodeExpires = cfg.GetDuration("oauth.codeexpires")
+	OAuthAccessExpires = cfg.GetDuration("oauth.accessexpires")
+	OAuthIDTokenExpires = cfg.GetDuration("oauth.idtokenexpires")
+}
+
+//OAuthClaims OAuth access token及OIDC id token的claims
+// 用户授权时sub为uid，客户端凭证授权时sub为client_id
+type OAuthClaims struct {
+	jwt.StandardClaims
+	ClientID string    `json:"client_id,omitempty"`
+	Scope    string    `json:"scope,omitempty"`
+	Nonce    string    `json:"nonce,omitempty"`
+	AuthTime *jwt.Time `json:"auth_time,omitempty"`
+}
+
+//ClientCredentials 是否为客户端凭证授权的token
+func (c *OAuthClaims) ClientCredentials() bool {
+	return c.Subject == c.ClientID
+}
+
+//ScopeList 授权范围列表
+func (c *OAuthClaims) ScopeList() []string {
+	return strings.Fields(c.Scope)
+}
+
+func newOAuthClaims(subject, clientID string, expires time.Duration) *OAuthClaims {
+	now := time.Now()
+	return &OAuthClaims{
+		ClientID: clientID,
+		StandardClaims: jwt.StandardClaims{
+			ID:        uuid.New().String(),
+			Subject:   subject,
+			Audience:  jwt.ClaimStrings{clientID},
+			Issuer:    OAuthIssuer,
+			IssuedAt:  jwt.At(now),
+			ExpiresAt: jwt.At(now.Add(expires)),
+		},
+	}
+}
+
+//NewOAuthAccessToken 签发OAuth access token，authTime为空表示客户端凭证授权
+func NewOAuthAccessToken(subject, clientID, scope string, authTime *time.Time) (string, *OAuthClaims, error) {
+	claims := newOAuthClaims(subject, clientID, OAuthAccessExpires)
+	claims.Scope = scope
+	if authTime != nil {
+		claims.AuthTime = jwt.At(*authTime)
+	}
+	token, err := Keys().SignWithType(claims, OAuthAccessTokenType)
+	return token, claims, err
+}
+
+//NewIDToken 签发OIDC id token
+func NewIDToken(subject, clientID, nonce string, authTime time.Time) (string, error) {
+	claims := newOAuthClaims(subject, clientID, OAuthIDTokenExpires)
+	claims.Nonce = nonce
+	claims.AuthTime = jwt.At(authTime)
+	return Keys().Sign(claims)
+}
+
+//ParseOAuthAccessToken 解析并校验OAuth access token，已吊销或在用户登录态水位之前签发的token无效
+func ParseOAuthAccessToken(ctx context.Context, tokenStr string) (*OAuthClaims, error) {
+	ks := Keys()
+	token, err := jwt.ParseWithClaims(tokenStr, &OAuthClaims{}, func(token *jwt.Token) (interface{}, error) {
+		// 登录态token及id token不能作为access token使用
+		if typ, _ := token.Header["typ"].(string); typ != OAuthAccessTokenType {
+			return nil, errors.New("not an access token")
+		}
+		return ks.Keyfunc(token)
+	}, jwt.WithValidMethods(ks.algorithms), jwt.WithIssuer(OAuthIssuer), jwt.WithoutAudienceValidation())
+	if err != nil {
+		return nil, err
+	}
+	claims, ok := token.Claims.(*OAuthClaims)
+	if !ok || !token.Valid || len(claims.ClientID) == 0 {
+		return nil, errors.New("invalid access token")
+	}
+
+	revoked, err := TokenRevocation.IsRevoked(ctx, claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, errors.New("revoked access token")
+	}
+	if !claims.ClientCredentials() {
+		watermark, err := TokenRevocation.Watermark(ctx, claims.Subject)
+		if err != nil {
+			return nil, err
+		}
+		if issuedBeforeWatermark(claims.IssuedAt, watermark) {
+			return nil, errors.New("revoked access token")
+		}
+	}
+	return claims, nil
+}
+
+//VerifyPKCE 校验PKCE code_verifier，仅支持S256，见RFC 7636
+func VerifyPKCE(verifier, challenge, method string) bool {
+	if method != "S256" || len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+//OAuthLoginRedirect 授权请求未携带登录态时跳转到登录页，登录后跳回授权地址；需放在鉴权中间件之前
+func OAuthLoginRedirect() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if len(OAuthLoginURL) == 0 || len(c.GetHeader(HeaderTokenName)) > 0 {
+			return
+		}
+		if _, err := c.Cookie(CookieTokenName); err == nil {
+			return
+		}
+		sep := "?"
+		if strings.Contains(OAuthLoginURL, "?") {
+			sep = "&"
+		}
+		c.Redirect(http.StatusFound, OAuthLoginURL+sep+"redirect="+url.QueryEscape(OAuthIssuer+c.Request.URL.RequestURI()))
+		c.Abort()
+	}
+}
@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func Test_VerifyPKCE(t *testing.T) {
+	verifier := "dBjftJeZ4CVP-mJ92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	sum := sha256.Sum256([]byte(verifier))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	convey.Convey("S256", t, func() {
+		convey.So(VerifyPKCE(verifier, challenge, "S256"), convey.ShouldBeTrue)
+		convey.So(VerifyPKCE(verifier+"x", challenge, "S256"), convey.ShouldBeFalse)
+		convey.So(VerifyPKCE("", challenge, "S256"), convey.ShouldBeFalse)
+	})
+	convey.Convey("plain is not supported", t, func() {
+		convey.So(VerifyPKCE(verifier, verifier, "plain"), convey.ShouldBeFalse)
+	})
+}
+
+func Test_OAuthAccessToken(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jwtkeys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeRSAKey(t, dir, "k1")
+	ks, err := LoadKeySet(dir, []KeyConfig{{Kid: "k1", Status: KeyStatusActive}}, defaultAlgorithms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldKeys := jwtKeys.Load()
+	jwtKeys.Store(ks)
+	defer jwtKeys.Store(oldKeys)
+	oldStore := TokenRevocation
+	TokenRevocation = NewMemoryRevocationStore()
+	defer func() { TokenRevocation = oldStore }()
+
+	ctx := context.Background()
+	authTime := time.Now()
+
+	convey.Convey("access token round trip", t, func() {
+		token, claims, err := NewOAuthAccessToken("10001", "client", "openid profile", &authTime)
+		convey.So(err, convey.ShouldBeNil)
+		parsed, err := ParseOAuthAccessToken(ctx, token)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(parsed.Subject, convey.ShouldEqual, "10001")
+		convey.So(parsed.ClientID, convey.ShouldEqual, "client")
+		convey.So(parsed.ScopeList(), convey.ShouldResemble, []string{"openid", "profile"})
+		convey.So(parsed.ClientCredentials(), convey.ShouldBeFalse)
+
+		convey.So(TokenRevocation.Revoke(ctx, claims.ID, time.Now().Add(time.Hour)), convey.ShouldBeNil)
+		_, err = ParseOAuthAccessToken(ctx, token)
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+
+	convey.Convey("client credentials", t, func() {
+		token, _, err := NewOAuthAccessToken("client", "client", "", nil)
+		convey.So(err, convey.ShouldBeNil)
+		parsed, err := ParseOAuthAccessToken(ctx, token)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(parsed.ClientCredentials(), convey.ShouldBeTrue)
+	})
+
+	convey.Convey("login token and id token are not access tokens", t, func() {
+		login, err := ks.Sign(NewCustomClaims([]byte("{}"), 60))
+		convey.So(err, convey.ShouldBeNil)
+		_, err = ParseOAuthAccessToken(ctx, login)
+		convey.So(err, convey.ShouldNotBeNil)
+
+		idToken, err := NewIDToken("10001", "client", "n", authTime)
+		convey.So(err, convey.ShouldBeNil)
+		_, err = ParseOAuthAccessToken(ctx, idToken)
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,183 @@
+package middleware
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ginfra/config"
+	"ginfra/datasource"
+	"ginfra/log"
+	"ginfra/models"
+	"ginfra/protocol"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+var (
+	RBACCacheTTL    time.Duration // 权限缓存时间
+	RBACCacheSize   int           // 权限缓存的最大用户数，超出时淘汰
+	RBACEmbedClaims bool          // 签发JWT时是否将权限写入claims
+)
+
+func init() {
+	cfg, err := config.Parse("")
+	if err != nil {
+		panic(err)
+	}
+
+	cfg.SetDefault("rbac.cachettl", time.Minute)
+	cfg.SetDefault("rbac.cachesize", 10000)
+	RBACCacheTTL = cfg.GetDuration("rbac.cachettl")
+	RBACCacheSize = cfg.GetInt("rbac.cachesize")
+	RBACEmbedClaims = cfg.GetBool("rbac.embedclaims")
+}
+
+// Require 中间件，要求当前用户拥有全部指定权限，需放在鉴权中间件之后
+// admin.uids中的超级管理员拥有全部权限，JWT请求优先使用claims中的权限，否则按用户及客户查询角色权限(带缓存)；
+// API密钥请求按密钥所属用户的权限校验，且不能超出密钥的授权范围
+func Require(perms ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !authorize(c, protocol.GetCustomerId(c), perms) {
+			c.Abort()
+		}
+	}
+}
+
+//AuthorizeCustomer 校验当前用户能否操作customerID客户的数据，不通过时设置错误响应，需放在Require之后
+// customerID与请求所属客户一致时已由Require校验，否则(包括全局)要求在全局拥有全部指定权限，避免客户管理员越权操作其他客户
+func AuthorizeCustomer(c *gin.Context, customerID string, perms ...string) bool {
+	if len(customerID) > 0 && customerID == protocol.GetCustomerId(c) {
+		return true
+	}
+	return authorize(c, "", perms)
+}
+
+// authorize 校验当前用户在customerID下拥有全部权限，customerID为空时只计全局角色，不通过时设置错误响应
+func authorize(c *gin.Context, customerID string, perms []string) bool {
+	granted, err := permissionsOf(c, customerID)
+	if err != nil {
+		log.WithGinContext(c).Error("Require load permissions fail", zap.String("error", err.Error()))
+		protocol.SetErrResponse(c, protocol.ErrCodeDBException)
+		return false
+	}
+
+	scoped := protocol.GetAuthType(c) == protocol.AuthTypeApiKey
+	for _, p := range perms {
+		if !HasPermission(granted, p) || (scoped && !HasPermission(protocol.GetScopes(c), p)) {
+			Audit(c, AuditEventPermissionDenied, p)
+			protocol.SetErrResponse(c, protocol.ErrCodeUnAuthorized)
+			return false
+		}
+	}
+	return true
+}
+
+//HasPermission 判断权限列表是否包含perm，支持*及 资源:* 通配
+func HasPermission(granted []string, perm string) bool {
+	for _, g := range granted {
+		if g == "*" || g == perm {
+			return true
+		}
+		if strings.HasSuffix(g, ":*") && strings.HasPrefix(perm, g[:len(g)-1]) {
+			return true
+		}
+	}
+	return false
+}
+
+func permissionsOf(c *gin.Context, customerID string) ([]string, error) {
+	switch protocol.GetAuthType(c) {
+	case protocol.AuthTypeJWT, protocol.AuthTypeApiKey:
+	default:
+		return nil, nil
+	}
+
+	if IsSuperAdmin(protocol.GetUserId(c)) {
+		return []string{"*"}, nil
+	}
+	if perms, ok := c.Value(protocol.CtxPermissions).([]string); ok {
+		return perms, nil
+	}
+	uid, err := strconv.ParseUint(protocol.GetUserId(c), 10, 64)
+	if err != nil {
+		return nil, nil
+	}
+	return LoadPermissions(c.Request.Context(), uid, customerID)
+}
+
+type permissionEntry struct {
+	perms     []string
+	expiresAt time.Time
+}
+
+var permissionCache = struct {
+	sync.RWMutex
+	entries map[uint64]map[string]*permissionEntry
+}{entries: make(map[uint64]map[string]*permissionEntry)}
+
+//LoadPermissions 查询用户在customerID下的权限，结果缓存RBACCacheTTL，最多缓存RBACCacheSize个用户
+func LoadPermissions(ctx context.Context, uid uint64, customerID string) ([]string, error) {
+	now := time.Now()
+	permissionCache.RLock()
+	entry := permissionCache.entries[uid][customerID]
+	permissionCache.RUnlock()
+	if entry != nil && entry.expiresAt.After(now) {
+		return entry.perms, nil
+	}
+
+	db, err := datasource.Gormv2(ctx)
+	if err != nil {
+		return nil, err
+	}
+	perms, err := models.GetUserPermissions(db, uid, customerID)
+	if err != nil {
+		return nil, err
+	}
+
+	cachePermissions(uid, customerID, perms, now)
+	return perms, nil
+}
+
+// cachePermissions 写入权限缓存，用户数达到RBACCacheSize时先清理过期项，仍然超出时随机淘汰
+func cachePermissions(uid uint64, customerID string, perms []string, now time.Time) {
+	permissionCache.Lock()
+	defer permissionCache.Unlock()
+	if permissionCache.entries[uid] == nil {
+		if len(permissionCache.entries) >= RBACCacheSize {
+			evictPermissions(now)
+		}
+		permissionCache.entries[uid] = make(map[string]*permissionEntry)
+	}
+	permissionCache.entries[uid][customerID] = &permissionEntry{perms: perms, expiresAt: now.Add(RBACCacheTTL)}
+}
+
+// evictPermissions 清理过期的缓存，仍达到上限时淘汰任意用户，调用方需持有写锁
+func evictPermissions(now time.Time) {
+	for uid, customers := range permissionCache.entries {
+		for customerID, entry := range customers {
+			if !entry.expiresAt.After(now) {
+				delete(customers, customerID)
+			}
+		}
+		if len(customers) == 0 {
+			delete(permissionCache.entries, uid)
+		}
+	}
+	for uid := range permissionCache.entries {
+		if len(permissionCache.entries) < RBACCacheSize {
+			return
+		}
+		delete(permissionCache.entries, uid)
+	}
+}
+
+//InvalidatePermissions 清除用户的权限缓存，仅对当前实例生效，其他实例在缓存过期后生效
+func InvalidatePermissions(uid uint64) {
+	permissionCache.Lock()
+	defer permissionCache.Unlock()
+	delete(permissionCache.entries, uid)
+}
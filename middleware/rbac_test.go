@@ -0,0 +1,164 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ginfra/log"
+	"ginfra/protocol"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smartystreets/goconvey/convey"
+	"go.uber.org/zap"
+)
+
+func Test_HasPermission(t *testing.T) {
+	convey.Convey("HasPermission", t, func() {
+		convey.So(HasPermission([]string{"post:read"}, "post:read"), convey.ShouldBeTrue)
+		convey.So(HasPermission([]string{"post:read"}, "post:write"), convey.ShouldBeFalse)
+		convey.So(HasPermission([]string{"*"}, "role:write"), convey.ShouldBeTrue)
+		convey.So(HasPermission([]string{"post:*"}, "post:write"), convey.ShouldBeTrue)
+		convey.So(HasPermission([]string{"post:*"}, "poster:write"), convey.ShouldBeFalse)
+		convey.So(HasPermission([]string{"post"}, "post:write"), convey.ShouldBeFalse)
+		convey.So(HasPermission(nil, "post:read"), convey.ShouldBeFalse)
+	})
+}
+
+func Test_Require(t *testing.T) {
+	oldLogger, oldAdmins := log.ZLog, adminCfg.Get("admin.uids")
+	defer func() {
+		log.ZLog = oldLogger
+		adminCfg.Set("admin.uids", oldAdmins)
+		InvalidatePermissions(4)
+	}()
+	log.ZLog = zap.NewNop()
+	adminCfg.Set("admin.uids", []string{"1"})
+	cachePermissions(4, "", []string{"apikey:read"}, time.Now())
+
+	gin.SetMode(gin.TestMode)
+	do := func(setup func(c *gin.Context), handlers ...gin.HandlerFunc) string {
+		g := gin.New()
+		g.Use(setup)
+		handlers = append(handlers, func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+		g.POST("/", handlers...)
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", nil))
+		return w.Body.String()
+	}
+	jwtUser := func(uid string, perms []string) func(c *gin.Context) {
+		return func(c *gin.Context) {
+			c.Set(protocol.CtxAuthType, protocol.AuthTypeJWT)
+			c.Set(protocol.CtxUserID, uid)
+			c.Set(protocol.CtxPermissions, perms)
+		}
+	}
+	apiKey := func(uid string, scopes []string) func(c *gin.Context) {
+		return func(c *gin.Context) {
+			c.Set(protocol.CtxAuthType, protocol.AuthTypeApiKey)
+			c.Set(protocol.CtxUserID, uid)
+			c.Set(protocol.CtxScopes, scopes)
+		}
+	}
+	unauthorized := protocol.ErrCodeUnAuthorized.Code
+
+	convey.Convey("Require", t, func() {
+		convey.So(do(jwtUser("2", []string{"role:*"}), Require("role:write")), convey.ShouldEqual, "ok")
+		convey.So(do(jwtUser("2", []string{"role:read"}), Require("role:read", "role:write")), convey.ShouldContainSubstring, unauthorized)
+		convey.So(do(jwtUser("1", nil), Require("role:write")), convey.ShouldEqual, "ok")
+		convey.So(do(apiKey("1", []string{"apikey:read"}), Require("apikey:read")), convey.ShouldEqual, "ok")
+		convey.So(do(apiKey("1", []string{"apikey:read"}), Require("apikey:write")), convey.ShouldContainSubstring, unauthorized)
+		// 授权范围不能超出密钥所属用户的权限
+		convey.So(do(apiKey("4", []string{"*"}), Require("apikey:read")), convey.ShouldEqual, "ok")
+		convey.So(do(apiKey("4", []string{"*"}), Require("apikey:write")), convey.ShouldContainSubstring, unauthorized)
+		convey.So(do(func(c *gin.Context) {}, Require("role:read")), convey.ShouldContainSubstring, unauthorized)
+	})
+
+	convey.Convey("RequireAdmin", t, func() {
+		convey.So(do(jwtUser("2", []string{"role:read"}), RequireAdmin(), Require("role:read")), convey.ShouldEqual, "ok")
+		convey.So(do(jwtUser("2", []string{"role:read"}), RequireAdmin(), Require("role:write")), convey.ShouldContainSubstring, unauthorized)
+		convey.So(do(apiKey("1", []string{"*"}), RequireAdmin(), Require("role:read")), convey.ShouldContainSubstring, unauthorized)
+	})
+}
+
+func Test_AuthorizeCustomer(t *testing.T) {
+	oldLogger, oldAdmins := log.ZLog, adminCfg.Get("admin.uids")
+	defer func() {
+		log.ZLog = oldLogger
+		adminCfg.Set("admin.uids", oldAdmins)
+		InvalidatePermissions(5)
+	}()
+	log.ZLog = zap.NewNop()
+	adminCfg.Set("admin.uids", []string{"1"})
+	// 用户5只在客户acme下拥有role:write
+	cachePermissions(5, "acme", []string{"role:write"}, time.Now())
+	cachePermissions(5, "", []string{}, time.Now())
+
+	gin.SetMode(gin.TestMode)
+	do := func(uid, tenant, customerID string) string {
+		g := gin.New()
+		g.POST("/", func(c *gin.Context) {
+			c.Set(protocol.CtxAuthType, protocol.AuthTypeJWT)
+			c.Set(protocol.CtxUserID, uid)
+			c.Set(protocol.CtxCustomerID, tenant)
+		}, Require("role:write"), func(c *gin.Context) {
+			if AuthorizeCustomer(c, customerID, "role:write") {
+				c.String(http.StatusOK, "ok")
+			}
+		})
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", nil))
+		return w.Body.String()
+	}
+	unauthorized := protocol.ErrCodeUnAuthorized.Code
+
+	convey.Convey("AuthorizeCustomer", t, func() {
+		convey.So(do("5", "acme", "acme"), convey.ShouldEqual, "ok")
+		convey.So(do("5", "acme", ""), convey.ShouldContainSubstring, unauthorized)
+		convey.So(do("5", "acme", "other"), convey.ShouldContainSubstring, unauthorized)
+		convey.So(do("1", "acme", "other"), convey.ShouldEqual, "ok")
+		convey.So(do("1", "", ""), convey.ShouldEqual, "ok")
+	})
+}
+
+func Test_PermissionCache(t *testing.T) {
+	savedSize, savedTTL := RBACCacheSize, RBACCacheTTL
+	defer func() {
+		RBACCacheSize, RBACCacheTTL = savedSize, savedTTL
+		InvalidatePermissions(1)
+		InvalidatePermissions(2)
+		InvalidatePermissions(3)
+	}()
+	RBACCacheSize, RBACCacheTTL = 2, time.Minute
+	size := func() int {
+		permissionCache.RLock()
+		defer permissionCache.RUnlock()
+		return len(permissionCache.entries)
+	}
+	now := time.Now()
+
+	convey.Convey("bounded", t, func() {
+		cachePermissions(1, "", []string{"a"}, now.Add(-2*time.Minute))
+		cachePermissions(2, "", []string{"b"}, now)
+		cachePermissions(2, "c1", []string{"b"}, now)
+		convey.So(size(), convey.ShouldEqual, 2)
+
+		// 先清理过期的用户1
+		cachePermissions(3, "", []string{"c"}, now)
+		convey.So(size(), convey.ShouldEqual, 2)
+		permissionCache.RLock()
+		_, expired := permissionCache.entries[1]
+		permissionCache.RUnlock()
+		convey.So(expired, convey.ShouldBeFalse)
+
+		// 没有过期项时淘汰任意用户
+		cachePermissions(1, "", []string{"a"}, now)
+		convey.So(size(), convey.ShouldEqual, 2)
+
+		InvalidatePermissions(1)
+		InvalidatePermissions(2)
+		InvalidatePermissions(3)
+		convey.So(size(), convey.ShouldEqual, 0)
+	})
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"strings"
+
+	"ginfra/utils"
+)
+
+//NewRefreshToken 生成不透明的refresh token，格式为 族标识.随机串
+// 族标识在登录时生成，轮换时沿用；family为空时生成新的族标识
+func NewRefreshToken(family string) (string, string, error) {
+	var err error
+	if len(family) == 0 {
+		family, err = utils.RandomString(32)
+		if err != nil {
+			return "", "", err
+		}
+	}
+	secret, err := utils.RandomString(40)
+	if err != nil {
+		return "", "", err
+	}
+	return family, family + "." + secret, nil
+}
+
+//RefreshTokenFamily 解析refresh token的族标识
+func RefreshTokenFamily(token string) (string, bool) {
+	i := strings.IndexByte(token, '.')
+	if i <= 0 || i == len(token)-1 {
+		return "", false
+	}
+	return token[:i], true
+}
+
+//HashRefreshToken 计算refresh token的哈希值，DB中只保存哈希
+func HashRefreshToken(token string) string {
+	return utils.SHA256Hex(token)
+}
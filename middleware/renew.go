@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"ginfra/config"
+	"ginfra/log"
+	"ginfra/utils"
+
+	"github.com/dgrijalva/jwt-go/v4"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+var (
+	RenewWindow            time.Duration // 剩余有效期小于该值时续期，0表示不续期
+	RenewMaxAge            time.Duration // 最长会话时间，从登录开始计算，续期不超过该时间
+	HeaderRenewedTokenName string        // 续期后新token的响应头
+	cookieDomain           string
+)
+
+func init() {
+	cfg, err := config.Parse("")
+	if err != nil {
+		panic(err)
+	}
+
+	cfg.SetDefault("jwt.renew.window", 0)
+	cfg.SetDefault("jwt.renew.maxage", 7*24*time.Hour)
+	cfg.SetDefault("jwt.renew.headername", "X-Renewed-Token")
+	RenewWindow = cfg.GetDuration("jwt.renew.window")
+	RenewMaxAge = cfg.GetDuration("jwt.renew.maxage")
+	HeaderRenewedTokenName = cfg.GetString("jwt.renew.headername")
+	cookieDomain = cfg.GetString("jwt.domain")
+}
+
+// renewToken 有效期剩余不足RenewWindow时签发新token，token来自Cookie时写回Cookie，否则通过响应头返回
+// 旧token在过期前仍然有效，避免并发请求失败
+func renewToken(c *gin.Context, claims *utils.CustomClaims, fromCookie bool) {
+	if RenewWindow <= 0 {
+		return
+	}
+	token, expiresAt, ok, err := RenewClaims(claims, time.Now())
+	if err != nil {
+		log.WithGinContext(c).Error("JWTAuth renew token fail", zap.String("error", err.Error()))
+		return
+	}
+	if !ok {
+		return
+	}
+
+	if fromCookie {
+		maxAge := int(time.Until(expiresAt) / time.Second)
+		http.SetCookie(c.Writer, &http.Cookie{
+			Name:     CookieTokenName,
+			Value:    token,
+			MaxAge:   maxAge,
+			Path:     "/",
+			Domain:   cookieDomain,
+			Secure:   c.Request.TLS != nil,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+		return
+	}
+	c.Header(HeaderRenewedTokenName, token)
+}
+
+//RenewClaims 以now为当前时间判断是否需要续期，需要时签发新token
+// 新token沿用原jti、有效期长度、登录时间、二次验证及会话，吊销原jti即吊销续期得到的token，过期时间不超过登录时间+RenewMaxAge，未携带登录时间的旧token不续期
+func RenewClaims(claims *utils.CustomClaims, now time.Time) (string, time.Time, bool, error) {
+	if claims.ExpiresAt == nil || claims.IssuedAt == nil || claims.AuthTime == nil {
+		return "", time.Time{}, false, nil
+	}
+	if claims.ExpiresAt.Sub(now) > RenewWindow {
+		return "", time.Time{}, false, nil
+	}
+
+	expiresAt := now.Add(claims.ExpiresAt.Sub(claims.IssuedAt.Time))
+	if maxExpiresAt := claims.AuthTime.Add(RenewMaxAge); expiresAt.After(maxExpiresAt) {
+		expiresAt = maxExpiresAt
+	}
+	if !expiresAt.After(claims.ExpiresAt.Time) {
+		// 已达到最长会话时间
+		return "", time.Time{}, false, nil
+	}
+
+	renewed := &utils.CustomClaims{
+		Data:      claims.Data,
+		AuthTime:  claims.AuthTime,
+		MFA:       claims.MFA,
+		MFATime:   claims.MFATime,
+		SessionID: claims.SessionID,
+		StandardClaims: jwt.StandardClaims{
+			ID:        claims.ID,
+			IssuedAt:  jwt.At(now),
+			NotBefore: jwt.At(now.Add(-1 * time.Hour)),
+			ExpiresAt: jwt.At(expiresAt),
+			Issuer:    claims.Issuer,
+		},
+	}
+	if len(renewed.ID) == 0 {
+		renewed.ID = uuid.New().String()
+	}
+	token, err := Keys().Sign(renewed)
+	if err != nil {
+		return "", time.Time{}, false, err
+	}
+	return token, expiresAt, true, nil
+}
+
+// tokenIDExpiresAt 同一jti的token最晚的过期时间，开启续期时为登录时间+RenewMaxAge，吊销记录需保留到该时间
+func tokenIDExpiresAt(claims *utils.CustomClaims) time.Time {
+	var expiresAt time.Time
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Time
+	}
+	if RenewWindow > 0 && claims.AuthTime != nil {
+		if maxExpiresAt := claims.AuthTime.Add(RenewMaxAge); maxExpiresAt.After(expiresAt) {
+			expiresAt = maxExpiresAt
+		}
+	}
+	return expiresAt
+}
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"ginfra/utils"
+
+	"github.com/dgrijalva/jwt-go/v4"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func Test_RenewClaims(t *testing.T) {
+	RenewWindow, RenewMaxAge = 5*time.Minute, time.Hour
+	defer func() { RenewWindow, RenewMaxAge = 0, 7*24*time.Hour }()
+	now := time.Now()
+	claims := func(authTime, issuedAt time.Time, lifetime time.Duration) *utils.CustomClaims {
+		return &utils.CustomClaims{
+			AuthTime: jwt.At(authTime),
+			StandardClaims: jwt.StandardClaims{
+				IssuedAt:  jwt.At(issuedAt),
+				ExpiresAt: jwt.At(issuedAt.Add(lifetime)),
+			},
+		}
+	}
+
+	convey.Convey("RenewClaims", t, func() {
+		// 未进入续期窗口
+		_, _, ok, _ := RenewClaims(claims(now, now, 15*time.Minute), now)
+		convey.So(ok, convey.ShouldBeFalse)
+
+		// 达到最长会话时间
+		_, _, ok, _ = RenewClaims(claims(now.Add(-time.Hour), now.Add(-14*time.Minute), 15*time.Minute), now)
+		convey.So(ok, convey.ShouldBeFalse)
+
+		// 未携带登录时间的旧token
+		old := claims(now, now.Add(-14*time.Minute), 15*time.Minute)
+		old.AuthTime = nil
+		_, _, ok, _ = RenewClaims(old, now)
+		convey.So(ok, convey.ShouldBeFalse)
+	})
+
+	dir, err := ioutil.TempDir("", "jwtkeys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeRSAKey(t, dir, "renew")
+	keys, _ := LoadKeySet(dir, []KeyConfig{{Kid: "renew", Status: KeyStatusActive}}, defaultAlgorithms)
+	saved := Keys()
+	jwtKeys.Store(keys)
+	defer jwtKeys.Store(saved)
+
+	convey.Convey("RenewClaims renewed", t, func() {
+		c := claims(now.Add(-50*time.Minute), now.Add(-14*time.Minute), 15*time.Minute)
+		c.ID = "renew-jti"
+		token, expiresAt, ok, err := RenewClaims(c, now)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(ok, convey.ShouldBeTrue)
+		// 不超过登录时间+RenewMaxAge
+		convey.So(expiresAt, convey.ShouldEqual, c.AuthTime.Add(time.Hour))
+
+		renewed, err := keys.Parse(token)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(renewed.AuthTime.Unix(), convey.ShouldEqual, c.AuthTime.Unix())
+
+		// 沿用jti，吊销原token即吊销续期的token，吊销记录保留到最长会话时间
+		convey.So(renewed.ID, convey.ShouldEqual, c.ID)
+		convey.So(tokenIDExpiresAt(c), convey.ShouldEqual, c.AuthTime.Add(time.Hour))
+		store := NewMemoryRevocationStore()
+		convey.So(store.Revoke(context.Background(), c.ID, tokenIDExpiresAt(c)), convey.ShouldBeNil)
+		revoked, _ := store.IsRevoked(context.Background(), renewed.ID)
+		convey.So(revoked, convey.ShouldBeTrue)
+	})
+
+	convey.Convey("ValidateTimeClaims", t, func() {
+		c := claims(now, now.Add(-20*time.Minute), 15*time.Minute)
+		err := utils.ValidateTimeClaims(&c.StandardClaims, now, 0)
+		_, expired := err.(*jwt.TokenExpiredError)
+		convey.So(expired, convey.ShouldBeTrue)
+		convey.So(utils.ValidateTimeClaims(&c.StandardClaims, now.Add(-10*time.Minute), 0), convey.ShouldBeNil)
+	})
+}
@@ -0,0 +1,178 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"ginfra/config"
+	"ginfra/datasource"
+	"ginfra/log"
+	"ginfra/models"
+
+	"github.com/dgrijalva/jwt-go/v4"
+	"go.uber.org/zap"
+)
+
+//RevocationStore JWT吊销存储，按jti吊销单个token，按用户水位吊销该时间之前签发的全部token
+type RevocationStore interface {
+	Revoke(ctx context.Context, jti string, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+	RevokeBefore(ctx context.Context, uid string, t time.Time) error
+	Watermark(ctx context.Context, uid string) (time.Time, error)
+}
+
+//TokenRevocation 当前使用的吊销存储，jwt.revocation.store配置为memory或db
+var TokenRevocation RevocationStore
+
+//RevocationCleanup 清理已过期吊销记录的间隔，由Start启动，0表示不清理
+var RevocationCleanup time.Duration
+
+// revocationCleaner 支持清理已过期吊销记录的存储
+type revocationCleaner interface {
+	cleanup(now time.Time)
+}
+
+func init() {
+	cfg, err := config.Parse("")
+	if err != nil {
+		panic(err)
+	}
+
+	cfg.SetDefault("jwt.revocation.store", "memory")
+	cfg.SetDefault("jwt.revocation.cleanup", 10*time.Minute)
+	RevocationCleanup = cfg.GetDuration("jwt.revocation.cleanup")
+	switch store := cfg.GetString("jwt.revocation.store"); store {
+	case "memory":
+		TokenRevocation = NewMemoryRevocationStore()
+	case "db":
+		TokenRevocation = NewDBRevocationStore()
+	default:
+		panic(fmt.Errorf("unknown jwt revocation store: %s", store))
+	}
+}
+
+//RevokeToken 吊销单个token，expiresAt为token过期时间，过期后吊销记录可清理
+func RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	return TokenRevocation.Revoke(ctx, jti, expiresAt)
+}
+
+//RevokeUserTokens 吊销用户当前时间之前签发的全部token
+// 水位与JWT的iat同为微秒精度(jwt.TimePrecision)，吊销前签发的token均失效，吊销后签发的token(如修改密码后重新登录)有效
+func RevokeUserTokens(ctx context.Context, uid string) error {
+	return TokenRevocation.RevokeBefore(ctx, uid, time.Now().Truncate(jwt.TimePrecision))
+}
+
+// issuedBeforeWatermark token是否在水位及之前签发，未携带iat的旧token视为在水位之前签发
+func issuedBeforeWatermark(issuedAt *jwt.Time, watermark time.Time) bool {
+	if watermark.IsZero() {
+		return false
+	}
+	return issuedAt == nil || !issuedAt.After(watermark)
+}
+
+// memoryRevocationStore 单实例部署使用，重启后吊销记录丢失
+type memoryRevocationStore struct {
+	mu         sync.RWMutex
+	revoked    map[string]time.Time
+	watermarks map[string]time.Time
+}
+
+//NewMemoryRevocationStore 内存吊销存储，Start按RevocationCleanup间隔清理已过期的jti
+func NewMemoryRevocationStore() RevocationStore {
+	return &memoryRevocationStore{
+		revoked:    make(map[string]time.Time),
+		watermarks: make(map[string]time.Time),
+	}
+}
+
+func (s *memoryRevocationStore) cleanup(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for jti, expiresAt := range s.revoked {
+		if expiresAt.Before(now) {
+			delete(s.revoked, jti)
+		}
+	}
+}
+
+func (s *memoryRevocationStore) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revoked[jti] = expiresAt
+	return nil
+}
+
+func (s *memoryRevocationStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.revoked[jti]
+	return ok, nil
+}
+
+func (s *memoryRevocationStore) RevokeBefore(ctx context.Context, uid string, t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t.After(s.watermarks[uid]) {
+		s.watermarks[uid] = t
+	}
+	return nil
+}
+
+func (s *memoryRevocationStore) Watermark(ctx context.Context, uid string) (time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.watermarks[uid], nil
+}
+
+// dbRevocationStore 多实例部署使用，吊销记录保存在DB
+type dbRevocationStore struct{}
+
+//NewDBRevocationStore DB吊销存储，Start按RevocationCleanup间隔删除已过期的吊销记录
+func NewDBRevocationStore() RevocationStore {
+	return &dbRevocationStore{}
+}
+
+func (s *dbRevocationStore) cleanup(now time.Time) {
+	ctx := context.Background()
+	db, err := datasource.Gormv2(ctx)
+	if err != nil {
+		return
+	}
+	if err := models.DeleteExpiredRevokedTokens(db, now); err != nil {
+		log.WithContext(ctx).Error("delete expired revoked tokens fail", zap.String("error", err.Error()))
+	}
+}
+
+func (s *dbRevocationStore) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	db, err := datasource.Gormv2(ctx)
+	if err != nil {
+		return err
+	}
+	return (&models.RevokedToken{Jti: jti, ExpiresAt: expiresAt}).Insert(db)
+}
+
+func (s *dbRevocationStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	db, err := datasource.Gormv2(ctx)
+	if err != nil {
+		return false, err
+	}
+	return models.IsTokenRevoked(db, jti)
+}
+
+func (s *dbRevocationStore) RevokeBefore(ctx context.Context, uid string, t time.Time) error {
+	db, err := datasource.Gormv2(ctx)
+	if err != nil {
+		return err
+	}
+	return models.SetTokenWatermark(db, uid, t)
+}
+
+func (s *dbRevocationStore) Watermark(ctx context.Context, uid string) (time.Time, error) {
+	db, err := datasource.Gormv2(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return models.GetTokenWatermark(db, uid)
+}
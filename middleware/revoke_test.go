@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"ginfra/log"
+	"ginfra/protocol"
+
+	"github.com/dgrijalva/jwt-go/v4"
+	"github.com/gin-gonic/gin"
+	"github.com/smartystreets/goconvey/convey"
+	"go.uber.org/zap"
+)
+
+func Test_MemoryRevocationStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryRevocationStore().(*memoryRevocationStore)
+	now := time.Now()
+
+	convey.Convey("Revoke", t, func() {
+		convey.So(s.Revoke(ctx, "a", now.Add(time.Minute)), convey.ShouldBeNil)
+		convey.So(s.Revoke(ctx, "b", now.Add(-time.Minute)), convey.ShouldBeNil)
+		revoked, _ := s.IsRevoked(ctx, "a")
+		convey.So(revoked, convey.ShouldBeTrue)
+		revoked, _ = s.IsRevoked(ctx, "c")
+		convey.So(revoked, convey.ShouldBeFalse)
+
+		s.cleanup(now)
+		revoked, _ = s.IsRevoked(ctx, "a")
+		convey.So(revoked, convey.ShouldBeTrue)
+		revoked, _ = s.IsRevoked(ctx, "b")
+		convey.So(revoked, convey.ShouldBeFalse)
+	})
+
+	convey.Convey("RevokeBefore", t, func() {
+		w, _ := s.Watermark(ctx, "1")
+		convey.So(w.IsZero(), convey.ShouldBeTrue)
+
+		convey.So(s.RevokeBefore(ctx, "1", now), convey.ShouldBeNil)
+		convey.So(s.RevokeBefore(ctx, "1", now.Add(-time.Hour)), convey.ShouldBeNil)
+		w, _ = s.Watermark(ctx, "1")
+		convey.So(w, convey.ShouldEqual, now)
+	})
+}
+
+func Test_WatermarkPrecision(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jwtkeys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeRSAKey(t, dir, "watermark")
+	keys, _ := LoadKeySet(dir, []KeyConfig{{Kid: "watermark", Status: KeyStatusActive}}, defaultAlgorithms)
+	saved := Keys()
+	jwtKeys.Store(keys)
+	defer jwtKeys.Store(saved)
+
+	oldLogger, oldStore := log.ZLog, TokenRevocation
+	defer func() { log.ZLog, TokenRevocation = oldLogger, oldStore }()
+	log.ZLog = zap.NewNop()
+	TokenRevocation = NewMemoryRevocationStore()
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/", nil)
+	c.Set(protocol.CtxUserID, "1")
+	issue := func() *jwt.Time {
+		token, err := GenerateToken(map[string]uint64{"Uid": 1}, 60)
+		if err != nil {
+			t.Fatal(err)
+		}
+		claims, err := ParseToken(token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return claims.IssuedAt
+	}
+
+	convey.Convey("token issued within the same second as revocation", t, func() {
+		// 吊销前刚签发的token经过签名及解析后仍被吊销
+		before := issue()
+		convey.So(RevokeUserTokens(context.Background(), "1"), convey.ShouldBeNil)
+		w, _ := TokenRevocation.Watermark(context.Background(), "1")
+		convey.So(w.Sub(before.Time), convey.ShouldBeLessThan, time.Second)
+		convey.So(checkWatermark(c, before), convey.ShouldNotBeNil)
+		convey.So(checkWatermark(c, nil), convey.ShouldNotBeNil)
+
+		// 修改密码、注销全部登录态后重新登录签发的token有效
+		time.Sleep(time.Millisecond)
+		convey.So(checkWatermark(c, issue()), convey.ShouldBeNil)
+	})
+}
@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"ginfra/config"
+	"ginfra/datasource"
+	"ginfra/log"
+	"ginfra/models"
+
+	"go.uber.org/zap"
+)
+
+//SessionFlushInterval 会话最近访问时间的批量写入间隔，由Start启动，0表示不记录
+var SessionFlushInterval time.Duration
+
+var sessionsSeen = &sessionTracker{seen: make(map[string]models.SessionSeen)}
+
+func init() {
+	cfg, err := config.Parse("")
+	if err != nil {
+		panic(err)
+	}
+
+	cfg.SetDefault("session.flushinterval", time.Minute)
+	SessionFlushInterval = cfg.GetDuration("session.flushinterval")
+}
+
+//GenerateSessionToken 生成属于会话sid的登录态token
+func GenerateSessionToken(claims interface{}, expires int64, sid string) (string, string, error) {
+	b, err := json.Marshal(claims)
+	if err != nil {
+		return "", "", err
+	}
+	custom := NewCustomClaims(b, expires)
+	custom.SessionID = sid
+	token, err := Keys().Sign(custom)
+	if err != nil {
+		return "", "", err
+	}
+	return token, custom.ID, nil
+}
+
+//RevokeSession 吊销会话签发的全部token，expiresAt为会话过期时间
+func RevokeSession(ctx context.Context, sid string, expiresAt time.Time) error {
+	return TokenRevocation.Revoke(ctx, sessionRevocationKey(sid), expiresAt)
+}
+
+// sessionRevocationKey 会话在吊销存储中的标识，与jti共用存储
+func sessionRevocationKey(sid string) string {
+	return "sid:" + sid
+}
+
+//TouchSession 记录会话的一次访问，在内存中合并后按SessionFlushInterval批量写入
+func TouchSession(sid, ip string, now time.Time) {
+	if SessionFlushInterval <= 0 || len(sid) == 0 {
+		return
+	}
+	sessionsSeen.touch(sid, models.SessionSeen{At: now, IP: ip})
+}
+
+//FlushSessions 写入内存中的会话访问记录，写入失败时丢弃，之后的访问会再次记录
+func FlushSessions(ctx context.Context) {
+	seen := sessionsSeen.take()
+	if len(seen) == 0 {
+		return
+	}
+	db, err := datasource.Gormv2(ctx)
+	if err != nil {
+		return
+	}
+	if err := models.UpdateSessionsLastSeen(db, seen); err != nil {
+		log.WithContext(ctx).Error("flush sessions last seen fail", zap.Int("count", len(seen)),
+			zap.String("error", err.Error()))
+	}
+}
+
+// sessionTracker 内存中的会话访问记录，同一会话只保留最近一次
+type sessionTracker struct {
+	sync.Mutex
+	seen map[string]models.SessionSeen
+}
+
+func (t *sessionTracker) touch(sid string, s models.SessionSeen) {
+	t.Lock()
+	defer t.Unlock()
+	if old, ok := t.seen[sid]; ok && !s.At.After(old.At) {
+		return
+	}
+	t.seen[sid] = s
+}
+
+func (t *sessionTracker) take() map[string]models.SessionSeen {
+	t.Lock()
+	defer t.Unlock()
+	seen := t.seen
+	t.seen = make(map[string]models.SessionSeen)
+	return seen
+}
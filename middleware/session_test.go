@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"ginfra/log"
+	"ginfra/models"
+	"ginfra/protocol"
+	"ginfra/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smartystreets/goconvey/convey"
+	"go.uber.org/zap"
+)
+
+func Test_SessionTracker(t *testing.T) {
+	tracker := &sessionTracker{seen: make(map[string]models.SessionSeen)}
+	now := time.Now()
+	convey.Convey("keep latest", t, func() {
+		tracker.touch("s1", models.SessionSeen{At: now, IP: "10.0.0.1"})
+		tracker.touch("s1", models.SessionSeen{At: now.Add(-time.Second), IP: "10.0.0.2"})
+		tracker.touch("s2", models.SessionSeen{At: now, IP: "10.0.0.3"})
+		tracker.touch("s2", models.SessionSeen{At: now.Add(time.Second), IP: "10.0.0.4"})
+
+		seen := tracker.take()
+		convey.So(seen, convey.ShouldHaveLength, 2)
+		convey.So(seen["s1"].IP, convey.ShouldEqual, "10.0.0.1")
+		convey.So(seen["s2"].IP, convey.ShouldEqual, "10.0.0.4")
+		convey.So(tracker.take(), convey.ShouldBeEmpty)
+	})
+}
+
+func Test_JWTAuthSession(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jwtkeys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeRSAKey(t, dir, "session")
+	keys, _ := LoadKeySet(dir, []KeyConfig{{Kid: "session", Status: KeyStatusActive}}, defaultAlgorithms)
+	saved := Keys()
+	jwtKeys.Store(keys)
+	defer jwtKeys.Store(saved)
+
+	oldLogger, oldStore, oldInterval := log.ZLog, TokenRevocation, SessionFlushInterval
+	defer func() { log.ZLog, TokenRevocation, SessionFlushInterval = oldLogger, oldStore, oldInterval }()
+	log.ZLog = zap.NewNop()
+	TokenRevocation = NewMemoryRevocationStore()
+	SessionFlushInterval = time.Minute
+	sessionsSeen.take()
+
+	gin.SetMode(gin.TestMode)
+	g := gin.New()
+	g.Use(JWTAuth(func(c *gin.Context, claims *utils.CustomClaims) error {
+		c.Set(protocol.CtxUserID, "1")
+		return nil
+	}))
+	g.GET("/sid", func(c *gin.Context) { c.String(http.StatusOK, c.GetString(protocol.CtxSessionID)) })
+	do := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/sid", nil)
+		req.Header.Set(HeaderTokenName, token)
+		req.RemoteAddr = "10.0.0.1:1234"
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, req)
+		return w
+	}
+
+	convey.Convey("session token", t, func() {
+		token, jti, err := GenerateSessionToken(map[string]uint64{"Uid": 1}, 60, "s1")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(jti, convey.ShouldNotBeEmpty)
+
+		w := do(token)
+		convey.So(w.Body.String(), convey.ShouldEqual, "s1")
+		seen := sessionsSeen.take()
+		convey.So(seen["s1"].IP, convey.ShouldEqual, "10.0.0.1")
+
+		// 会话结束后同一会话的token全部失效
+		other, _, _ := GenerateSessionToken(map[string]uint64{"Uid": 1}, 60, "s1")
+		convey.So(RevokeSession(context.Background(), "s1", time.Now().Add(time.Hour)), convey.ShouldBeNil)
+		convey.So(do(token).Body.String(), convey.ShouldContainSubstring, "revoked auth token")
+		convey.So(do(other).Body.String(), convey.ShouldContainSubstring, "revoked auth token")
+
+		token, _, _ = GenerateSessionToken(map[string]uint64{"Uid": 1}, 60, "s2")
+		convey.So(do(token).Body.String(), convey.ShouldEqual, "s2")
+	})
+}
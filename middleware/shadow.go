@@ -0,0 +1,240 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"ginfra/config"
+	"ginfra/log"
+	"ginfra/protocol"
+	"ginfra/tracing"
+	"ginfra/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+var shadowRequestCount = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "ginfra_shadow_request_count",
+		Help: "shadow request count",
+	},
+	[]string{"path", "result"},
+)
+
+var shadowMismatchCount = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "ginfra_shadow_mismatch_count",
+		Help: "shadow response mismatch count",
+	},
+	[]string{"path", "kind"},
+)
+
+//ShadowConfig 影子流量配置
+type ShadowConfig struct {
+	Enable             bool
+	Upstream           string        // 影子服务地址，如 http://127.0.0.1:8081
+	Percentage         float64       // 采样比例 0~100
+	Timeout            time.Duration // 影子请求超时时间
+	MaxBodySize        int64         // 请求体超过该大小时不镜像
+	Concurrency        int32         // 同时进行的影子请求上限，超过则丢弃
+	MirrorMethods      []string      // 镜像的Method，默认只镜像GET、HEAD
+	MirrorRoutes       []string      // 额外镜像的路由(注册的完整路由)，仅用于幂等的POST读接口，如/api/v2/DescribeSessions
+	ExcludeRoutes      []string      // 不镜像的路由前缀，如登录、支付等敏感接口，默认排除会写库的GET接口/auth、/oauth
+	StripHeaders       []string      // 不透传给影子服务的请求头
+	ForwardCredentials bool          // 是否透传登录态、API密钥、Cookie等凭证请求头，默认不透传
+}
+
+var (
+	shadowCfg      atomic.Value
+	shadowInflight int32
+	shadowClient   = &http.Client{Transport: tracing.NewTransport(nil)}
+)
+
+// hop-by-hop 请求头不透传
+var hopHeaders = []string{
+	"Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization",
+	"Te", "Trailer", "Transfer-Encoding", "Upgrade",
+}
+
+// credentialHeaders 凭证请求头，默认不透传给影子服务，避免待发布版本获得用户凭证
+func credentialHeaders() []string {
+	return []string{
+		"Authorization", "Cookie", HeaderTokenName, HeaderSecretIdName, HeaderSecretKeyName,
+		HeaderSmsCode, HeaderCaptchaTicket, HeaderCaptchaRandstr,
+	}
+}
+
+func init() {
+	cfg, err := config.Parse("")
+	if err != nil {
+		panic(err)
+	}
+
+	if err := loadShadowConfig(cfg); err != nil {
+		panic(err)
+	}
+	cfg.OnChange(func() {
+		if err := loadShadowConfig(cfg); err != nil {
+			log.WithContext(context.Background()).Error("reload shadow config fail",
+				zap.String("error", err.Error()))
+		}
+	})
+}
+
+func loadShadowConfig(cfg *config.Config) error {
+	// 切片在解码后再设置默认值，mapstructure会将配置追加到已有的切片中
+	sc := &ShadowConfig{
+		Timeout:     3 * time.Second,
+		MaxBodySize: 1 << 20,
+		Concurrency: 64,
+	}
+	if err := cfg.UnmarshalKey("shadow", sc); err != nil {
+		return err
+	}
+	if len(sc.MirrorMethods) == 0 {
+		sc.MirrorMethods = []string{"GET", "HEAD"}
+	}
+	if len(sc.ExcludeRoutes) == 0 {
+		sc.ExcludeRoutes = []string{"/auth", "/oauth"}
+	}
+	sc.Upstream = strings.TrimSuffix(sc.Upstream, "/")
+	shadowCfg.Store(sc)
+	return nil
+}
+
+// Shadow 中间件，按比例将请求异步镜像到影子服务，不影响主请求的响应
+// 只镜像MirrorMethods及MirrorRoutes，避免在影子服务重放写操作；默认剥离凭证请求头
+// 对比HTTP状态码及Response.Error.Code，不一致时记录指标和日志；需放在RequestId之后
+func Shadow() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sc := shadowCfg.Load().(*ShadowConfig)
+		if !sc.Enable || len(sc.Upstream) == 0 || rand.Float64()*100 >= sc.Percentage {
+			return
+		}
+		if !utils.StringInSlice(c.Request.Method, sc.MirrorMethods) && !utils.StringInSlice(c.FullPath(), sc.MirrorRoutes) {
+			return
+		}
+		path := c.Request.URL.Path
+		for _, prefix := range sc.ExcludeRoutes {
+			if len(prefix) > 0 && strings.HasPrefix(path, prefix) {
+				return
+			}
+		}
+		if c.Request.ContentLength > sc.MaxBodySize {
+			return
+		}
+
+		// 读取请求体后还原，供后续handler使用
+		var body []byte
+		if c.Request.Body != nil {
+			var err error
+			body, err = ioutil.ReadAll(io.LimitReader(c.Request.Body, sc.MaxBodySize+1))
+			c.Request.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(body), c.Request.Body))
+			if err != nil || int64(len(body)) > sc.MaxBodySize {
+				return
+			}
+		}
+
+		req, err := http.NewRequest(c.Request.Method, sc.Upstream+c.Request.URL.RequestURI(), bytes.NewReader(body))
+		if err != nil {
+			return
+		}
+		req.Header = c.Request.Header.Clone()
+		strip := append(hopHeaders, sc.StripHeaders...)
+		if !sc.ForwardCredentials {
+			strip = append(strip, credentialHeaders()...)
+		}
+		for _, h := range strip {
+			req.Header.Del(h)
+		}
+		requestId := protocol.GetRequestId(c)
+		req.Header.Set(protocol.CtxRequestID, requestId)
+		req.Header.Set("X-Shadow-Request", "1")
+		// 按JSON解析影子响应的错误码
+		req.Header.Set("Accept", protocol.MIMEJSON)
+
+		c.Next()
+
+		if atomic.AddInt32(&shadowInflight, 1) > sc.Concurrency {
+			atomic.AddInt32(&shadowInflight, -1)
+			shadowRequestCount.With(prometheus.Labels{"path": path, "result": "dropped"}).Inc()
+			return
+		}
+		primary := shadowResult{status: c.Writer.Status(), code: protocol.GetResponseCode(c)}
+		logger := log.WithGinContext(c)
+		go func() {
+			defer atomic.AddInt32(&shadowInflight, -1)
+			mirror(req, sc.Timeout, path, requestId, primary, logger)
+		}()
+	}
+}
+
+type shadowResult struct {
+	status int
+	code   string
+}
+
+func mirror(req *http.Request, timeout time.Duration, path, requestId string, primary shadowResult, logger *zap.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	resp, err := shadowClient.Do(req.WithContext(ctx))
+	if err != nil {
+		shadowRequestCount.With(prometheus.Labels{"path": path, "result": "error"}).Inc()
+		logger.Warn("shadow request fail", zap.String("RequestId", requestId), zap.String("error", err.Error()))
+		return
+	}
+	defer resp.Body.Close()
+	respBody, _ := ioutil.ReadAll(resp.Body)
+
+	shadow := shadowResult{status: resp.StatusCode, code: envelopeCode(respBody)}
+	kind := ""
+	if shadow.status != primary.status {
+		kind = "status"
+	} else if shadow.code != primary.code {
+		kind = "code"
+	}
+	if len(kind) == 0 {
+		shadowRequestCount.With(prometheus.Labels{"path": path, "result": "match"}).Inc()
+		return
+	}
+
+	shadowRequestCount.With(prometheus.Labels{"path": path, "result": "mismatch"}).Inc()
+	shadowMismatchCount.With(prometheus.Labels{"path": path, "kind": kind}).Inc()
+	logger.Warn("shadow response mismatch",
+		zap.String("RequestId", requestId),
+		zap.String("path", path),
+		zap.String("kind", kind),
+		zap.String("primary", strconv.Itoa(primary.status)+"/"+primary.code),
+		zap.String("shadow", strconv.Itoa(shadow.status)+"/"+shadow.code),
+	)
+}
+
+// envelopeCode 解析protocol响应中的Response.Error.Code，正常响应返回OK，非protocol响应返回空
+func envelopeCode(body []byte) string {
+	var envelope struct {
+		Response *struct {
+			Error *struct {
+				Code string
+			}
+		}
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil || envelope.Response == nil {
+		return ""
+	}
+	if envelope.Response.Error == nil {
+		return "OK"
+	}
+	return envelope.Response.Error.Code
+}
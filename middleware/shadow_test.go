@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ginfra/log"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smartystreets/goconvey/convey"
+	"go.uber.org/zap"
+)
+
+func Test_Shadow(t *testing.T) {
+	saved := shadowCfg.Load()
+	oldLogger := log.ZLog
+	defer func() {
+		shadowCfg.Store(saved)
+		log.ZLog = oldLogger
+	}()
+	log.ZLog = zap.NewNop()
+
+	mirrored := make(chan *http.Request, 8)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mirrored <- r
+	}))
+	defer upstream.Close()
+
+	gin.SetMode(gin.TestMode)
+	g := gin.New()
+	g.Use(Shadow())
+	ok := func(c *gin.Context) { c.String(http.StatusOK, "ok") }
+	g.GET("/api/v2/Ping", ok)
+	g.POST("/api/v2/DescribeSessions", ok)
+	g.POST("/api/v2/RevokeAllTokens", ok)
+	g.GET("/auth/:provider/callback", ok)
+	do := func(method, path string) {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		req.Header.Set("Cookie", "token=secret")
+		req.Header.Set(HeaderTokenName, "secret")
+		req.Header.Set(HeaderSecretKeyName, "secret")
+		req.Header.Set("X-Trace", "1")
+		g.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	receive := func() *http.Request {
+		select {
+		case r := <-mirrored:
+			return r
+		case <-time.After(200 * time.Millisecond):
+			return nil
+		}
+	}
+
+	convey.Convey("only reads are mirrored without credentials", t, func() {
+		convey.So(loadShadowConfig(testConfig(t, `
+shadow:
+  enable: true
+  upstream: `+upstream.URL+`
+  percentage: 100
+  mirrorroutes: [/api/v2/DescribeSessions]
+`)), convey.ShouldBeNil)
+
+		do(http.MethodGet, "/api/v2/Ping")
+		r := receive()
+		convey.So(r, convey.ShouldNotBeNil)
+		convey.So(r.URL.Path, convey.ShouldEqual, "/api/v2/Ping")
+		convey.So(r.Header.Get("X-Trace"), convey.ShouldEqual, "1")
+		for _, h := range []string{"Authorization", "Cookie", HeaderTokenName, HeaderSecretKeyName} {
+			convey.So(r.Header.Get(h), convey.ShouldBeEmpty)
+		}
+
+		do(http.MethodPost, "/api/v2/DescribeSessions")
+		r = receive()
+		convey.So(r, convey.ShouldNotBeNil)
+		convey.So(r.URL.Path, convey.ShouldEqual, "/api/v2/DescribeSessions")
+
+		do(http.MethodPost, "/api/v2/RevokeAllTokens")
+		do(http.MethodGet, "/auth/github/callback?code=x")
+		convey.So(receive(), convey.ShouldBeNil)
+	})
+
+	convey.Convey("forward credentials when enabled", t, func() {
+		convey.So(loadShadowConfig(testConfig(t, `
+shadow:
+  enable: true
+  upstream: `+upstream.URL+`
+  percentage: 100
+  forwardcredentials: true
+`)), convey.ShouldBeNil)
+
+		do(http.MethodGet, "/api/v2/Ping")
+		r := receive()
+		convey.So(r, convey.ShouldNotBeNil)
+		convey.So(r.Header.Get("Authorization"), convey.ShouldEqual, "Bearer secret")
+	})
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"ginfra/errcode"
+	"ginfra/log"
+	"ginfra/protocol"
+	"ginfra/sms"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+//HeaderSmsCode 敏感操作二次验证的短信验证码请求头
+const HeaderSmsCode = "X-Sms-Code"
+
+//LinkedPhoneFunc 查询当前用户绑定的手机号，返回的错误直接作为响应
+type LinkedPhoneFunc func(c *gin.Context) (string, error)
+
+// RequireSmsCode 中间件，敏感操作要求二次验证，校验X-Sms-Code请求头中发送到当前用户绑定手机号的验证码
+// 仅支持JWT鉴权，需放在JWTAuth之后；手机号由phoneHandler查询，如handler.LinkedPhone
+func RequireSmsCode(phoneHandler LinkedPhoneFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if protocol.GetAuthType(c) != protocol.AuthTypeJWT {
+			log.WithGinContext(c).Error("RequireSmsCode denied, not jwt")
+			protocol.SetErrResponse(c, protocol.ErrCodeUnAuthorized)
+			c.Abort()
+			return
+		}
+		phone, err := phoneHandler(c)
+		if err != nil {
+			protocol.SetErrResponse(c, err)
+			c.Abort()
+			return
+		}
+
+		code := c.GetHeader(HeaderSmsCode)
+		if len(code) == 0 {
+			protocol.SetErrResponse(c, protocol.ErrCodeInvalidVerifyCode)
+			c.Abort()
+			return
+		}
+		ok, err := sms.Verify(c.Request.Context(), phone, sms.PurposeVerify, code)
+		if err != nil {
+			log.WithGinContext(c).Error(err.Error(), zap.String("error", errcode.ErrCodeInternalError))
+			protocol.SetErrResponse(c, errcode.NewCustomError(errcode.ErrCodeInternalError, "校验验证码失败"))
+			c.Abort()
+			return
+		}
+		if !ok {
+			RecordCaptchaFailure(c)
+			protocol.SetErrResponse(c, protocol.ErrCodeInvalidVerifyCode)
+			c.Abort()
+			return
+		}
+	}
+}
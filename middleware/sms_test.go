@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ginfra/log"
+	"ginfra/protocol"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smartystreets/goconvey/convey"
+	"go.uber.org/zap"
+)
+
+func Test_RequireSmsCode(t *testing.T) {
+	oldLogger := log.ZLog
+	defer func() { log.ZLog = oldLogger }()
+	log.ZLog = zap.NewNop()
+
+	gin.SetMode(gin.TestMode)
+	do := func(authType string, phoneHandler LinkedPhoneFunc) string {
+		g := gin.New()
+		g.POST("/", func(c *gin.Context) {
+			c.Set(protocol.CtxAuthType, authType)
+		}, RequireSmsCode(phoneHandler), func(c *gin.Context) {
+			c.String(http.StatusOK, "ok")
+		})
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", nil))
+		return w.Body.String()
+	}
+	linked := func(c *gin.Context) (string, error) { return "13800000000", nil }
+	notLinked := func(c *gin.Context) (string, error) { return "", protocol.ErrCodePhoneNotLinked }
+
+	convey.Convey("RequireSmsCode", t, func() {
+		convey.So(do(protocol.AuthTypeApiKey, linked), convey.ShouldContainSubstring, protocol.ErrCodeUnAuthorized.Code)
+		convey.So(do(protocol.AuthTypeJWT, notLinked), convey.ShouldContainSubstring, protocol.ErrCodePhoneNotLinked.Code)
+		convey.So(do(protocol.AuthTypeJWT, linked), convey.ShouldContainSubstring, protocol.ErrCodeInvalidVerifyCode.Code)
+	})
+}
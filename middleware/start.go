@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"context"
+	"time"
+)
+
+//Start 启动中间件的后台任务，ctx取消后全部退出；需在初始化DB及日志后由main调用
+// 包括按SessionFlushInterval批量写入会话访问时间、清理已使用的验证码票据及失败计数、按RevocationCleanup清理过期的吊销记录
+func Start(ctx context.Context) {
+	if SessionFlushInterval > 0 {
+		go every(ctx, SessionFlushInterval, func(now time.Time) {
+			FlushSessions(context.Background())
+		})
+	}
+	go every(ctx, time.Minute, func(now time.Time) {
+		captchaTickets.cleanup(now)
+		captchaFailures.cleanup(now)
+	})
+	if cleaner, ok := TokenRevocation.(revocationCleaner); ok && RevocationCleanup > 0 {
+		go every(ctx, RevocationCleanup, cleaner.cleanup)
+	}
+}
+
+// every 每隔interval执行一次fn，ctx取消后退出
+func every(ctx context.Context, interval time.Duration, fn func(now time.Time)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			fn(now)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Start(t *testing.T) {
+	oldStore, oldCleanup, oldFlush := TokenRevocation, RevocationCleanup, SessionFlushInterval
+	defer func() { TokenRevocation, RevocationCleanup, SessionFlushInterval = oldStore, oldCleanup, oldFlush }()
+	store := NewMemoryRevocationStore().(*memoryRevocationStore)
+	TokenRevocation, RevocationCleanup, SessionFlushInterval = store, 10*time.Millisecond, 0
+
+	revoked := func(jti string) bool {
+		ok, _ := store.IsRevoked(context.Background(), jti)
+		return ok
+	}
+
+	convey.Convey("background cleanup runs until ctx is canceled", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		store.Revoke(ctx, "expired", time.Now().Add(-time.Minute))
+		convey.So(revoked("expired"), convey.ShouldBeTrue)
+
+		Start(ctx)
+		time.Sleep(50 * time.Millisecond)
+		convey.So(revoked("expired"), convey.ShouldBeFalse)
+
+		cancel()
+		time.Sleep(20 * time.Millisecond)
+		store.Revoke(context.Background(), "after", time.Now().Add(-time.Minute))
+		time.Sleep(50 * time.Millisecond)
+		convey.So(revoked("after"), convey.ShouldBeTrue)
+	})
+}
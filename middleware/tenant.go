@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"net"
+	"strings"
+
+	"ginfra/config"
+	"ginfra/datasource"
+	"ginfra/protocol"
+	"ginfra/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	TenantDomains  []string // 按子域名识别租户的根域名，如 example.com 时 acme.example.com 的租户为 acme
+	TenantReserved []string // 不作为租户的子域名，如 www、api
+)
+
+func init() {
+	cfg, err := config.Parse("")
+	if err != nil {
+		panic(err)
+	}
+
+	TenantDomains = cfg.GetStringSlice("tenant.domains")
+	cfg.SetDefault("tenant.reserved", []string{"www", "api"})
+	TenantReserved = cfg.GetStringSlice("tenant.reserved")
+}
+
+// Tenant 中间件，识别当前请求的租户(客户)，写入gin context及request context，需放在鉴权中间件之后
+// 优先使用登录态或API密钥中的客户，其次使用子域名；两者不一致时拒绝请求
+// JWT登录态只能访问claims中所属客户的子域名，所属关系来自用户在该客户下的角色(models.UserRole)
+// 未识别到租户时不拦截，访问租户隔离的表时返回datasource.ErrMissingTenant
+func Tenant() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenant := protocol.GetCustomerId(c)
+		sub := SubdomainTenant(c.Request.Host)
+		if len(sub) > 0 && protocol.GetAuthType(c) == protocol.AuthTypeJWT &&
+			!utils.StringInSlice(sub, protocol.GetTenants(c)) {
+			Audit(c, AuditEventTenantMismatch, "user not bound to subdomain tenant "+sub)
+			protocol.SetErrResponse(c, protocol.ErrCodeUnAuthorized)
+			c.Abort()
+			return
+		}
+		if len(tenant) == 0 {
+			tenant = sub
+		} else if len(sub) > 0 && sub != tenant {
+			Audit(c, AuditEventTenantMismatch, "subdomain tenant "+sub)
+			protocol.SetErrResponse(c, protocol.ErrCodeUnAuthorized)
+			c.Abort()
+			return
+		}
+		if len(tenant) == 0 {
+			return
+		}
+
+		c.Set(protocol.CtxCustomerID, tenant)
+		c.Request = c.Request.WithContext(datasource.WithTenant(c.Request.Context(), tenant))
+	}
+}
+
+//SubdomainTenant 根据Host识别子域名租户，仅识别tenant.domains下的一级子域名
+func SubdomainTenant(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	for _, domain := range TenantDomains {
+		suffix := "." + strings.ToLower(domain)
+		if !strings.HasSuffix(host, suffix) {
+			continue
+		}
+		sub := host[:len(host)-len(suffix)]
+		if len(sub) == 0 || strings.Contains(sub, ".") || utils.StringInSlice(sub, TenantReserved) {
+			return ""
+		}
+		return sub
+	}
+	return ""
+}
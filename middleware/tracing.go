@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"ginfra/log"
+	"ginfra/protocol"
+	"ginfra/tracing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing 中间件，每个请求创建一个server span，支持W3C traceparent透传
+// 需放在ContextLogger之后，TraceID/SpanID会写入日志字段
+func Tracing(serviceName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(),
+			propagation.HeaderCarrier(c.Request.Header))
+
+		route := c.FullPath()
+		spanName := route
+		if len(spanName) == 0 {
+			spanName = "HTTP " + c.Request.Method
+		}
+		ctx, span := tracing.Tracer().Start(ctx, spanName,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPServerAttributesFromHTTPRequest(serviceName, route, c.Request)...),
+			trace.WithAttributes(attribute.String("http.request_id", protocol.GetRequestId(c))))
+		defer span.End()
+
+		if traceID, spanID := tracing.IDs(ctx); len(traceID) > 0 {
+			log.Logger(c).Set("TraceID", traceID).Set("SpanID", spanID)
+		}
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(semconv.HTTPStatusCodeKey.Int(status),
+			attribute.String("ginfra.response_code", protocol.GetResponseCode(c)),
+			attribute.String("ginfra.user_id", protocol.GetUserId(c)))
+		span.SetStatus(semconv.SpanStatusFromHTTPStatusCode(status))
+		if len(c.Errors) > 0 {
+			span.SetAttributes(attribute.String("gin.errors", c.Errors.String()))
+		}
+	}
+}
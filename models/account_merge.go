@@ -0,0 +1,101 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+//MergeConflict 合并账号时两个账号绑定了同类型的身份，只能保留一个
+type MergeConflict struct {
+	IdentityType int
+	Kept         string // 保留的身份标识
+	Dropped      string // 删除的身份标识
+}
+
+//MergeResult 合并账号结果
+type MergeResult struct {
+	Moved     []string // 迁移到目标账号的身份标识
+	Conflicts []MergeConflict
+	Roles     int // 迁移的用户角色数
+}
+
+//MergeAccounts 将sourceUid的身份及角色合并到targetUid，事务内完成
+// 同类型身份冲突时preferSource为true保留源账号的身份，否则保留目标账号的身份；被删除的身份不可恢复
+func MergeAccounts(db *gorm.DB, sourceUid, targetUid uint64, preferSource bool) (*MergeResult, error) {
+	result := &MergeResult{}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		sources, err := ListUserAuthsByUid(tx, sourceUid)
+		if err != nil {
+			return err
+		}
+		if len(sources) == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		targets, err := ListUserAuthsByUid(tx, targetUid)
+		if err != nil {
+			return err
+		}
+		if len(targets) == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		byType := make(map[int]*UserAuth, len(targets))
+		for _, t := range targets {
+			byType[t.IdentityType] = t
+		}
+
+		for _, src := range sources {
+			if dst, ok := byType[src.IdentityType]; ok {
+				kept, dropped := dst, src
+				if preferSource {
+					kept, dropped = src, dst
+				}
+				if err := tx.Unscoped().Delete(dropped).Error; err != nil {
+					return err
+				}
+				result.Conflicts = append(result.Conflicts, MergeConflict{
+					IdentityType: src.IdentityType,
+					Kept:         kept.Identifier,
+					Dropped:      dropped.Identifier,
+				})
+				if kept == dst {
+					continue
+				}
+			}
+			// 源账号的登录态随合并失效，由下方RevokeUserSessionsByUid注销其会话
+			if err := tx.Model(src).Update("uid", targetUid).Error; err != nil {
+				return err
+			}
+			result.Moved = append(result.Moved, src.Identifier)
+		}
+
+		// 目标账号已有的角色不重复迁移
+		var roles []*UserRole
+		if err := tx.Where("uid = ?", sourceUid).Find(&roles).Error; err != nil {
+			return err
+		}
+		for _, r := range roles {
+			var count int64
+			err := tx.Model(&UserRole{}).Where("uid = ? AND role_id = ? AND customer_id = ?",
+				targetUid, r.RoleID, r.CustomerID).Count(&count).Error
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				err = tx.Unscoped().Delete(r).Error
+			} else {
+				err = tx.Model(r).Update("uid", targetUid).Error
+				result.Roles++
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if err := RevokeUserSessionsByUid(tx, sourceUid); err != nil {
+			return err
+		}
+		return tx.Where("uid = ?", sourceUid).Delete(&PasswordReset{}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
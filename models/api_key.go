@@ -0,0 +1,79 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//ApiKey 合作方API密钥表，SecretKey只保存哈希值
+type ApiKey struct {
+	gorm.Model
+	SecretId      string     `gorm:"uniqueIndex;size:64"`
+	SecretKeyHash string     `gorm:"size:64" json:"-"`
+	Uid           uint64     `gorm:"index"`         // 密钥所属用户，即创建者，按其身份鉴权
+	CustomerID    string     `gorm:"index;size:64"` // 密钥所属客户
+	Name          string     `gorm:"size:128"`      // 密钥备注
+	Scopes        string     `gorm:"size:512"`      // 授权范围，逗号分隔，*表示全部
+	Disabled      bool       // 是否禁用
+	ExpiresAt     *time.Time // 过期时间，为空表示永不过期
+	LastUsedAt    *time.Time // 最近使用时间
+}
+
+//ScopeList 授权范围列表
+func (k *ApiKey) ScopeList() []string {
+	scopes := make([]string, 0)
+	for _, s := range strings.Split(k.Scopes, ",") {
+		s = strings.TrimSpace(s)
+		if len(s) > 0 {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
+}
+
+//Expired 密钥是否已过期
+func (k *ApiKey) Expired() bool {
+	return k.ExpiresAt != nil && k.ExpiresAt.Before(time.Now())
+}
+
+//Insert 新增密钥
+func (k *ApiKey) Insert(db *gorm.DB) error {
+	return db.Create(k).Error
+}
+
+//Disable 禁用密钥
+func (k *ApiKey) Disable(db *gorm.DB) error {
+	k.Disabled = true
+	return db.Model(k).Update("disabled", true).Error
+}
+
+//UpdateSecretKeyHash 轮换密钥
+func (k *ApiKey) UpdateSecretKeyHash(db *gorm.DB, hash string) error {
+	k.SecretKeyHash = hash
+	return db.Model(k).Update("secret_key_hash", hash).Error
+}
+
+//UpdateLastUsed 更新最近使用时间
+func (k *ApiKey) UpdateLastUsed(db *gorm.DB, t time.Time) error {
+	k.LastUsedAt = &t
+	return db.Model(k).UpdateColumn("last_used_at", t).Error
+}
+
+//GetApiKeyBySecretId 根据SecretId查询密钥
+func GetApiKeyBySecretId(db *gorm.DB, secretId string) (*ApiKey, error) {
+	var key ApiKey
+	err := db.First(&key, "secret_id = ?", secretId).Error
+	return &key, err
+}
+
+//ListApiKeys 查询密钥列表，customerID为空时查询全部
+func ListApiKeys(db *gorm.DB, customerID string) ([]*ApiKey, error) {
+	var keys []*ApiKey
+	if len(customerID) > 0 {
+		db = db.Where("customer_id = ?", customerID)
+	}
+	err := db.Order("id desc").Find(&keys).Error
+	return keys, err
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+//AuditLog 审计日志表，记录鉴权拒绝、管理员操作等安全相关事件
+type AuditLog struct {
+	gorm.Model
+	Event      string `gorm:"index;size:64"`
+	Uid        string `gorm:"index;size:64"` // 操作人
+	CustomerID string `gorm:"size:64"`
+	AuthType   string `gorm:"size:16"`
+	Method     string `gorm:"size:16"`
+	Path       string `gorm:"size:256"`
+	ClientIP   string `gorm:"size:64"`
+	RequestId  string `gorm:"size:64"`
+	Detail     string `gorm:"size:1024"`
+}
+
+//Insert 插入审计日志
+func (a *AuditLog) Insert(db *gorm.DB) error {
+	return db.Create(a).Error
+}
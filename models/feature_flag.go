@@ -0,0 +1,22 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+//FeatureFlag 功能开关表，与配置文件中同名的开关以DB为准
+type FeatureFlag struct {
+	gorm.Model
+	Name       string `gorm:"uniqueIndex;size:64"`
+	Enabled    bool   // 总开关
+	Users      string `gorm:"size:1024"` // 白名单用户，逗号分隔
+	Customers  string `gorm:"size:1024"` // 白名单客户，逗号分隔
+	Percentage int    // 灰度比例 0~100
+}
+
+//ListFeatureFlags 查询全部功能开关
+func ListFeatureFlags(db *gorm.DB) ([]*FeatureFlag, error) {
+	var flags []*FeatureFlag
+	err := db.Find(&flags).Error
+	return flags, err
+}
@@ -0,0 +1,135 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 邮件发送状态
+const (
+	MailStatusPending = "pending" // 待发送或等待重试
+	MailStatusSending = "sending" // 发送中
+	MailStatusSent    = "sent"    // 已发送
+	MailStatusFailed  = "failed"  // 重试次数用完
+)
+
+//MailTemplate 邮件模板，同名时覆盖内置模板；Subject、Text使用text/template，HTML使用html/template
+type MailTemplate struct {
+	gorm.Model
+	Name    string `gorm:"uniqueIndex;size:64"`
+	Subject string `gorm:"size:256"`
+	Text    string `gorm:"type:text"`
+	HTML    string `gorm:"type:text"`
+}
+
+//GetMailTemplate 按名称查询邮件模板
+func GetMailTemplate(db *gorm.DB, name string) (*MailTemplate, error) {
+	var tpl MailTemplate
+	err := db.First(&tpl, "name = ?", name).Error
+	return &tpl, err
+}
+
+//MailJob 邮件发送队列，保存已生成的MIME邮件，发送失败后按退避时间重试
+type MailJob struct {
+	gorm.Model
+	From          string `gorm:"size:256"`
+	Recipients    string `gorm:"type:text"` // 收件人、抄送及密送地址，逗号分隔
+	Subject       string `gorm:"size:256"`
+	Raw           []byte // MIME邮件内容
+	Status        string `gorm:"index:idx_mail_job_due;size:16"`
+	Attempts      int
+	NextAttemptAt time.Time `gorm:"index:idx_mail_job_due"`
+	LastError     string    `gorm:"size:512"`
+	SentAt        *time.Time
+}
+
+//Insert 加入发送队列
+func (j *MailJob) Insert(db *gorm.DB) error {
+	return db.Create(j).Error
+}
+
+//Claim 领取待发送或发送超时的邮件，多实例同时领取时只有一个成功，返回是否领取成功
+func (j *MailJob) Claim(db *gorm.DB, staleBefore time.Time) (bool, error) {
+	result := db.Model(j).Where("attempts = ?", j.Attempts).
+		Where("status = ? OR (status = ? AND updated_at < ?)", MailStatusPending, MailStatusSending, staleBefore).
+		Update("status", MailStatusSending)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+//MarkSent 标记为已发送
+func (j *MailJob) MarkSent(db *gorm.DB) error {
+	now := time.Now()
+	j.SentAt = &now
+	return db.Model(j).Updates(map[string]interface{}{
+		"status":   MailStatusSent,
+		"attempts": gorm.Expr("attempts + 1"),
+		"sent_at":  now,
+	}).Error
+}
+
+//MarkFailed 记录发送失败，next为零值时不再重试
+func (j *MailJob) MarkFailed(db *gorm.DB, sendErr error, next time.Time) error {
+	msg := sendErr.Error()
+	if len(msg) > 512 {
+		msg = msg[:512]
+	}
+	updates := map[string]interface{}{
+		"status":     MailStatusFailed,
+		"attempts":   gorm.Expr("attempts + 1"),
+		"last_error": msg,
+	}
+	if !next.IsZero() {
+		updates["status"] = MailStatusPending
+		updates["next_attempt_at"] = next
+	}
+	return db.Model(j).Updates(updates).Error
+}
+
+//ListDueMailJobs 查询到期待发送的邮件，以及发送中超过staleBefore未更新(实例异常退出)的邮件
+func ListDueMailJobs(db *gorm.DB, now, staleBefore time.Time, limit int) ([]*MailJob, error) {
+	var jobs []*MailJob
+	err := db.Where("(status = ? AND next_attempt_at <= ?) OR (status = ? AND updated_at < ?)",
+		MailStatusPending, now, MailStatusSending, staleBefore).
+		Order("next_attempt_at").Limit(limit).Find(&jobs).Error
+	return jobs, err
+}
+
+//EmailVerification 邮箱验证凭证，仅保存凭证哈希，使用一次后失效
+type EmailVerification struct {
+	gorm.Model
+	Uid       uint64    `gorm:"index"`
+	Email     string    `gorm:"size:128"`
+	TokenHash string    `gorm:"uniqueIndex;size:64"`
+	ExpiresAt time.Time // 凭证过期时间
+	UsedAt    *time.Time
+}
+
+//Insert 新建邮箱验证凭证
+func (v *EmailVerification) Insert(db *gorm.DB) error {
+	return db.Create(v).Error
+}
+
+//Consume 使用凭证，仅当凭证未使用时更新，返回是否更新成功
+func (v *EmailVerification) Consume(db *gorm.DB) (bool, error) {
+	now := time.Now()
+	result := db.Model(v).Where("used_at IS NULL").Update("used_at", now)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	v.UsedAt = &now
+	return true, nil
+}
+
+//GetEmailVerificationByHash 根据凭证哈希查询邮箱验证凭证
+func GetEmailVerificationByHash(db *gorm.DB, hash string) (*EmailVerification, error) {
+	var v EmailVerification
+	err := db.First(&v, "token_hash = ?", hash).Error
+	return &v, err
+}
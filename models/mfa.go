@@ -0,0 +1,123 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//UserTOTP 用户的TOTP二次验证，密钥加密保存；绑定后确认前EnabledAt为空
+type UserTOTP struct {
+	gorm.Model
+	Uid         uint64 `gorm:"uniqueIndex"`
+	Secret      string `gorm:"size:128"` // AES-GCM加密后base64编码的密钥
+	EnabledAt   *time.Time
+	LastCounter int64 // 最后一次使用的时间步，同一验证码不能重复使用
+}
+
+//Enabled 是否已确认启用
+func (t *UserTOTP) Enabled() bool {
+	return t.EnabledAt != nil
+}
+
+//SaveSecret 新建TOTP或更新未启用的TOTP密钥，已启用时不更新，返回是否保存成功
+func (t *UserTOTP) SaveSecret(db *gorm.DB, secret string) (bool, error) {
+	if t.ID == 0 {
+		t.Secret = secret
+		return true, db.Create(t).Error
+	}
+	result := db.Model(t).Where("enabled_at IS NULL").Update("secret", secret)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	t.Secret = secret
+	return true, nil
+}
+
+//Enable 确认启用并记录使用的时间步，仅当未启用时更新，返回是否更新成功
+func (t *UserTOTP) Enable(db *gorm.DB, counter int64) (bool, error) {
+	now := time.Now()
+	result := db.Model(t).Where("enabled_at IS NULL").
+		Updates(map[string]interface{}{"enabled_at": now, "last_counter": counter})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	t.EnabledAt = &now
+	t.LastCounter = counter
+	return true, nil
+}
+
+//UseCounter 记录使用的时间步，仅当大于上次使用的时间步时更新，返回是否更新成功
+func (t *UserTOTP) UseCounter(db *gorm.DB, counter int64) (bool, error) {
+	result := db.Model(t).Where("last_counter < ?", counter).UpdateColumn("last_counter", counter)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	t.LastCounter = counter
+	return true, nil
+}
+
+//GetUserTOTP 查询用户的TOTP
+func GetUserTOTP(db *gorm.DB, uid uint64) (*UserTOTP, error) {
+	var t UserTOTP
+	err := db.First(&t, "uid = ?", uid).Error
+	return &t, err
+}
+
+//DeleteUserTOTP 删除用户的TOTP及恢复码，删除后可重新绑定
+func DeleteUserTOTP(db *gorm.DB, uid uint64) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("uid = ?", uid).Delete(&UserTOTP{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Where("uid = ?", uid).Delete(&RecoveryCode{}).Error
+	})
+}
+
+//RecoveryCode 二次验证恢复码，无法使用身份验证器时代替TOTP，仅保存哈希，每个只能使用一次
+type RecoveryCode struct {
+	gorm.Model
+	Uid      uint64 `gorm:"index:idx_recovery_code"`
+	CodeHash string `gorm:"index:idx_recovery_code;size:64"`
+	UsedAt   *time.Time
+}
+
+//ReplaceRecoveryCodes 生成新的恢复码，之前的恢复码全部失效
+func ReplaceRecoveryCodes(db *gorm.DB, uid uint64, hashes []string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("uid = ?", uid).Delete(&RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		codes := make([]RecoveryCode, 0, len(hashes))
+		for _, hash := range hashes {
+			codes = append(codes, RecoveryCode{Uid: uid, CodeHash: hash})
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+//ConsumeRecoveryCode 使用恢复码，仅当恢复码存在且未使用时更新，返回是否更新成功
+func ConsumeRecoveryCode(db *gorm.DB, uid uint64, hash string) (bool, error) {
+	result := db.Model(&RecoveryCode{}).Where("uid = ? AND code_hash = ? AND used_at IS NULL", uid, hash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+//CountRecoveryCodes 统计用户未使用的恢复码数量
+func CountRecoveryCodes(db *gorm.DB, uid uint64) (int64, error) {
+	var count int64
+	err := db.Model(&RecoveryCode{}).Where("uid = ? AND used_at IS NULL", uid).Count(&count).Error
+	return count, err
+}
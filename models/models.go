@@ -2,25 +2,68 @@ package models
 
 import (
 	"strconv"
+	"time"
 
 	"gorm.io/gorm"
 )
 
+// 用户身份类型
+const (
+	IdentityTypeUsername   = 1 // 用户名
+	IdentityTypeEmail      = 2 // 邮箱
+	IdentityTypePhone      = 3 // 手机号
+	IdentityTypeQQ         = 4 // QQ
+	IdentityTypeWeixin     = 5 // 微信
+	IdentityTypeApiKey     = 6 // API密钥，不落UserAuth表，仅用于登录态
+	IdentityTypeWeixinMini = 7 // 微信小程序
+	IdentityTypeSeewo      = 8 // 希沃
+)
+
 //UserAuth 用户授权表
 type UserAuth struct {
 	gorm.Model
 	Uid          uint64 `gorm:"uniqueIndex:idx_uid"`                 // uid
-	IdentityType int    `gorm:"uniqueIndex:idx_uid,idx_identifier"`  // 1用户名 2邮箱 3手机号 4qq 5微信
+	IdentityType int    `gorm:"uniqueIndex:idx_uid,idx_identifier"`  // 1用户名 2邮箱 3手机号 4qq 5微信 7微信小程序 8希沃
 	Identifier   string `gorm:"uniqueIndex:idx_identifier;size:128"` // 手机号 邮箱 用户名或第三方应用的唯一标识
 	Certificate  string `gorm:"size:128"`                            // 密码凭证(站内的保存密码，站外的不保存或保存token)
 	//CertExpireAt time.Time
-	RefreshToken string `gorm:"size:128"`
-	Openid       string `gorm:"index;size:128"` // wx openid
+	FailedAttempts int        // 连续密码错误次数，登录成功后清零
+	LockedUntil    *time.Time // 密码错误次数过多时锁定到该时间
+	VerifiedAt     *time.Time // 邮箱通过验证链接验证的时间，未验证的邮箱不能密码登录
+	Openid         string     `gorm:"index;size:128"` // wx openid
+}
+
+//AutoMigrate 创建或更新全部表结构
+func AutoMigrate(db *gorm.DB) error {
+	return db.AutoMigrate(
+		&Post{},
+		&Tag{},
+		&PostTag{},
+		&ApiKey{},
+		&FeatureFlag{},
+		&UserAuth{},
+		&UserSession{},
+		&PasswordReset{},
+		&SmsCode{},
+		&UserTOTP{},
+		&RecoveryCode{},
+		&EmailVerification{},
+		&MailTemplate{},
+		&MailJob{},
+		&OAuthClient{},
+		&OAuthCode{},
+		&RevokedToken{},
+		&TokenWatermark{},
+		&Role{},
+		&UserRole{},
+		&AuditLog{},
+	)
 }
 
 // table posts
 type Post struct {
 	gorm.Model
+	TenantID    string `gorm:"index;size:64"` // 租户ID，由datasource.TenantPlugin自动填充
 	Title       string // title
 	Body        string // body
 	View        int    // view count
@@ -31,8 +74,9 @@ type Post struct {
 // table tags
 type Tag struct {
 	gorm.Model
-	Name  string // tag name
-	Total int    `gorm:"-"` // count of post
+	TenantID string `gorm:"index;size:64"` // 租户ID，由datasource.TenantPlugin自动填充
+	Name     string // tag name
+	Total    int    `gorm:"-"` // count of post
 }
 
 // table post_tags
@@ -0,0 +1,114 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//OAuthClient OAuth2客户端，ClientSecret只保存哈希值；Public客户端(如SPA、App)没有密钥，必须使用PKCE
+type OAuthClient struct {
+	gorm.Model
+	ClientID     string `gorm:"uniqueIndex;size:64"`
+	SecretHash   string `gorm:"size:64" json:"-"`
+	Name         string `gorm:"size:128"`
+	RedirectURIs string `gorm:"size:1024"` // 回调地址，逗号分隔，精确匹配
+	Scopes       string `gorm:"size:512"`  // 允许申请的授权范围，逗号分隔
+	GrantTypes   string `gorm:"size:128"`  // 允许的授权方式，逗号分隔，authorization_code、client_credentials
+	Public       bool
+	Disabled     bool
+}
+
+//OAuthCode OAuth2授权码，仅保存哈希值，使用一次后失效
+type OAuthCode struct {
+	gorm.Model
+	CodeHash            string `gorm:"uniqueIndex;size:64"`
+	ClientID            string `gorm:"size:64"`
+	Uid                 uint64
+	RedirectURI         string    `gorm:"size:512"`
+	Scope               string    `gorm:"size:512"`
+	Nonce               string    `gorm:"size:256"`
+	CodeChallenge       string    `gorm:"size:128"`
+	CodeChallengeMethod string    `gorm:"size:16"`
+	AuthTime            time.Time // 用户登录时间
+	ExpiresAt           time.Time
+	UsedAt              *time.Time
+}
+
+func splitList(s string) []string {
+	list := make([]string, 0)
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if len(v) > 0 {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+//RedirectURIList 回调地址列表
+func (c *OAuthClient) RedirectURIList() []string {
+	return splitList(c.RedirectURIs)
+}
+
+//ScopeList 授权范围列表
+func (c *OAuthClient) ScopeList() []string {
+	return splitList(c.Scopes)
+}
+
+//GrantTypeList 授权方式列表
+func (c *OAuthClient) GrantTypeList() []string {
+	return splitList(c.GrantTypes)
+}
+
+//Insert 新增客户端
+func (c *OAuthClient) Insert(db *gorm.DB) error {
+	return db.Create(c).Error
+}
+
+//Disable 禁用客户端
+func (c *OAuthClient) Disable(db *gorm.DB) error {
+	c.Disabled = true
+	return db.Model(c).Update("disabled", true).Error
+}
+
+//GetOAuthClient 根据ClientID查询客户端
+func GetOAuthClient(db *gorm.DB, clientID string) (*OAuthClient, error) {
+	var client OAuthClient
+	err := db.First(&client, "client_id = ?", clientID).Error
+	return &client, err
+}
+
+//ListOAuthClients 查询全部客户端
+func ListOAuthClients(db *gorm.DB) ([]*OAuthClient, error) {
+	var clients []*OAuthClient
+	err := db.Order("id").Find(&clients).Error
+	return clients, err
+}
+
+//Insert 新建授权码
+func (c *OAuthCode) Insert(db *gorm.DB) error {
+	return db.Create(c).Error
+}
+
+//Consume 使用授权码，仅当未使用时更新，返回是否更新成功
+func (c *OAuthCode) Consume(db *gorm.DB) (bool, error) {
+	now := time.Now()
+	result := db.Model(c).Where("used_at IS NULL").Update("used_at", now)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	c.UsedAt = &now
+	return true, nil
+}
+
+//GetOAuthCodeByHash 根据授权码哈希查询授权码
+func GetOAuthCodeByHash(db *gorm.DB, hash string) (*OAuthCode, error) {
+	var code OAuthCode
+	err := db.First(&code, "code_hash = ?", hash).Error
+	return &code, err
+}
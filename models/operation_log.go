@@ -10,8 +10,9 @@ import (
 //OperationLog 操作日志的表
 type OperationLog struct {
 	gorm.Model
-	Event   string `gorm:"index;size:64"`
-	ErrCode string `gorm:"size:64"`
+	TenantID string `gorm:"index;size:64"` // 租户ID，由datasource.TenantPlugin自动填充
+	Event    string `gorm:"index;size:64"`
+	ErrCode  string `gorm:"size:64"`
 
 	// private field, ignored from gorm
 	TableID uint `gorm:"-"`
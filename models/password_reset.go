@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//PasswordReset 找回密码凭证，仅保存凭证哈希，使用一次后失效
+type PasswordReset struct {
+	gorm.Model
+	Uid       uint64    `gorm:"index"`
+	TokenHash string    `gorm:"uniqueIndex;size:64"`
+	ExpiresAt time.Time // 凭证过期时间
+	UsedAt    *time.Time
+}
+
+//Insert 新建找回密码凭证
+func (r *PasswordReset) Insert(db *gorm.DB) error {
+	return db.Create(r).Error
+}
+
+//Consume 使用凭证，仅当凭证未使用时更新，返回是否更新成功
+func (r *PasswordReset) Consume(db *gorm.DB) (bool, error) {
+	now := time.Now()
+	result := db.Model(r).Where("used_at IS NULL").Update("used_at", now)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	r.UsedAt = &now
+	return true, nil
+}
+
+//GetPasswordResetByHash 根据凭证哈希查询找回密码凭证
+func GetPasswordResetByHash(db *gorm.DB, hash string) (*PasswordReset, error) {
+	var reset PasswordReset
+	err := db.First(&reset, "token_hash = ?", hash).Error
+	return &reset, err
+}
+
+//DeletePasswordResetsByUid 删除用户未使用的找回密码凭证，修改密码后之前的凭证失效
+func DeletePasswordResetsByUid(db *gorm.DB, uid uint64) error {
+	return db.Where("uid = ? AND used_at IS NULL", uid).Delete(&PasswordReset{}).Error
+}
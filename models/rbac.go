@@ -0,0 +1,133 @@
+package models
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+//Role 角色表，权限以逗号分隔，如 post:read,post:write；*表示全部权限，post:*表示post下全部权限
+type Role struct {
+	gorm.Model
+	Name        string `gorm:"uniqueIndex;size:64"`
+	Description string `gorm:"size:256"`
+	Permissions string `gorm:"size:1024"`
+}
+
+//UserRole 用户角色表，CustomerID为空表示全局角色，否则仅在该客户(租户)下生效
+type UserRole struct {
+	gorm.Model
+	Uid        uint64 `gorm:"uniqueIndex:idx_user_role"`
+	RoleID     uint   `gorm:"uniqueIndex:idx_user_role;index"`
+	CustomerID string `gorm:"uniqueIndex:idx_user_role;size:64"` // 非空时角色限定在该客户下，用户即属于该客户(租户)
+	Role       *Role  `gorm:"-"`
+}
+
+//PermissionList 权限列表
+func (r *Role) PermissionList() []string {
+	perms := make([]string, 0)
+	for _, p := range strings.Split(r.Permissions, ",") {
+		p = strings.TrimSpace(p)
+		if len(p) > 0 {
+			perms = append(perms, p)
+		}
+	}
+	return perms
+}
+
+//Insert 新增角色
+func (r *Role) Insert(db *gorm.DB) error {
+	return db.Create(r).Error
+}
+
+//UpdatePermissions 修改角色权限
+func (r *Role) UpdatePermissions(db *gorm.DB, permissions string) error {
+	r.Permissions = permissions
+	return db.Model(r).Update("permissions", permissions).Error
+}
+
+//GetRoleByName 根据名称查询角色
+func GetRoleByName(db *gorm.DB, name string) (*Role, error) {
+	var role Role
+	err := db.First(&role, "name = ?", name).Error
+	return &role, err
+}
+
+//ListRoles 查询全部角色
+func ListRoles(db *gorm.DB) ([]*Role, error) {
+	var roles []*Role
+	err := db.Order("id").Find(&roles).Error
+	return roles, err
+}
+
+//Insert 分配角色
+func (ur *UserRole) Insert(db *gorm.DB) error {
+	return db.Create(ur).Error
+}
+
+//DeleteUserRole 取消分配角色
+func DeleteUserRole(db *gorm.DB, uid uint64, roleID uint, customerID string) (int64, error) {
+	result := db.Unscoped().Where("uid = ? AND role_id = ? AND customer_id = ?", uid, roleID, customerID).
+		Delete(&UserRole{})
+	return result.RowsAffected, result.Error
+}
+
+//ListRoleUids 查询分配了该角色的用户
+func ListRoleUids(db *gorm.DB, roleID uint) ([]uint64, error) {
+	var uids []uint64
+	err := db.Model(&UserRole{}).Where("role_id = ?", roleID).Distinct().Pluck("uid", &uids).Error
+	return uids, err
+}
+
+//ListUserTenants 查询用户所属的客户(租户)，即用户有角色的客户，不含全局角色
+func ListUserTenants(db *gorm.DB, uid uint64) ([]string, error) {
+	var tenants []string
+	err := db.Model(&UserRole{}).Where("uid = ? AND customer_id <> ''", uid).
+		Distinct().Order("customer_id").Pluck("customer_id", &tenants).Error
+	return tenants, err
+}
+
+//ListUserRoles 查询用户的全部角色
+func ListUserRoles(db *gorm.DB, uid uint64) ([]*UserRole, error) {
+	var userRoles []*UserRole
+	err := db.Where("uid = ?", uid).Order("id").Find(&userRoles).Error
+	if err != nil || len(userRoles) == 0 {
+		return userRoles, err
+	}
+
+	roleIDs := make([]uint, 0, len(userRoles))
+	for _, ur := range userRoles {
+		roleIDs = append(roleIDs, ur.RoleID)
+	}
+	var roles []*Role
+	err = db.Where("id IN ?", roleIDs).Find(&roles).Error
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]*Role, len(roles))
+	for _, r := range roles {
+		byID[r.ID] = r
+	}
+	for _, ur := range userRoles {
+		ur.Role = byID[ur.RoleID]
+	}
+	return userRoles, nil
+}
+
+//GetUserPermissions 查询用户在customerID下的全部权限，包含全局角色的权限
+func GetUserPermissions(db *gorm.DB, uid uint64, customerID string) ([]string, error) {
+	var roles []*Role
+	err := db.Model(&Role{}).
+		Joins("JOIN t_user_role ON t_user_role.role_id = t_role.id AND t_user_role.deleted_at IS NULL").
+		Where("t_user_role.uid = ? AND t_user_role.customer_id IN ?", uid, []string{"", customerID}).
+		Find(&roles).Error
+	if err != nil {
+		return nil, err
+	}
+
+	perms := make([]string, 0)
+	for _, r := range roles {
+		perms = append(perms, r.PermissionList()...)
+	}
+	return perms, nil
+}
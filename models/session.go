@@ -0,0 +1,119 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//UserSession 登录会话，登录签发token对时创建，与refresh token族一一对应
+// 同一会话刷新签发的access token携带相同的sid，会话结束后refresh token族失效
+type UserSession struct {
+	gorm.Model
+	SessionID     string    `gorm:"uniqueIndex;size:64"` // 会话标识，即JWT的sid
+	Uid           uint64    `gorm:"index"`
+	UserAuthID    uint      // 登录使用的身份
+	IdentityType  int       // 登录使用的身份类型
+	RefreshFamily string    `gorm:"index;size:64"` // 会话的refresh token族标识，登录时生成，轮换时不变
+	RefreshToken  string    `gorm:"size:128"`      // 当前refresh token的哈希值
+	TokenID       string    `gorm:"size:64"`       // 最近签发的access token的jti
+	Device        string    `gorm:"size:64"`       // 根据User-Agent识别的设备
+	UserAgent     string    `gorm:"size:256"`
+	ClientIP      string    `gorm:"size:64"` // 登录时的IP
+	LastSeenIP    string    `gorm:"size:64"` // 最近访问的IP
+	LastSeenAt    time.Time // 最近访问时间，批量延迟写入
+	ExpiresAt     time.Time // 会话过期时间，即refresh token族过期时间，轮换不延长
+	RevokedAt     *time.Time
+}
+
+//Active 会话是否有效
+func (s *UserSession) Active(now time.Time) bool {
+	return s.RevokedAt == nil && s.ExpiresAt.After(now)
+}
+
+//Insert 新建会话
+func (s *UserSession) Insert(db *gorm.DB) error {
+	return db.Create(s).Error
+}
+
+//SetTokenID 记录最近签发的access token
+func (s *UserSession) SetTokenID(db *gorm.DB, jti string) error {
+	s.TokenID = jti
+	return db.Model(s).UpdateColumn("token_id", jti).Error
+}
+
+//RotateRefreshToken 轮换refresh token，仅当会话未结束且DB中仍为oldHash时更新，返回是否更新成功
+func (s *UserSession) RotateRefreshToken(db *gorm.DB, oldHash, newHash string) (bool, error) {
+	result := db.Model(s).Where("refresh_token = ? AND revoked_at IS NULL", oldHash).
+		Update("refresh_token", newHash)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	s.RefreshToken = newHash
+	return true, nil
+}
+
+//Revoke 结束会话，仅当会话未结束时更新，返回是否更新成功
+func (s *UserSession) Revoke(db *gorm.DB) (bool, error) {
+	now := time.Now()
+	result := db.Model(s).Where("revoked_at IS NULL").Update("revoked_at", now)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	s.RevokedAt = &now
+	return true, nil
+}
+
+//GetUserSession 根据会话标识查询会话
+func GetUserSession(db *gorm.DB, sessionID string) (*UserSession, error) {
+	var s UserSession
+	err := db.First(&s, "session_id = ?", sessionID).Error
+	return &s, err
+}
+
+//GetUserSessionByFamily 根据refresh token族查询会话
+func GetUserSessionByFamily(db *gorm.DB, family string) (*UserSession, error) {
+	var s UserSession
+	err := db.First(&s, "refresh_family = ?", family).Error
+	return &s, err
+}
+
+//ListActiveUserSessions 查询用户未结束且未过期的会话，按最近访问时间倒序
+func ListActiveUserSessions(db *gorm.DB, uid uint64, now time.Time) ([]*UserSession, error) {
+	var sessions []*UserSession
+	err := db.Where("uid = ? AND revoked_at IS NULL AND expires_at > ?", uid, now).
+		Order("last_seen_at DESC").Find(&sessions).Error
+	return sessions, err
+}
+
+//RevokeUserSessionsByUid 结束用户全部会话
+func RevokeUserSessionsByUid(db *gorm.DB, uid uint64) error {
+	return db.Model(&UserSession{}).Where("uid = ? AND revoked_at IS NULL", uid).
+		Update("revoked_at", time.Now()).Error
+}
+
+//SessionSeen 会话的一次访问记录
+type SessionSeen struct {
+	At time.Time
+	IP string
+}
+
+//UpdateSessionsLastSeen 在一个事务中批量更新会话最近访问时间，只向后更新
+func UpdateSessionsLastSeen(db *gorm.DB, seen map[string]SessionSeen) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for sid, s := range seen {
+			err := tx.Model(&UserSession{}).Where("session_id = ? AND last_seen_at < ?", sid, s.At).
+				Updates(map[string]interface{}{"last_seen_at": s.At, "last_seen_ip": s.IP}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//SmsCode 短信验证码，仅保存验证码哈希；同一手机号及用途仅最新的验证码有效
+type SmsCode struct {
+	gorm.Model
+	Phone     string `gorm:"index:idx_sms_code_phone;size:32"`
+	Purpose   string `gorm:"index:idx_sms_code_phone;size:16"`
+	CodeHash  string `gorm:"size:64"`
+	ClientIP  string `gorm:"index;size:64"` // 请求发送的IP，用于限频
+	Attempts  int    // 校验失败次数
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+//Insert 新建短信验证码
+func (s *SmsCode) Insert(db *gorm.DB) error {
+	return db.Create(s).Error
+}
+
+//RecordFailure 记录一次校验失败，失败次数达到max后不再更新，返回是否记录成功
+func (s *SmsCode) RecordFailure(db *gorm.DB, max int) (bool, error) {
+	result := db.Model(s).Where("attempts < ? AND used_at IS NULL", max).
+		UpdateColumn("attempts", gorm.Expr("attempts + 1"))
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	s.Attempts++
+	return true, nil
+}
+
+//Consume 使用验证码，仅当验证码未使用且失败次数未超过max时更新，返回是否更新成功
+func (s *SmsCode) Consume(db *gorm.DB, max int) (bool, error) {
+	now := time.Now()
+	result := db.Model(s).Where("attempts < ? AND used_at IS NULL", max).Update("used_at", now)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	s.UsedAt = &now
+	return true, nil
+}
+
+//GetLatestSmsCode 查询手机号指定用途最新发送的验证码
+func GetLatestSmsCode(db *gorm.DB, phone, purpose string) (*SmsCode, error) {
+	var code SmsCode
+	err := db.Where("phone = ? AND purpose = ?", phone, purpose).Order("id DESC").First(&code).Error
+	return &code, err
+}
+
+//CountSmsCodesByPhone 统计手机号在since之后发送的验证码数量
+func CountSmsCodesByPhone(db *gorm.DB, phone string, since time.Time) (int64, error) {
+	var count int64
+	err := db.Model(&SmsCode{}).Where("phone = ? AND created_at >= ?", phone, since).Count(&count).Error
+	return count, err
+}
+
+//CountSmsCodesByIP 统计IP在since之后请求发送的验证码数量
+func CountSmsCodesByIP(db *gorm.DB, clientIP string, since time.Time) (int64, error) {
+	var count int64
+	err := db.Model(&SmsCode{}).Where("client_ip = ? AND created_at >= ?", clientIP, since).Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+//RevokedToken 已吊销的JWT，过期后可清理
+type RevokedToken struct {
+	gorm.Model
+	Jti       string    `gorm:"uniqueIndex;size:64"`
+	ExpiresAt time.Time `gorm:"index"` // token过期时间
+}
+
+//TokenWatermark 用户登录态水位，该时间之前签发的JWT均无效
+type TokenWatermark struct {
+	gorm.Model
+	Uid          string    `gorm:"uniqueIndex;size:64"`
+	IssuedBefore time.Time `gorm:"precision:6"` // 与JWT的iat同为微秒精度，避免存储时舍入到秒或毫秒
+}
+
+//Insert 吊销token，重复吊销忽略
+func (t *RevokedToken) Insert(db *gorm.DB) error {
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(t).Error
+}
+
+//IsTokenRevoked jti是否已吊销
+func IsTokenRevoked(db *gorm.DB, jti string) (bool, error) {
+	var count int64
+	err := db.Model(&RevokedToken{}).Where("jti = ?", jti).Count(&count).Error
+	return count > 0, err
+}
+
+//DeleteExpiredRevokedTokens 清理已过期的吊销记录
+func DeleteExpiredRevokedTokens(db *gorm.DB, before time.Time) error {
+	return db.Unscoped().Where("expires_at < ?", before).Delete(&RevokedToken{}).Error
+}
+
+//SetTokenWatermark 设置用户登录态水位
+func SetTokenWatermark(db *gorm.DB, uid string, issuedBefore time.Time) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "uid"}},
+		DoUpdates: clause.AssignmentColumns([]string{"issued_before", "updated_at"}),
+	}).Create(&TokenWatermark{Uid: uid, IssuedBefore: issuedBefore}).Error
+}
+
+//GetTokenWatermark 查询用户登录态水位，未设置时返回零值
+func GetTokenWatermark(db *gorm.DB, uid string) (time.Time, error) {
+	var w TokenWatermark
+	err := db.Where("uid = ?", uid).Limit(1).Find(&w).Error
+	return w.IssuedBefore, err
+}
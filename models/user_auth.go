@@ -0,0 +1,131 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+//PasswordIdentityTypes 使用站内密码登录的身份类型
+var PasswordIdentityTypes = []int{IdentityTypeUsername, IdentityTypeEmail, IdentityTypePhone}
+
+//Insert 新建用户授权信息
+func (a *UserAuth) Insert(db *gorm.DB) error {
+	return db.Create(a).Error
+}
+
+//GetUserAuthByIdentifier 根据身份类型及标识查询用户授权信息
+func GetUserAuthByIdentifier(db *gorm.DB, identityType int, identifier string) (*UserAuth, error) {
+	var auth UserAuth
+	err := db.First(&auth, "identity_type = ? AND identifier = ?", identityType, identifier).Error
+	return &auth, err
+}
+
+//GetUserAuth 根据ID查询用户授权信息
+func GetUserAuth(db *gorm.DB, id uint) (*UserAuth, error) {
+	var auth UserAuth
+	err := db.First(&auth, id).Error
+	return &auth, err
+}
+
+//GetUserAuthByUidAndType 查询用户指定类型的身份
+func GetUserAuthByUidAndType(db *gorm.DB, uid uint64, identityType int) (*UserAuth, error) {
+	var auth UserAuth
+	err := db.First(&auth, "uid = ? AND identity_type = ?", uid, identityType).Error
+	return &auth, err
+}
+
+//GetPasswordUserAuthByUid 查询用户已设置密码的站内身份
+func GetPasswordUserAuthByUid(db *gorm.DB, uid uint64) (*UserAuth, error) {
+	var auth UserAuth
+	err := db.Where("uid = ? AND identity_type IN ? AND certificate <> ''", uid, PasswordIdentityTypes).
+		Order("identity_type").First(&auth).Error
+	return &auth, err
+}
+
+//ListUserAuthsByUid 查询用户绑定的全部身份
+func ListUserAuthsByUid(db *gorm.DB, uid uint64) ([]*UserAuth, error) {
+	var auths []*UserAuth
+	err := db.Where("uid = ?", uid).Order("identity_type").Find(&auths).Error
+	return auths, err
+}
+
+//Unlink 解绑身份，仅当用户还有其他身份时删除，返回是否删除成功
+// 删除后唯一索引释放，该身份可重新绑定
+func (a *UserAuth) Unlink(db *gorm.DB) (bool, error) {
+	var deleted bool
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		// 锁定用户的全部身份，防止并发解绑删除全部身份
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Model(&UserAuth{}).
+			Where("uid = ?", a.Uid).Count(&count).Error
+		if err != nil || count <= 1 {
+			return err
+		}
+		result := tx.Unscoped().Delete(a)
+		deleted = result.RowsAffected > 0
+		return result.Error
+	})
+	return deleted, err
+}
+
+//Locked 是否因密码错误次数过多被锁定
+func (a *UserAuth) Locked(now time.Time) bool {
+	return a.LockedUntil != nil && a.LockedUntil.After(now)
+}
+
+//RecordLoginFailure 记录一次密码错误，连续错误达到maxFailures次时锁定lockFor，返回是否已锁定
+// 错误次数在DB中原子累加，并发登录不会少计
+func (a *UserAuth) RecordLoginFailure(db *gorm.DB, maxFailures int, lockFor time.Duration) (bool, error) {
+	err := db.Model(a).UpdateColumn("failed_attempts", gorm.Expr("failed_attempts + 1")).Error
+	if err != nil {
+		return false, err
+	}
+	err = db.Model(a).Select("failed_attempts").Take(a).Error
+	if err != nil {
+		return false, err
+	}
+	if maxFailures <= 0 || a.FailedAttempts < maxFailures {
+		return false, nil
+	}
+
+	lockedUntil := time.Now().Add(lockFor)
+	a.FailedAttempts = 0
+	a.LockedUntil = &lockedUntil
+	return true, db.Model(a).Updates(map[string]interface{}{
+		"failed_attempts": 0,
+		"locked_until":    lockedUntil,
+	}).Error
+}
+
+//ResetLoginFailures 登录成功后清除密码错误次数
+func (a *UserAuth) ResetLoginFailures(db *gorm.DB) error {
+	if a.FailedAttempts == 0 && a.LockedUntil == nil {
+		return nil
+	}
+	a.FailedAttempts = 0
+	a.LockedUntil = nil
+	return db.Model(a).Updates(map[string]interface{}{
+		"failed_attempts": 0,
+		"locked_until":    nil,
+	}).Error
+}
+
+//UpdatePasswordByUid 修改用户全部站内身份的密码，并解除锁定
+func UpdatePasswordByUid(db *gorm.DB, uid uint64, certificate string) error {
+	return db.Model(&UserAuth{}).
+		Where("uid = ? AND identity_type IN ?", uid, PasswordIdentityTypes).
+		Updates(map[string]interface{}{
+			"certificate":     certificate,
+			"failed_attempts": 0,
+			"locked_until":    nil,
+		}).Error
+}
+
+//MarkVerified 记录身份已验证
+func (a *UserAuth) MarkVerified(db *gorm.DB) error {
+	now := time.Now()
+	a.VerifiedAt = &now
+	return db.Model(a).Update("verified_at", now).Error
+}
@@ -1,11 +1,11 @@
 package seewo
 
 import (
+	"context"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"net/http"
 	"net/url"
 	"sort"
 	"strconv"
@@ -35,12 +35,12 @@ type SeeWoAccessTokenResponse struct {
 }
 
 //GetSeeWoAccessToken 获取seewo开放平台AccessToken
-func GetSeeWoAccessToken(appid, secret string, code string) (*SeeWoAccessTokenResponse, error) {
+func GetSeeWoAccessToken(ctx context.Context, appid, secret string, code string) (*SeeWoAccessTokenResponse, error) {
 	url := "https://openapi.seewo.com/api/oauth2/access_token?" +
 		fmt.Sprintf("app_id=%s&app_secret=%s&code=%s&grant_type=authorization_code",
 			appid, secret, code)
 
-	resp, err := http.Get(url)
+	resp, err := utils.HttpGet(ctx, url)
 	if err != nil {
 		return nil, errcode.NewCustomError(errcode.ErrCodeInternalError,
 			fmt.Sprintf("获取希沃AccessToken异常:%s", err.Error()))
@@ -69,7 +69,7 @@ type SeeWoUserInfo struct {
 }
 
 //GetSeeWoUserInfo seewo开放平台获取用户信息
-func GetSeeWoUserInfo(appid, secret, access_token, openid string) (*SeeWoUserInfo, error) {
+func GetSeeWoUserInfo(ctx context.Context, appid, secret, access_token, openid string) (*SeeWoUserInfo, error) {
 	requrl := "https://openapi.seewo.com/user-center/user-api/user-base"
 	body := []byte(fmt.Sprintf("{\"uid\":\"%s\"}", openid))
 
@@ -105,7 +105,7 @@ func GetSeeWoUserInfo(appid, secret, access_token, openid string) (*SeeWoUserInf
 	headers["x-sw-req-path"] = url.QueryEscape(headers["x-sw-req-path"])
 
 	// request
-	body, err := utils.PostRequest(requrl, headers, body)
+	body, err := utils.PostRequest(ctx, requrl, headers, body)
 	if err != nil {
 		return nil, errcode.NewCustomError(errcode.ErrCodeInternalError,
 			fmt.Sprintf("获取希沃用户信息异常:%s", err.Error()))
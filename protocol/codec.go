@@ -0,0 +1,417 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"ginfra/log"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/ugorji/go/codec"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// 内置编码的媒体类型
+const (
+	MIMEJSON     = "application/json"
+	MIMEMsgPack  = "application/x-msgpack"
+	MIMEProtobuf = "application/x-protobuf"
+	MIMEXML      = "application/xml"
+)
+
+//Codec 响应编码及请求解码，响应按Accept、请求按Content-Type选择
+type Codec interface {
+	ContentType() string                     // 响应的Content-Type
+	Encode(w io.Writer, v interface{}) error // v为Response、ErrorResponse等响应结构
+	Decode(r io.Reader, v interface{}) error // v为请求参数结构体的指针
+}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = make(map[string]Codec)
+	// 注册顺序，Accept为type/*时按该顺序匹配
+	codecTypes []string
+)
+
+func init() {
+	RegisterCodec(jsonCodec{}, MIMEJSON)
+	RegisterCodec(msgpackCodec{handle: newMsgpackHandle()}, MIMEMsgPack, "application/msgpack")
+	RegisterCodec(protobufCodec{}, MIMEProtobuf, "application/protobuf")
+	RegisterCodec(xmlCodec{}, MIMEXML, "text/xml")
+}
+
+//RegisterCodec 注册编码，mediaTypes为匹配Accept及Content-Type的媒体类型，已注册的媒体类型被替换
+func RegisterCodec(c Codec, mediaTypes ...string) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	for _, t := range mediaTypes {
+		t = strings.ToLower(t)
+		if _, ok := codecs[t]; !ok {
+			codecTypes = append(codecTypes, t)
+		}
+		codecs[t] = c
+	}
+}
+
+// negotiateCodec 返回Accept中权重最高的已注册编码，支持type/*及*/*，都不支持时使用JSON
+func negotiateCodec(accept string) Codec {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	best, bestQ := codecs[MIMEJSON], 0.0
+	for _, part := range strings.Split(accept, ",") {
+		tag, q := splitQuality(part)
+		if len(tag) == 0 || q <= bestQ {
+			continue
+		}
+		if c, ok := codecs[tag]; ok {
+			best, bestQ = c, q
+			continue
+		}
+		// 通配时JSON优先，保持未指定Accept时的行为
+		for _, t := range append([]string{MIMEJSON}, codecTypes...) {
+			if tag == "*/*" || (strings.HasSuffix(tag, "/*") && strings.HasPrefix(t, tag[:len(tag)-1])) {
+				best, bestQ = codecs[t], q
+				break
+			}
+		}
+	}
+	return best
+}
+
+// requestCodec 按Content-Type选择请求解码，未携带或未注册时使用JSON
+func requestCodec(contentType string) Codec {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	if t, _, err := mime.ParseMediaType(contentType); err == nil {
+		if c, ok := codecs[t]; ok {
+			return c
+		}
+	}
+	return codecs[MIMEJSON]
+}
+
+// splitQuality 解析Accept类请求头中的一项，返回小写的值及q权重
+func splitQuality(part string) (string, float64) {
+	tag, q := part, 1.0
+	if i := strings.IndexByte(part, ';'); i >= 0 {
+		tag = part[:i]
+		for _, param := range strings.Split(part[i+1:], ";") {
+			if v := strings.TrimSpace(param); strings.HasPrefix(v, "q=") {
+				if f, err := strconv.ParseFloat(v[2:], 64); err == nil {
+					q = f
+				}
+			}
+		}
+	}
+	return strings.ToLower(strings.TrimSpace(tag)), q
+}
+
+// render 按Accept编码响应，编码失败时使用JSON
+func render(c *gin.Context, status int, v interface{}) {
+	cc := negotiateCodec(c.GetHeader("Accept"))
+	var buf bytes.Buffer
+	if err := cc.Encode(&buf, v); err != nil {
+		log.WithGinContext(c).Error("encode response fail", zap.String("content-type", cc.ContentType()),
+			zap.String("error", err.Error()))
+		c.JSON(status, v)
+		return
+	}
+	c.Header("Vary", "Accept")
+	c.Data(status, cc.ContentType(), buf.Bytes())
+}
+
+//Bind 按Content-Type解码请求体并校验binding规则，未携带Content-Type时按JSON解码
+// 替代c.ShouldBindJSON，校验错误可通过InvalidParameter返回
+func Bind(c *gin.Context, obj interface{}) error {
+	if c.Request == nil || c.Request.Body == nil {
+		return errors.New("invalid request")
+	}
+	if err := requestCodec(c.ContentType()).Decode(c.Request.Body, obj); err != nil {
+		return err
+	}
+	if binding.Validator == nil {
+		return nil
+	}
+	return binding.Validator.ValidateStruct(obj)
+}
+
+// generic 将响应转换为JSON等价的通用结构，整数为int64或uint64，保证各编码与JSON响应的内容一致
+func generic(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var tree interface{}
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	if err := decoder.Decode(&tree); err != nil {
+		return nil, err
+	}
+	return convertNumbers(tree), nil
+}
+
+func convertNumbers(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, e := range t {
+			t[k] = convertNumbers(e)
+		}
+	case []interface{}:
+		for i, e := range t {
+			t[i] = convertNumbers(e)
+		}
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i
+		}
+		if u, err := strconv.ParseUint(t.String(), 10, 64); err == nil {
+			return u
+		}
+		f, _ := t.Float64()
+		return f
+	}
+	return v
+}
+
+// decodeGeneric 将解码得到的通用结构经JSON转换为请求参数，字段名、类型与JSON请求一致
+func decodeGeneric(tree interface{}, v interface{}) error {
+	b, err := json.Marshal(tree)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string {
+	return "application/json; charset=utf-8"
+}
+
+func (jsonCodec) Encode(w io.Writer, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+func (jsonCodec) Decode(r io.Reader, v interface{}) error {
+	return json.NewDecoder(r).Decode(v)
+}
+
+type msgpackCodec struct {
+	handle *codec.MsgpackHandle
+}
+
+func newMsgpackHandle() *codec.MsgpackHandle {
+	h := &codec.MsgpackHandle{}
+	h.RawToString = true
+	return h
+}
+
+func (msgpackCodec) ContentType() string {
+	return MIMEMsgPack
+}
+
+func (m msgpackCodec) Encode(w io.Writer, v interface{}) error {
+	tree, err := generic(v)
+	if err != nil {
+		return err
+	}
+	return codec.NewEncoder(w, m.handle).Encode(tree)
+}
+
+func (m msgpackCodec) Decode(r io.Reader, v interface{}) error {
+	var tree interface{}
+	if err := codec.NewDecoder(r, m.handle).Decode(&tree); err != nil {
+		return err
+	}
+	return decodeGeneric(stringKeys(tree), v)
+}
+
+// stringKeys msgpack解码的map键为interface{}，转换为string以便按JSON处理
+func stringKeys(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, e := range t {
+			m[fmt.Sprint(k)] = stringKeys(e)
+		}
+		return m
+	case map[string]interface{}:
+		for k, e := range t {
+			t[k] = stringKeys(e)
+		}
+	case []interface{}:
+		for i, e := range t {
+			t[i] = stringKeys(e)
+		}
+	}
+	return v
+}
+
+// protobufCodec 以google.protobuf.Struct编码，超出float64精确范围的整数以字符串表示
+type protobufCodec struct{}
+
+// float64可以精确表示的最大整数
+const maxSafeInteger = 1<<53 - 1
+
+func (protobufCodec) ContentType() string {
+	return MIMEProtobuf
+}
+
+func (protobufCodec) Encode(w io.Writer, v interface{}) error {
+	tree, err := generic(v)
+	if err != nil {
+		return err
+	}
+	value, err := structpb.NewValue(safeIntegers(tree))
+	if err != nil {
+		return err
+	}
+	var msg proto.Message = value
+	if s := value.GetStructValue(); s != nil {
+		msg = s
+	}
+	b, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+func (protobufCodec) Decode(r io.Reader, v interface{}) error {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	var s structpb.Struct
+	if err := proto.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	return decodeGeneric(s.AsMap(), v)
+}
+
+func safeIntegers(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, e := range t {
+			t[k] = safeIntegers(e)
+		}
+	case []interface{}:
+		for i, e := range t {
+			t[i] = safeIntegers(e)
+		}
+	case int64:
+		if t > maxSafeInteger || t < -maxSafeInteger {
+			return strconv.FormatInt(t, 10)
+		}
+	case uint64:
+		if t > maxSafeInteger {
+			return strconv.FormatUint(t, 10)
+		}
+	}
+	return v
+}
+
+// xmlCodec 对象的键为元素名，不是合法元素名的键输出为<entry key="...">，数组为同名的重复元素，根元素为对象唯一的键或xml
+type xmlCodec struct{}
+
+func (xmlCodec) ContentType() string {
+	return "application/xml; charset=utf-8"
+}
+
+func (xmlCodec) Encode(w io.Writer, v interface{}) error {
+	tree, err := generic(v)
+	if err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	if m, ok := tree.(map[string]interface{}); ok && len(m) == 1 {
+		for k, e := range m {
+			err = encodeXML(enc, k, e)
+		}
+	} else {
+		err = encodeXML(enc, "xml", tree)
+	}
+	if err != nil {
+		return err
+	}
+	return enc.Flush()
+}
+
+func encodeXML(enc *xml.Encoder, name string, v interface{}) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if !isXMLName(name) {
+		start = xml.StartElement{
+			Name: xml.Name{Local: "entry"},
+			Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: name}},
+		}
+	}
+	switch t := v.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		for _, e := range t {
+			if err := encodeXML(enc, name, e); err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]interface{}:
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if err := encodeXML(enc, k, t[k]); err != nil {
+				return err
+			}
+		}
+		return enc.EncodeToken(start.End())
+	}
+	return enc.EncodeElement(v, start)
+}
+
+// isXMLName 是否为不含命名空间前缀的合法XML元素名
+func isXMLName(name string) bool {
+	if len(name) == 0 || !utf8.ValidString(name) {
+		return false
+	}
+	for i, r := range name {
+		if unicode.IsLetter(r) || r == '_' {
+			continue
+		}
+		if i == 0 || !(unicode.IsDigit(r) || r == '-' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// Decode 按encoding/xml解码，字段名即元素名，可使用xml标签，根元素名不限
+func (xmlCodec) Decode(r io.Reader, v interface{}) error {
+	return xml.NewDecoder(r).Decode(v)
+}
@@ -0,0 +1,141 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ginfra/log"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smartystreets/goconvey/convey"
+	"github.com/ugorji/go/codec"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func Test_NegotiateCodec(t *testing.T) {
+	convey.Convey("accept", t, func() {
+		convey.So(negotiateCodec(""), convey.ShouldHaveSameTypeAs, jsonCodec{})
+		convey.So(negotiateCodec("*/*"), convey.ShouldHaveSameTypeAs, jsonCodec{})
+		convey.So(negotiateCodec("text/html"), convey.ShouldHaveSameTypeAs, jsonCodec{})
+		convey.So(negotiateCodec("application/x-msgpack"), convey.ShouldHaveSameTypeAs, msgpackCodec{})
+		convey.So(negotiateCodec("application/json;q=0.5, application/x-protobuf"), convey.ShouldHaveSameTypeAs, protobufCodec{})
+		convey.So(negotiateCodec("text/*"), convey.ShouldHaveSameTypeAs, xmlCodec{})
+		convey.So(requestCodec("application/xml; charset=utf-8"), convey.ShouldHaveSameTypeAs, xmlCodec{})
+		convey.So(requestCodec(""), convey.ShouldHaveSameTypeAs, jsonCodec{})
+	})
+}
+
+type codecRequest struct {
+	Name  string   `binding:"required"`
+	Uid   uint64   `binding:"required"`
+	Roles []string `binding:"max=2"`
+}
+
+func Test_Codecs(t *testing.T) {
+	oldLogger := log.ZLog
+	defer func() { log.ZLog = oldLogger }()
+	log.ZLog = zap.NewNop()
+
+	gin.SetMode(gin.TestMode)
+	g := gin.New()
+	g.POST("/echo", func(c *gin.Context) {
+		var req codecRequest
+		if err := Bind(c, &req); err != nil {
+			SetErrResponse(c, InvalidParameter(err))
+			return
+		}
+		SetResponse(c, &req)
+	})
+	do := func(contentType string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/echo", bytes.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Accept", contentType)
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, req)
+		return w
+	}
+	const uid = uint64(1) << 60
+
+	convey.Convey("json", t, func() {
+		w := do(MIMEJSON, []byte(`{"Name":"a","Uid":1152921504606846976,"Roles":["x"]}`))
+		convey.So(w.Header().Get("Content-Type"), convey.ShouldStartWith, MIMEJSON)
+		convey.So(w.Body.String(), convey.ShouldContainSubstring, `"Uid":1152921504606846976`)
+	})
+
+	convey.Convey("msgpack", t, func() {
+		h := newMsgpackHandle()
+		var body []byte
+		codec.NewEncoderBytes(&body, h).Encode(map[string]interface{}{"Name": "a", "Uid": uid, "Roles": []string{"x"}})
+		w := do(MIMEMsgPack, body)
+		convey.So(w.Header().Get("Content-Type"), convey.ShouldEqual, MIMEMsgPack)
+
+		var resp map[string]interface{}
+		convey.So(codec.NewDecoderBytes(w.Body.Bytes(), h).Decode(&resp), convey.ShouldBeNil)
+		inner := stringKeys(resp["Response"]).(map[string]interface{})
+		convey.So(inner["Name"], convey.ShouldEqual, "a")
+		convey.So(inner["Uid"], convey.ShouldEqual, uid)
+		convey.So(inner["RequestId"], convey.ShouldNotBeNil)
+	})
+
+	convey.Convey("protobuf", t, func() {
+		s, _ := structpb.NewStruct(map[string]interface{}{"Name": "a", "Uid": 7, "Roles": []interface{}{"x", "y", "z"}})
+		body, _ := proto.Marshal(s)
+		w := do(MIMEProtobuf, body)
+		convey.So(w.Header().Get("Content-Type"), convey.ShouldEqual, MIMEProtobuf)
+
+		var resp structpb.Struct
+		convey.So(proto.Unmarshal(w.Body.Bytes(), &resp), convey.ShouldBeNil)
+		e := resp.AsMap()["Response"].(map[string]interface{})["Error"].(map[string]interface{})
+		convey.So(e["Code"], convey.ShouldEqual, ErrCodeInvalidParameter.Code)
+		convey.So(e["Details"].([]interface{})[0].(map[string]interface{})["Field"], convey.ShouldEqual, "Roles")
+
+		// 超出float64精确范围的整数以字符串表示
+		var out bytes.Buffer
+		convey.So(protobufCodec{}.Encode(&out, map[string]interface{}{"Uid": uid}), convey.ShouldBeNil)
+		convey.So(proto.Unmarshal(out.Bytes(), &resp), convey.ShouldBeNil)
+		convey.So(resp.AsMap()["Uid"], convey.ShouldEqual, "1152921504606846976")
+	})
+
+	convey.Convey("xml", t, func() {
+		w := do(MIMEXML, []byte(`<xml><Name>a</Name><Uid>7</Uid><Roles>x</Roles><Roles>y</Roles></xml>`))
+		convey.So(w.Header().Get("Content-Type"), convey.ShouldStartWith, MIMEXML)
+		body := w.Body.String()
+		convey.So(body, convey.ShouldStartWith, "<Response>")
+		convey.So(body, convey.ShouldContainSubstring, "<Name>a</Name><RequestId>")
+		convey.So(body, convey.ShouldContainSubstring, "<Roles>x</Roles><Roles>y</Roles>")
+
+		var resp struct {
+			Name  string
+			Uid   uint64
+			Roles []string
+		}
+		convey.So(xml.Unmarshal(w.Body.Bytes(), &resp), convey.ShouldBeNil)
+		convey.So(resp.Uid, convey.ShouldEqual, 7)
+		convey.So(resp.Roles, convey.ShouldResemble, []string{"x", "y"})
+
+		// 不是合法元素名的键
+		var out bytes.Buffer
+		convey.So(xmlCodec{}.Encode(&out, map[string]interface{}{
+			"Data": map[string]interface{}{"ok": 1, "1a": 2, "a b": 3, "<x>": "v", "x:y": 4, "": 5},
+		}), convey.ShouldBeNil)
+		convey.So(out.String(), convey.ShouldEqual, `<Data><entry key="">5</entry><entry key="1a">2</entry>`+
+			`<entry key="&lt;x&gt;">v</entry><entry key="a b">3</entry><ok>1</ok><entry key="x:y">4</entry></Data>`)
+		var tree struct {
+			Entries []struct {
+				Key   string `xml:"key,attr"`
+				Value string `xml:",chardata"`
+			} `xml:"entry"`
+		}
+		convey.So(xml.Unmarshal(out.Bytes(), &tree), convey.ShouldBeNil)
+		convey.So(len(tree.Entries), convey.ShouldEqual, 5)
+
+		w = do(MIMEXML, []byte(`<xml><Uid>7</Uid></xml>`))
+		convey.So(strings.Contains(w.Body.String(), "<Code>InvalidParameter</Code>"), convey.ShouldBeTrue)
+	})
+}
@@ -13,6 +13,23 @@ var CtxUserID = "X-User-ID"             // 用户ID, 问答用户
 var CtxCustomerID = "X-Customer-ID"     // 客户ID, 商户、客服等
 var CtxResponseCode = "X-Response-Code" // 返回码
 
+// 鉴权信息
+var CtxAuthType = "X-Auth-Type"              // 鉴权方式, jwt或apikey
+var CtxScopes = "X-Scopes"                   // API密钥授权范围, []string
+var CtxTokenID = "X-Token-ID"                // JWT的jti
+var CtxTokenExpiresAt = "X-Token-Expires-At" // 同一jti的JWT最晚的过期时间，含续期, time.Time
+var CtxPermissions = "X-Permissions"         // JWT claims中的权限, []string
+var CtxAuthTime = "X-Auth-Time"              // JWT的登录时间, time.Time
+var CtxMFALevel = "X-MFA-Level"              // JWT的二次验证级别, int
+var CtxMFATime = "X-MFA-Time"                // JWT的二次验证时间, time.Time
+var CtxSessionID = "X-Session-ID"            // JWT的登录会话标识
+var CtxTenants = "X-Tenants"                 // JWT claims中用户所属的客户(租户), []string
+
+const (
+	AuthTypeJWT    = "jwt"
+	AuthTypeApiKey = "apikey"
+)
+
 //GetUserId 获取gin请求UserId
 func GetUserId(c *gin.Context) string {
 	if ctxUserId, ok := c.Value(CtxUserID).(string); ok {
@@ -22,6 +39,51 @@ func GetUserId(c *gin.Context) string {
 	return ""
 }
 
+//GetCustomerId 获取gin请求CustomerId
+func GetCustomerId(c *gin.Context) string {
+	if ctxCustomerId, ok := c.Value(CtxCustomerID).(string); ok {
+		return ctxCustomerId
+	}
+
+	return ""
+}
+
+//GetAuthType 获取gin请求鉴权方式
+func GetAuthType(c *gin.Context) string {
+	if ctxAuthType, ok := c.Value(CtxAuthType).(string); ok {
+		return ctxAuthType
+	}
+
+	return ""
+}
+
+//GetScopes 获取gin请求API密钥授权范围
+func GetScopes(c *gin.Context) []string {
+	if ctxScopes, ok := c.Value(CtxScopes).([]string); ok {
+		return ctxScopes
+	}
+
+	return nil
+}
+
+//GetTenants 获取gin请求JWT登录态所属的客户(租户)
+func GetTenants(c *gin.Context) []string {
+	if ctxTenants, ok := c.Value(CtxTenants).([]string); ok {
+		return ctxTenants
+	}
+
+	return nil
+}
+
+//GetTokenId 获取gin请求JWT的jti
+func GetTokenId(c *gin.Context) string {
+	if ctxTokenId, ok := c.Value(CtxTokenID).(string); ok {
+		return ctxTokenId
+	}
+
+	return ""
+}
+
 //GetRequestId 获取gin请求ID
 func GetRequestId(c *gin.Context) string {
 	if ctxReqId, ok := c.Value(CtxRequestID).(string); ok {
@@ -1,35 +1,353 @@
 package protocol
 
 import (
+	"net/http"
+
 	"ginfra/errcode"
 )
 
-var ErrCodeInvalidParameter *errcode.CustomError = &errcode.CustomError{
-	Code:    errcode.ErrInvalidParam,
-	Message: "请求参数解析错误",
-}
-
-var ErrCodeMissingParameter *errcode.CustomError = &errcode.CustomError{
-	Code:    "MissingParam",
-	Message: "缺失必填请求参数",
-}
-
-var ErrCodeInvalidClaims *errcode.CustomError = &errcode.CustomError{
-	Code:    "InvalidJWTClaims",
-	Message: "用户登录态信息无效，请重新登录",
-}
-
-var ErrCodeUnAuthorized *errcode.CustomError = &errcode.CustomError{
-	Code:    "UnauthorizedOperation",
-	Message: "无权限，请检查账号是否有权限访问相关数据",
-}
-
-var ErrCodeInvalidWXCode *errcode.CustomError = &errcode.CustomError{
-	Code:    "InvalidWXCode",
-	Message: "微信登录CODE无效",
-}
-
-var ErrCodeDBException *errcode.CustomError = &errcode.CustomError{
-	Code:    "DataException",
-	Message: "操作数据异常，请稍后重试",
-}
+var ErrCodeInvalidParameter = errcode.Register(errcode.Definition{
+	Code:       errcode.ErrInvalidParam,
+	HTTPStatus: http.StatusBadRequest,
+	Messages: map[string]string{
+		errcode.LangZH: "请求参数解析错误",
+		errcode.LangEN: "Invalid request parameters",
+	},
+})
+
+var ErrCodeMissingParameter = errcode.Register(errcode.Definition{
+	Code:       "MissingParam",
+	HTTPStatus: http.StatusBadRequest,
+	Messages: map[string]string{
+		errcode.LangZH: "缺失必填请求参数",
+		errcode.LangEN: "Missing required parameters",
+	},
+})
+
+var ErrCodeInvalidClaims = errcode.Register(errcode.Definition{
+	Code:       "InvalidJWTClaims",
+	HTTPStatus: http.StatusUnauthorized,
+	Messages: map[string]string{
+		errcode.LangZH: "用户登录态信息无效，请重新登录",
+		errcode.LangEN: "Invalid login claims, please log in again",
+	},
+})
+
+var ErrCodeUnAuthorized = errcode.Register(errcode.Definition{
+	Code:       "UnauthorizedOperation",
+	HTTPStatus: http.StatusForbidden,
+	Messages: map[string]string{
+		errcode.LangZH: "无权限，请检查账号是否有权限访问相关数据",
+		errcode.LangEN: "Unauthorized operation, please check your permissions",
+	},
+})
+
+var ErrCodeInvalidWXCode = errcode.Register(errcode.Definition{
+	Code:       "InvalidWXCode",
+	HTTPStatus: http.StatusBadRequest,
+	Messages: map[string]string{
+		errcode.LangZH: "微信登录CODE无效",
+		errcode.LangEN: "Invalid WeChat login code",
+	},
+})
+
+var ErrCodeDBException = errcode.Register(errcode.Definition{
+	Code:       "DataException",
+	HTTPStatus: http.StatusInternalServerError,
+	Retryable:  true,
+	Messages: map[string]string{
+		errcode.LangZH: "操作数据异常，请稍后重试",
+		errcode.LangEN: "Data operation failed, please try again later",
+	},
+})
+
+var ErrCodeApiKeyNotFound = errcode.Register(errcode.Definition{
+	Code:       "ApiKeyNotFound",
+	HTTPStatus: http.StatusNotFound,
+	Messages: map[string]string{
+		errcode.LangZH: "API密钥不存在",
+		errcode.LangEN: "API key not found",
+	},
+})
+
+var ErrCodeServiceUnavailable = errcode.Register(errcode.Definition{
+	Code:       "ServiceUnavailable",
+	HTTPStatus: http.StatusServiceUnavailable,
+	Retryable:  true,
+	Messages: map[string]string{
+		errcode.LangZH: "服务维护中，请稍后重试",
+		errcode.LangEN: "Service under maintenance, please try again later",
+	},
+})
+
+var ErrCodeInvalidRefreshToken = errcode.Register(errcode.Definition{
+	Code:       "InvalidRefreshToken",
+	HTTPStatus: http.StatusUnauthorized,
+	Messages: map[string]string{
+		errcode.LangZH: "refresh token无效，请重新登录",
+		errcode.LangEN: "Invalid refresh token, please log in again",
+	},
+})
+
+var ErrCodeExpiredRefreshToken = errcode.Register(errcode.Definition{
+	Code:       "ExpiredRefreshToken",
+	HTTPStatus: http.StatusUnauthorized,
+	Messages: map[string]string{
+		errcode.LangZH: "refresh token已过期，请重新登录",
+		errcode.LangEN: "Refresh token expired, please log in again",
+	},
+})
+
+var ErrCodeRoleNotFound = errcode.Register(errcode.Definition{
+	Code:       "RoleNotFound",
+	HTTPStatus: http.StatusNotFound,
+	Messages: map[string]string{
+		errcode.LangZH: "角色不存在",
+		errcode.LangEN: "Role not found",
+	},
+})
+
+var ErrCodeAccountExists = errcode.Register(errcode.Definition{
+	Code:       "AccountExists",
+	HTTPStatus: http.StatusConflict,
+	Messages: map[string]string{
+		errcode.LangZH: "账号已存在",
+		errcode.LangEN: "Account already exists",
+	},
+})
+
+var ErrCodeInvalidCredentials = errcode.Register(errcode.Definition{
+	Code:       "InvalidCredentials",
+	HTTPStatus: http.StatusUnauthorized,
+	Messages: map[string]string{
+		errcode.LangZH: "账号或密码错误",
+		errcode.LangEN: "Incorrect account or password",
+	},
+})
+
+var ErrCodeAccountLocked = errcode.Register(errcode.Definition{
+	Code:       "AccountLocked",
+	HTTPStatus: http.StatusForbidden,
+	Retryable:  true,
+	Messages: map[string]string{
+		errcode.LangZH: "密码错误次数过多，账号已临时锁定，请稍后重试",
+		errcode.LangEN: "Too many failed attempts, the account is temporarily locked",
+	},
+})
+
+var ErrCodeEmailNotVerified = errcode.Register(errcode.Definition{
+	Code:       "EmailNotVerified",
+	HTTPStatus: http.StatusForbidden,
+	Messages: map[string]string{
+		errcode.LangZH: "邮箱尚未验证，请通过验证邮件中的链接完成验证后登录",
+		errcode.LangEN: "Email is not verified, please follow the link in the verification email before logging in",
+	},
+})
+
+var ErrCodeWeakPassword = errcode.Register(errcode.Definition{
+	Code:       "WeakPassword",
+	HTTPStatus: http.StatusBadRequest,
+	Messages: map[string]string{
+		errcode.LangZH: "密码长度需为{min}到{max}位",
+		errcode.LangEN: "Password must be {min} to {max} characters",
+	},
+})
+
+var ErrCodeInvalidResetToken = errcode.Register(errcode.Definition{
+	Code:       "InvalidResetToken",
+	HTTPStatus: http.StatusBadRequest,
+	Messages: map[string]string{
+		errcode.LangZH: "找回密码凭证无效或已过期",
+		errcode.LangEN: "Password reset token is invalid or expired",
+	},
+})
+
+var ErrCodeProviderNotFound = errcode.Register(errcode.Definition{
+	Code:       "IdentityProviderNotFound",
+	HTTPStatus: http.StatusNotFound,
+	Messages: map[string]string{
+		errcode.LangZH: "不支持该第三方登录方式",
+		errcode.LangEN: "Unsupported identity provider",
+	},
+})
+
+var ErrCodeInvalidAuthCode = errcode.Register(errcode.Definition{
+	Code:       "InvalidAuthCode",
+	HTTPStatus: http.StatusBadRequest,
+	Messages: map[string]string{
+		errcode.LangZH: "第三方登录CODE无效",
+		errcode.LangEN: "Invalid identity provider code",
+	},
+})
+
+var ErrCodeInvalidOAuthState = errcode.Register(errcode.Definition{
+	Code:       "InvalidOAuthState",
+	HTTPStatus: http.StatusBadRequest,
+	Messages: map[string]string{
+		errcode.LangZH: "授权已过期，请重新登录",
+		errcode.LangEN: "Authorization expired, please log in again",
+	},
+})
+
+var ErrCodeIdentityLinked = errcode.Register(errcode.Definition{
+	Code:       "IdentityLinked",
+	HTTPStatus: http.StatusConflict,
+	Messages: map[string]string{
+		errcode.LangZH: "该身份已绑定其他账号，请联系管理员合并账号",
+		errcode.LangEN: "Identity is linked to another account, please contact the administrator to merge accounts",
+	},
+})
+
+var ErrCodeIdentityTypeLinked = errcode.Register(errcode.Definition{
+	Code:       "IdentityTypeLinked",
+	HTTPStatus: http.StatusConflict,
+	Messages: map[string]string{
+		errcode.LangZH: "已绑定同类型的身份，请先解绑",
+		errcode.LangEN: "An identity of the same type is already linked, please unlink it first",
+	},
+})
+
+var ErrCodeIdentityNotFound = errcode.Register(errcode.Definition{
+	Code:       "IdentityNotFound",
+	HTTPStatus: http.StatusNotFound,
+	Messages: map[string]string{
+		errcode.LangZH: "身份不存在",
+		errcode.LangEN: "Identity not found",
+	},
+})
+
+var ErrCodeLastIdentity = errcode.Register(errcode.Definition{
+	Code:       "LastIdentity",
+	HTTPStatus: http.StatusBadRequest,
+	Messages: map[string]string{
+		errcode.LangZH: "不能解绑唯一的登录方式",
+		errcode.LangEN: "Cannot unlink the only login method",
+	},
+})
+
+var ErrCodeInvalidVerifyCode = errcode.Register(errcode.Definition{
+	Code:       "InvalidVerifyCode",
+	HTTPStatus: http.StatusBadRequest,
+	Messages: map[string]string{
+		errcode.LangZH: "验证码错误或已过期",
+		errcode.LangEN: "Verification code is incorrect or expired",
+	},
+})
+
+var ErrCodeOAuthClientNotFound = errcode.Register(errcode.Definition{
+	Code:       "OAuthClientNotFound",
+	HTTPStatus: http.StatusNotFound,
+	Messages: map[string]string{
+		errcode.LangZH: "OAuth客户端不存在或已禁用",
+		errcode.LangEN: "OAuth client not found or disabled",
+	},
+})
+
+var ErrCodeInvalidRedirectURI = errcode.Register(errcode.Definition{
+	Code:       "InvalidRedirectURI",
+	HTTPStatus: http.StatusBadRequest,
+	Messages: map[string]string{
+		errcode.LangZH: "回调地址未登记",
+		errcode.LangEN: "Redirect URI is not registered",
+	},
+})
+
+var ErrCodeSmsTooFrequent = errcode.Register(errcode.Definition{
+	Code:       "SmsTooFrequent",
+	HTTPStatus: http.StatusTooManyRequests,
+	Retryable:  true,
+	Messages: map[string]string{
+		errcode.LangZH: "验证码发送过于频繁，请稍后再试",
+		errcode.LangEN: "Verification codes requested too frequently, please try again later",
+	},
+})
+
+var ErrCodeCaptchaRequired = errcode.Register(errcode.Definition{
+	Code:       "CaptchaRequired",
+	HTTPStatus: http.StatusBadRequest,
+	Messages: map[string]string{
+		errcode.LangZH: "请先完成图形验证码",
+		errcode.LangEN: "Please complete the captcha first",
+	},
+})
+
+var ErrCodePhoneNotLinked = errcode.Register(errcode.Definition{
+	Code:       "PhoneNotLinked",
+	HTTPStatus: http.StatusBadRequest,
+	Messages: map[string]string{
+		errcode.LangZH: "未绑定手机号",
+		errcode.LangEN: "No phone number linked",
+	},
+})
+
+var ErrCodeInvalidVerifyToken = errcode.Register(errcode.Definition{
+	Code:       "InvalidVerifyToken",
+	HTTPStatus: http.StatusBadRequest,
+	Messages: map[string]string{
+		errcode.LangZH: "验证链接无效或已过期",
+		errcode.LangEN: "Verification link is invalid or expired",
+	},
+})
+
+var ErrCodeEmailNotLinked = errcode.Register(errcode.Definition{
+	Code:       "EmailNotLinked",
+	HTTPStatus: http.StatusBadRequest,
+	Messages: map[string]string{
+		errcode.LangZH: "未绑定邮箱",
+		errcode.LangEN: "No email linked",
+	},
+})
+
+var ErrCodeCaptchaFailed = errcode.Register(errcode.Definition{
+	Code:       "CaptchaFailed",
+	HTTPStatus: http.StatusBadRequest,
+	Messages: map[string]string{
+		errcode.LangZH: "图形验证码校验失败，请重试",
+		errcode.LangEN: "Captcha verification failed, please try again",
+	},
+})
+
+var ErrCodeMFARequired = errcode.Register(errcode.Definition{
+	Code:       "MFARequired",
+	HTTPStatus: http.StatusForbidden,
+	Messages: map[string]string{
+		errcode.LangZH: "请先完成二次验证",
+		errcode.LangEN: "Please complete two-factor authentication first",
+	},
+})
+
+var ErrCodeInvalidMFACode = errcode.Register(errcode.Definition{
+	Code:       "InvalidMFACode",
+	HTTPStatus: http.StatusBadRequest,
+	Messages: map[string]string{
+		errcode.LangZH: "动态验证码或恢复码错误",
+		errcode.LangEN: "Incorrect authenticator code or recovery code",
+	},
+})
+
+var ErrCodeMFAAlreadyEnabled = errcode.Register(errcode.Definition{
+	Code:       "MFAAlreadyEnabled",
+	HTTPStatus: http.StatusConflict,
+	Messages: map[string]string{
+		errcode.LangZH: "已启用二次验证",
+		errcode.LangEN: "Two-factor authentication is already enabled",
+	},
+})
+
+var ErrCodeMFANotEnabled = errcode.Register(errcode.Definition{
+	Code:       "MFANotEnabled",
+	HTTPStatus: http.StatusBadRequest,
+	Messages: map[string]string{
+		errcode.LangZH: "未启用二次验证",
+		errcode.LangEN: "Two-factor authentication is not enabled",
+	},
+})
+
+var ErrCodeSessionNotFound = errcode.Register(errcode.Definition{
+	Code:       "SessionNotFound",
+	HTTPStatus: http.StatusNotFound,
+	Messages: map[string]string{
+		errcode.LangZH: "登录会话不存在",
+		errcode.LangEN: "Session not found",
+	},
+})
@@ -0,0 +1,86 @@
+package protocol
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+
+	"ginfra/config"
+	"ginfra/errcode"
+	"ginfra/log"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+//ErrorConfig 错误响应配置
+type ErrorConfig struct {
+	Language   string   // 默认语言，请求未携带Accept-Language或均不支持时使用
+	Languages  []string // 支持的语言，按Accept-Language选择
+	HTTPStatus bool     // 错误响应使用错误码对应的HTTP状态码，默认均为200
+	Stack      bool     // 创建、包装CustomError时记录调用栈，错误响应时记录到日志
+}
+
+var errorCfg atomic.Value
+
+func init() {
+	cfg, err := config.Parse("")
+	if err != nil {
+		panic(err)
+	}
+
+	if err := loadErrorConfig(cfg); err != nil {
+		panic(err)
+	}
+	cfg.OnChange(func() {
+		if err := loadErrorConfig(cfg); err != nil {
+			log.WithContext(context.Background()).Error("reload errcode config fail",
+				zap.String("error", err.Error()))
+		}
+	})
+}
+
+func loadErrorConfig(cfg *config.Config) error {
+	ec := &ErrorConfig{
+		Language:  errcode.DefaultLanguage,
+		Languages: []string{errcode.LangZH, errcode.LangEN},
+	}
+	if err := cfg.UnmarshalKey("errcode", ec); err != nil {
+		return err
+	}
+	SetErrorConfig(ec)
+	return nil
+}
+
+//SetErrorConfig 替换错误响应配置，用于测试
+func SetErrorConfig(ec *ErrorConfig) {
+	errcode.SetCaptureStack(ec.Stack)
+	errorCfg.Store(ec)
+}
+
+// errorLanguage 按Accept-Language选择错误消息的语言
+func errorLanguage(c *gin.Context, ec *ErrorConfig) string {
+	if lang := matchLanguage(c.GetHeader("Accept-Language"), ec.Languages); len(lang) > 0 {
+		return lang
+	}
+	return ec.Language
+}
+
+// matchLanguage 返回Accept-Language中权重最高的支持语言，en-US可匹配en，都不支持时返回空
+func matchLanguage(header string, supported []string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		tag, q := splitQuality(part)
+		if len(tag) == 0 || q <= bestQ {
+			continue
+		}
+		for _, lang := range supported {
+			l := strings.ToLower(lang)
+			if tag == l || strings.HasPrefix(tag, l+"-") {
+				best, bestQ = lang, q
+				break
+			}
+		}
+	}
+	return best
+}
@@ -2,13 +2,17 @@ package protocol
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"time"
 
 	"ginfra/errcode"
+	"ginfra/log"
 	"ginfra/utils"
+	"ginfra/validation"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 )
 
 // 正常Response
@@ -33,7 +37,7 @@ type innerErrorResponse struct {
 	Timestamp int64
 }
 
-//SetResponse 设置gin的response, for response without data field
+//SetResponse 设置gin的response, for response without data field，按Accept选择编码
 func SetResponse(c *gin.Context, data interface{}) {
 	var innerResp map[string]interface{}
 	r := &innerResponse{
@@ -51,10 +55,10 @@ func SetResponse(c *gin.Context, data interface{}) {
 	resp["Response"] = innerResp
 
 	c.Set(CtxResponseCode, "OK")
-	c.JSON(http.StatusOK, resp)
+	render(c, http.StatusOK, resp)
 }
 
-//SetResponseData 设置gin的response
+//SetResponseData 设置gin的response，按Accept选择编码
 func SetResponseData(c *gin.Context, data interface{}) {
 	r := &innerResponse{
 		RequestId: GetRequestId(c),
@@ -63,27 +67,44 @@ func SetResponseData(c *gin.Context, data interface{}) {
 	}
 
 	c.Set(CtxResponseCode, "OK")
-	c.JSON(http.StatusOK, r)
+	render(c, http.StatusOK, r)
 }
 
-//SetErrResponse 设置gin的error response
+//InvalidParameter 请求参数绑定失败时返回的错误，参数校验失败的字段及规则在Error.Details中返回
+func InvalidParameter(err error) *errcode.CustomError {
+	return ErrCodeInvalidParameter.Wrap(err).WithDetails(validation.Details(err)...)
+}
+
+//SetErrResponse 设置gin的error response，消息按Accept-Language本地化
+// errcode.httpstatus开启时使用错误码注册的HTTP状态码，否则为200
+// 客户端只能看到错误链中第一个CustomError的消息，不含CustomError的错误返回InternalError；错误链及调用栈记录到日志
 func SetErrResponse(c *gin.Context, err error) {
-	cserr, ok := err.(*errcode.CustomError)
-	if !ok {
-		e, ok := err.(errcode.CustomError)
-		if !ok {
-			cserr = errcode.NewCustomError(errcode.ErrCodeInternalError, err.Error())
-		} else {
-			cserr = &e
-		}
+	var cserr *errcode.CustomError
+	if !errors.As(err, &cserr) {
+		cserr = errcode.Internal(err)
+		err = cserr
 	}
+	if stack := errcode.StackTrace(err); errors.Unwrap(err) != nil || len(stack) > 0 {
+		log.WithGinContext(c).Error(err.Error(), zap.String("error", cserr.Code), zap.String("stack", stack))
+	}
+	ec := errorCfg.Load().(*ErrorConfig)
+	lang := errorLanguage(c, ec)
 	r := &ErrorResponse{
 		Response: innerErrorResponse{
 			RequestId: GetRequestId(c),
 			Timestamp: time.Now().Unix(),
-			Error:     *cserr,
+			Error: errcode.CustomError{
+				Code:    cserr.Code,
+				Message: errcode.Localize(cserr, lang),
+				Details: errcode.LocalizeDetails(cserr.Details, lang),
+			},
 		},
 	}
+	status := http.StatusOK
+	if ec.HTTPStatus {
+		status = errcode.HTTPStatus(cserr)
+	}
 	c.Set(CtxResponseCode, cserr.Code)
-	c.JSON(http.StatusOK, r)
+	c.Header("Content-Language", lang)
+	render(c, status, r)
 }
@@ -0,0 +1,139 @@
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ginfra/errcode"
+	"ginfra/log"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smartystreets/goconvey/convey"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func Test_MatchLanguage(t *testing.T) {
+	supported := []string{errcode.LangZH, errcode.LangEN}
+	convey.Convey("accept-language", t, func() {
+		convey.So(matchLanguage("", supported), convey.ShouldBeEmpty)
+		convey.So(matchLanguage("fr-FR, de;q=0.8", supported), convey.ShouldBeEmpty)
+		convey.So(matchLanguage("en-US,en;q=0.9", supported), convey.ShouldEqual, errcode.LangEN)
+		convey.So(matchLanguage("en;q=0.5, zh-CN;q=0.8", supported), convey.ShouldEqual, errcode.LangZH)
+		convey.So(matchLanguage("fr, EN;q=0.3", supported), convey.ShouldEqual, errcode.LangEN)
+		convey.So(matchLanguage("zh;q=0, en", supported), convey.ShouldEqual, errcode.LangEN)
+	})
+}
+
+func Test_SetErrResponse(t *testing.T) {
+	saved := errorCfg.Load().(*ErrorConfig)
+	defer SetErrorConfig(saved)
+
+	gin.SetMode(gin.TestMode)
+	g := gin.New()
+	g.GET("/weak", func(c *gin.Context) {
+		SetErrResponse(c, ErrCodeWeakPassword.WithParams(map[string]interface{}{"min": 8, "max": 72}))
+	})
+	do := func(lang string) (*httptest.ResponseRecorder, errcode.CustomError) {
+		req := httptest.NewRequest(http.MethodGet, "/weak", nil)
+		req.Header.Set("Accept-Language", lang)
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, req)
+		var resp ErrorResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w, resp.Response.Error
+	}
+
+	convey.Convey("localized message", t, func() {
+		SetErrorConfig(&ErrorConfig{Language: errcode.LangZH, Languages: []string{errcode.LangZH, errcode.LangEN}})
+		w, e := do("en-US")
+		convey.So(w.Code, convey.ShouldEqual, http.StatusOK)
+		convey.So(w.Header().Get("Content-Language"), convey.ShouldEqual, errcode.LangEN)
+		convey.So(e.Code, convey.ShouldEqual, ErrCodeWeakPassword.Code)
+		convey.So(e.Message, convey.ShouldContainSubstring, "72")
+		convey.So(e.Message, convey.ShouldNotContainSubstring, "{")
+
+		w, e = do("fr")
+		convey.So(w.Header().Get("Content-Language"), convey.ShouldEqual, errcode.LangZH)
+		convey.So(e.Message, convey.ShouldEqual, "密码长度需为8到72位")
+	})
+
+	convey.Convey("http status", t, func() {
+		SetErrorConfig(&ErrorConfig{Language: errcode.LangEN, Languages: []string{errcode.LangEN}, HTTPStatus: true})
+		w, _ := do("")
+		convey.So(w.Code, convey.ShouldEqual, errcode.HTTPStatus(ErrCodeWeakPassword))
+		convey.So(w.Header().Get("Content-Language"), convey.ShouldEqual, errcode.LangEN)
+	})
+}
+
+func Test_SetErrResponseChain(t *testing.T) {
+	saved, oldLogger := errorCfg.Load().(*ErrorConfig), log.ZLog
+	defer func() { SetErrorConfig(saved); log.ZLog = oldLogger }()
+	core, logs := observer.New(zap.ErrorLevel)
+	log.ZLog = zap.New(core)
+	SetErrorConfig(&ErrorConfig{Language: errcode.LangZH, Languages: []string{errcode.LangZH}, Stack: true})
+
+	gin.SetMode(gin.TestMode)
+	do := func(err error) errcode.CustomError {
+		g := gin.New()
+		g.GET("/err", func(c *gin.Context) { SetErrResponse(c, err) })
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/err", nil))
+		var resp ErrorResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp.Response.Error
+	}
+
+	convey.Convey("public message only", t, func() {
+		e := do(fmt.Errorf("load user: %w", ErrCodeDBException.Wrap(errors.New("dial tcp: connection refused"))))
+		convey.So(e.Code, convey.ShouldEqual, ErrCodeDBException.Code)
+		convey.So(e.Message, convey.ShouldEqual, ErrCodeDBException.Message)
+		entry := logs.TakeAll()
+		convey.So(entry, convey.ShouldHaveLength, 1)
+		convey.So(entry[0].Message, convey.ShouldContainSubstring, "connection refused")
+		convey.So(entry[0].ContextMap()["stack"], convey.ShouldContainSubstring, "response_test.go")
+
+		e = do(errors.New("secret internal detail"))
+		convey.So(e.Code, convey.ShouldEqual, errcode.ErrCodeInternalError)
+		convey.So(e.Message, convey.ShouldNotContainSubstring, "secret")
+		convey.So(logs.TakeAll()[0].Message, convey.ShouldContainSubstring, "secret internal detail")
+	})
+}
+
+func Test_InvalidParameter(t *testing.T) {
+	saved, oldLogger := errorCfg.Load().(*ErrorConfig), log.ZLog
+	defer func() { SetErrorConfig(saved); log.ZLog = oldLogger }()
+	log.ZLog = zap.NewNop()
+	SetErrorConfig(&ErrorConfig{Language: errcode.LangZH, Languages: []string{errcode.LangZH, errcode.LangEN}})
+
+	gin.SetMode(gin.TestMode)
+	g := gin.New()
+	g.POST("/ticket", func(c *gin.Context) {
+		var req struct {
+			EnvID string `binding:"required,envid"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			SetErrResponse(c, InvalidParameter(err))
+		}
+	})
+
+	convey.Convey("field details", t, func() {
+		req := httptest.NewRequest(http.MethodPost, "/ticket", strings.NewReader(`{}`))
+		req.Header.Set("Accept-Language", "en")
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, req)
+		var resp ErrorResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		e := resp.Response.Error
+		convey.So(e.Code, convey.ShouldEqual, errcode.ErrInvalidParam)
+		convey.So(e.Details, convey.ShouldHaveLength, 1)
+		convey.So(e.Details[0].Field, convey.ShouldEqual, "EnvID")
+		convey.So(e.Details[0].Rule, convey.ShouldEqual, "required")
+		convey.So(e.Details[0].Message, convey.ShouldEqual, "EnvID is required")
+	})
+}
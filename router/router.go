@@ -60,7 +60,12 @@ func load(g *gin.Engine) {
 		svcd.GET("/ram", sd.RAMCheck)
 	}
 
+	// JWT校验公钥
+	g.GET("/.well-known/jwks.json", handler.JWKS)
+	g.GET("/.well-known/openid-configuration", handler.OpenIDConfiguration)
+
 	gapi := g.Group("/api/v1")
+	gapi.Use(mw.Tenant(), mw.Maintenance())
 	{
 		gapi.GET("/wx", handler.WXCheckSignature)
 		gapi.POST("/wx", handler.WXMsgReceive)
@@ -69,9 +74,90 @@ func load(g *gin.Engine) {
 	}
 
 	gauth := g.Group("/api/v2")
-	gauth.Use(mw.JWTAuth(handler.HandleClaims))
+	gauth.Use(mw.Authenticate(handler.HandleClaims, handler.HandleApiKey), mw.Tenant(), mw.Maintenance(), mw.Captcha())
+	{
+		gauth.POST("/Upload", mw.RequireScope("upload"), handler.Upload)
+		gauth.POST("/GetDiscuzToken", mw.RequireFeature("discuz_token"), handler.GetDiscuzToken)
+		gauth.POST("/RevokeToken", handler.RevokeToken)
+		gauth.POST("/RevokeAllTokens", handler.RevokeAllTokens)
+		gauth.POST("/DescribeSessions", handler.DescribeSessions)
+		gauth.POST("/RevokeSession", handler.RevokeSession)
+		gauth.POST("/ChangePassword", mw.RequireSmsCode(handler.LinkedPhone), handler.ChangePassword)
+		gauth.POST("/DescribeIdentities", handler.DescribeIdentities)
+		gauth.POST("/LinkIdentity", mw.RequireSmsCode(handler.LinkedPhone), handler.LinkIdentity)
+		gauth.POST("/LinkPhone", handler.LinkPhone)
+		gauth.POST("/SendVerifySmsCode", handler.SendVerifySmsCode)
+		gauth.POST("/SendVerifyEmail", handler.SendVerifyEmail)
+		gauth.POST("/UnlinkIdentity", mw.RequireSmsCode(handler.LinkedPhone), handler.UnlinkIdentity)
+		gauth.POST("/DescribeMFA", handler.DescribeMFA)
+		gauth.POST("/EnrollTOTP", handler.EnrollTOTP)
+		gauth.POST("/ConfirmTOTP", handler.ConfirmTOTP)
+		gauth.POST("/VerifyMFA", handler.VerifyMFA)
+		gauth.POST("/RegenerateRecoveryCodes", mw.RequireMFA(mw.MFALevelTOTP), handler.RegenerateRecoveryCodes)
+		gauth.POST("/DisableTOTP", mw.RequireMFA(mw.MFALevelTOTP), handler.DisableTOTP)
+	}
+
+	// access token过期后仍可调用，不经过鉴权中间件
+	gtoken := g.Group("/api/v2/token")
+	gtoken.Use(mw.Maintenance())
+	{
+		gtoken.POST("/refresh", handler.RefreshToken)
+		gtoken.POST("/logout", handler.Logout)
+	}
+
+	// 站内账号注册、登录及找回密码
+	gaccount := g.Group("/api/v2/account")
+	gaccount.Use(mw.Maintenance(), mw.Captcha())
+	{
+		gaccount.POST("/Register", handler.Register)
+		gaccount.POST("/Login", handler.Login)
+		gaccount.POST("/ResetPassword", handler.ResetPassword)
+		gaccount.POST("/ConfirmResetPassword", handler.ConfirmResetPassword)
+		gaccount.POST("/SendSmsCode", handler.SendSmsCode)
+		gaccount.POST("/SmsLogin", handler.SmsLogin)
+		gaccount.POST("/ConfirmEmail", handler.ConfirmEmail)
+	}
+
+	// 第三方登录，provider为idp配置中的平台名称
+	goauth := g.Group("/auth")
+	goauth.Use(mw.Maintenance(), mw.Captcha())
+	{
+		goauth.GET("/:provider/authorize", handler.AuthorizeProvider)
+		goauth.GET("/:provider/callback", mw.Writes(), handler.ProviderCallback)
+		goauth.POST("/:provider/callback", mw.Writes(), handler.ProviderCallback)
+	}
+
+	// OAuth2/OIDC授权服务，授权端点使用站内登录态
+	goauth2 := g.Group("/oauth")
+	goauth2.Use(mw.Maintenance())
+	{
+		goauth2.GET("/authorize", mw.Writes(), mw.OAuthLoginRedirect(), mw.Authenticate(handler.HandleClaims, handler.HandleApiKey),
+			handler.OAuthAuthorize)
+		goauth2.POST("/token", handler.OAuthToken)
+		goauth2.POST("/introspect", handler.OAuthIntrospect)
+		goauth2.GET("/userinfo", handler.OAuthUserInfo)
+		goauth2.POST("/userinfo", handler.OAuthUserInfo)
+	}
+
+	gadmin := gauth.Group("/admin")
+	gadmin.Use(mw.RequireAdmin(), mw.RequireMFA(mw.MFALevelTOTP))
 	{
-		gauth.POST("/Upload", handler.Upload)
+		gadmin.POST("/CreateApiKey", mw.Require("apikey:write"), handler.CreateApiKey)
+		gadmin.POST("/DescribeApiKeys", mw.Require("apikey:read"), handler.DescribeApiKeys)
+		gadmin.POST("/DisableApiKey", mw.Require("apikey:write"), handler.DisableApiKey)
+		gadmin.POST("/RotateApiKey", mw.Require("apikey:write"), handler.RotateApiKey)
+		gadmin.POST("/RevokeUserTokens", mw.Require("token:revoke"), handler.RevokeUserTokens)
+		gadmin.POST("/RevokeTokenById", mw.Require("token:revoke"), handler.RevokeTokenById)
+		gadmin.POST("/CreateRole", mw.Require("role:write"), handler.CreateRole)
+		gadmin.POST("/UpdateRole", mw.Require("role:write"), handler.UpdateRole)
+		gadmin.POST("/DescribeRoles", mw.Require("role:read"), handler.DescribeRoles)
+		gadmin.POST("/AssignRole", mw.Require("role:write"), handler.AssignRole)
+		gadmin.POST("/UnassignRole", mw.Require("role:write"), handler.UnassignRole)
+		gadmin.POST("/DescribeUserRoles", mw.Require("role:read"), handler.DescribeUserRoles)
+		gadmin.POST("/MergeAccounts", mw.Require("account:merge"), handler.MergeAccounts)
+		gadmin.POST("/CreateOAuthClient", mw.Require("oauthclient:write"), handler.CreateOAuthClient)
+		gadmin.POST("/DescribeOAuthClients", mw.Require("oauthclient:read"), handler.DescribeOAuthClients)
+		gadmin.POST("/DisableOAuthClient", mw.Require("oauthclient:write"), handler.DisableOAuthClient)
 	}
 
 	// User handlers
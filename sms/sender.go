@@ -0,0 +1,47 @@
+package sms
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"ginfra/log"
+	"ginfra/tencent"
+
+	"go.uber.org/zap"
+)
+
+// tencentSender 腾讯云短信
+type tencentSender struct {
+	signName  string
+	templates map[string]string
+}
+
+//NewTencentSender 使用腾讯云短信发送验证码，模板参数为验证码及有效分钟数
+func NewTencentSender(cfg Config) Sender {
+	return &tencentSender{signName: cfg.SignName, templates: cfg.Templates}
+}
+
+func (s *tencentSender) Send(ctx context.Context, phone, purpose, code string, expires time.Duration) error {
+	tplID, ok := s.templates[purpose]
+	if !ok || len(tplID) == 0 {
+		return fmt.Errorf("sms template not configured: %s", purpose)
+	}
+	minutes := strconv.Itoa(int(expires / time.Minute))
+	return tencent.SendSms(ctx, phone, s.signName, tplID, []string{code, minutes})
+}
+
+// logSender 开发测试使用，验证码仅记录到日志
+type logSender struct{}
+
+//NewLogSender 验证码仅记录到日志，不实际发送，用于开发测试
+func NewLogSender(cfg Config) Sender {
+	return logSender{}
+}
+
+func (logSender) Send(ctx context.Context, phone, purpose, code string, expires time.Duration) error {
+	log.WithContext(ctx).Info("sms code", zap.String("phone", phone), zap.String("purpose", purpose),
+		zap.String("code", code), zap.Duration("expires", expires))
+	return nil
+}
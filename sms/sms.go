@@ -0,0 +1,220 @@
+package sms
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"ginfra/config"
+	"ginfra/datasource"
+	"ginfra/models"
+	"ginfra/utils"
+
+	"gorm.io/gorm"
+)
+
+// 验证码用途，不同用途的验证码不能混用
+const (
+	PurposeLogin  = "login"  // 短信验证码登录
+	PurposeBind   = "bind"   // 绑定手机号
+	PurposeVerify = "verify" // 敏感操作二次验证
+)
+
+var (
+	//ErrTooFrequent 发送过于频繁
+	ErrTooFrequent = errors.New("sms code sent too frequently")
+	//ErrInvalidPurpose 验证码用途无效
+	ErrInvalidPurpose = errors.New("invalid sms code purpose")
+)
+
+//Sender 短信发送渠道
+type Sender interface {
+	Send(ctx context.Context, phone, purpose, code string, expires time.Duration) error
+}
+
+//Config 短信验证码配置
+type Config struct {
+	Sink         string            // 发送渠道，tencent使用腾讯云短信，log仅记录日志用于开发测试
+	SignName     string            // 短信签名
+	Templates    map[string]string // 各用途的短信模板ID，模板参数为验证码及有效分钟数
+	CodeLength   int               // 验证码位数
+	CodeExpires  time.Duration     // 验证码有效期
+	MaxAttempts  int               // 验证码最多校验失败次数，超过后需重新发送
+	Interval     time.Duration     // 同一手机号及用途的最短发送间隔
+	Window       time.Duration     // 限频统计窗口
+	PhoneLimit   int64             // 窗口内每个手机号最多发送次数
+	IPLimit      int64             // 窗口内每个IP最多发送次数
+	CaptchaAfter int64             // 窗口内手机号或IP发送达到该次数后需先完成验证码，0表示不需要
+}
+
+var (
+	cfg = Config{
+		Sink:         "tencent",
+		CodeLength:   6,
+		CodeExpires:  5 * time.Minute,
+		MaxAttempts:  5,
+		Interval:     time.Minute,
+		Window:       time.Hour,
+		PhoneLimit:   5,
+		IPLimit:      20,
+		CaptchaAfter: 2,
+	}
+	sender Sender
+)
+
+// 发送渠道名及对应的创建函数
+var sinks = map[string]func(Config) Sender{
+	"tencent": NewTencentSender,
+	"log":     NewLogSender,
+}
+
+//Init 加载sms配置并创建发送渠道
+func Init(c *config.Config) error {
+	if err := c.UnmarshalKey("sms", &cfg); err != nil {
+		return err
+	}
+	factory, ok := sinks[cfg.Sink]
+	if !ok {
+		return fmt.Errorf("unknown sms sink: %s", cfg.Sink)
+	}
+	sender = factory(cfg)
+	return nil
+}
+
+//SetSender 替换发送渠道，用于测试或接入其他短信服务商
+func SetSender(s Sender) {
+	sender = s
+}
+
+//ValidPurpose 验证码用途是否有效
+func ValidPurpose(purpose string) bool {
+	switch purpose {
+	case PurposeLogin, PurposeBind, PurposeVerify:
+		return true
+	}
+	return false
+}
+
+// generateCode 使用crypto/rand生成数字验证码
+func generateCode(length int) (string, error) {
+	buf := make([]byte, length)
+	max := big.NewInt(10)
+	for i := range buf {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		buf[i] = byte('0' + n.Int64())
+	}
+	return string(buf), nil
+}
+
+// hashCode 验证码哈希，绑定手机号及用途
+func hashCode(phone, purpose, code string) string {
+	return utils.SHA256Hex(phone + ":" + purpose + ":" + code)
+}
+
+//CaptchaRequired 窗口内手机号或IP的发送次数达到CaptchaAfter时需先完成验证码
+func CaptchaRequired(ctx context.Context, phone, clientIP string) (bool, error) {
+	if cfg.CaptchaAfter <= 0 {
+		return false, nil
+	}
+	db, err := datasource.Gormv2(ctx)
+	if err != nil {
+		return false, err
+	}
+	since := time.Now().Add(-cfg.Window)
+	count, err := models.CountSmsCodesByPhone(db, phone, since)
+	if err != nil {
+		return false, err
+	}
+	if count >= cfg.CaptchaAfter {
+		return true, nil
+	}
+	count, err = models.CountSmsCodesByIP(db, clientIP, since)
+	if err != nil {
+		return false, err
+	}
+	return count >= cfg.CaptchaAfter, nil
+}
+
+//Send 生成并发送验证码，超过发送间隔或窗口内发送次数限制时返回ErrTooFrequent
+func Send(ctx context.Context, phone, purpose, clientIP string) error {
+	if !ValidPurpose(purpose) {
+		return ErrInvalidPurpose
+	}
+	if sender == nil {
+		return errors.New("sms sender not initialized")
+	}
+	db, err := datasource.Gormv2(ctx)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	latest, err := models.GetLatestSmsCode(db, phone, purpose)
+	if err == nil && now.Sub(latest.CreatedAt) < cfg.Interval {
+		return ErrTooFrequent
+	}
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return err
+	}
+	since := now.Add(-cfg.Window)
+	count, err := models.CountSmsCodesByPhone(db, phone, since)
+	if err != nil {
+		return err
+	}
+	if count >= cfg.PhoneLimit {
+		return ErrTooFrequent
+	}
+	count, err = models.CountSmsCodesByIP(db, clientIP, since)
+	if err != nil {
+		return err
+	}
+	if count >= cfg.IPLimit {
+		return ErrTooFrequent
+	}
+
+	code, err := generateCode(cfg.CodeLength)
+	if err != nil {
+		return err
+	}
+	record := &models.SmsCode{
+		Phone:     phone,
+		Purpose:   purpose,
+		CodeHash:  hashCode(phone, purpose, code),
+		ClientIP:  clientIP,
+		ExpiresAt: now.Add(cfg.CodeExpires),
+	}
+	// 先保存再发送，发送失败也计入限频
+	if err := record.Insert(db); err != nil {
+		return err
+	}
+	return sender.Send(ctx, phone, purpose, code, cfg.CodeExpires)
+}
+
+//Verify 校验验证码，仅最新发送的验证码有效，校验成功后验证码失效；失败次数超过MaxAttempts后需重新发送
+func Verify(ctx context.Context, phone, purpose, code string) (bool, error) {
+	db, err := datasource.Gormv2(ctx)
+	if err != nil {
+		return false, err
+	}
+	record, err := models.GetLatestSmsCode(db, phone, purpose)
+	if err == gorm.ErrRecordNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if record.UsedAt != nil || record.Attempts >= cfg.MaxAttempts || time.Now().After(record.ExpiresAt) {
+		return false, nil
+	}
+	if subtle.ConstantTimeCompare([]byte(record.CodeHash), []byte(hashCode(phone, purpose, code))) != 1 {
+		_, err := record.RecordFailure(db, cfg.MaxAttempts)
+		return false, err
+	}
+	return record.Consume(db, cfg.MaxAttempts)
+}
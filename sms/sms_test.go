@@ -0,0 +1,46 @@
+package sms
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func Test_GenerateCode(t *testing.T) {
+	convey.Convey("digits only", t, func() {
+		for i := 0; i < 100; i++ {
+			code, err := generateCode(6)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(code, convey.ShouldHaveLength, 6)
+			for _, r := range code {
+				convey.So(r >= '0' && r <= '9', convey.ShouldBeTrue)
+			}
+		}
+	})
+}
+
+func Test_HashCode(t *testing.T) {
+	convey.Convey("bound to phone and purpose", t, func() {
+		h := hashCode("13800000000", PurposeLogin, "123456")
+		convey.So(h, convey.ShouldHaveLength, 64)
+		convey.So(h, convey.ShouldNotContainSubstring, "123456")
+		convey.So(hashCode("13800000000", PurposeLogin, "123456"), convey.ShouldEqual, h)
+		convey.So(hashCode("13800000001", PurposeLogin, "123456"), convey.ShouldNotEqual, h)
+		convey.So(hashCode("13800000000", PurposeBind, "123456"), convey.ShouldNotEqual, h)
+	})
+}
+
+func Test_Senders(t *testing.T) {
+	ctx := context.Background()
+	convey.Convey("tencent sender requires template", t, func() {
+		s := NewTencentSender(Config{Templates: map[string]string{PurposeLogin: "1"}})
+		convey.So(s.Send(ctx, "13800000000", PurposeBind, "123456", time.Minute), convey.ShouldNotBeNil)
+	})
+	convey.Convey("purpose", t, func() {
+		convey.So(ValidPurpose(PurposeVerify), convey.ShouldBeTrue)
+		convey.So(ValidPurpose("reset"), convey.ShouldBeFalse)
+		convey.So(Send(ctx, "13800000000", "reset", "127.0.0.1"), convey.ShouldEqual, ErrInvalidPurpose)
+	})
+}
@@ -1,9 +1,11 @@
 package tencent
 
 import (
+	"context"
 	"fmt"
 
 	"ginfra/config"
+	"ginfra/tracing"
 
 	captcha "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/captcha/v20190722"
 	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
@@ -34,7 +36,8 @@ func init() {
 	captchaAppKey = cfg.GetString("captcha.AppKey")
 }
 
-func DescribeCaptchaResult(ticket, randstr, clientIp string) error {
+//DescribeCaptchaResult 腾讯云验证码票据校验
+func DescribeCaptchaResult(ctx context.Context, ticket, randstr, clientIp string) error {
 
 	credential := common.NewCredential(captchaSecretId, captchaSecretKey)
 	cpf := profile.NewClientProfile()
@@ -52,7 +55,9 @@ func DescribeCaptchaResult(ticket, randstr, clientIp string) error {
 	request.CaptchaAppId = common.Uint64Ptr(captchaAppId)
 	request.AppSecretKey = common.StringPtr(captchaAppKey)
 
+	_, span := tracing.StartClient(ctx, "tencentcloud.captcha.DescribeCaptchaResult")
 	response, err := client.DescribeCaptchaResult(request)
+	tracing.End(span, err)
 	if _, ok := err.(*errors.TencentCloudSDKError); ok {
 		return err
 	}
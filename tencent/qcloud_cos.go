@@ -9,6 +9,7 @@ import (
 	"sync"
 
 	"ginfra/config"
+	"ginfra/tracing"
 	"ginfra/utils"
 	"github.com/tencentyun/cos-go-sdk-v5"
 )
@@ -45,6 +46,7 @@ func CosClient() *cos.Client {
 			Transport: &cos.AuthorizationTransport{
 				SecretID:  CosSecretID,
 				SecretKey: CosSecretKey,
+				Transport: tracing.NewTransport(nil),
 			},
 		})
 	})
@@ -53,13 +55,13 @@ func CosClient() *cos.Client {
 }
 
 //PutFileToCos 上传文件
-func PutFileToCos(c *cos.Client, objectKey string, localfile string) error {
+func PutFileToCos(ctx context.Context, c *cos.Client, objectKey string, localfile string) error {
 	var err error
 	// 对象键（Key）是对象在存储桶中的唯一标识。
 	// 例如，在对象的访问域名 `examplebucket-1250000000.cos.COS_REGION.myqcloud.com/test/objectPut.go` 中，对象键为 test/objectPut.go
 
 	// 通过本地文件上传对象
-	_, err = c.Object.PutFromFile(context.Background(), objectKey, localfile, nil)
+	_, err = c.Object.PutFromFile(ctx, objectKey, localfile, nil)
 	if err != nil {
 		return err
 	}
@@ -67,12 +69,12 @@ func PutFileToCos(c *cos.Client, objectKey string, localfile string) error {
 }
 
 //GetFileFromCos 下载文件
-func GetFileFromCos(cosurl string) (string, error) {
+func GetFileFromCos(ctx context.Context, cosurl string) (string, error) {
 	var name string = cosurl
 	if len(CosBucketUrl) > 0 && strings.HasPrefix(cosurl, CosBucketUrl) {
 		name = cosurl[len(CosBucketUrl):]
 	}
-	response, err := CosClient().Object.Get(context.Background(), name, nil)
+	response, err := CosClient().Object.Get(ctx, name, nil)
 	if err != nil {
 		return "", err
 	}
@@ -85,11 +87,11 @@ func GetFileFromCos(cosurl string) (string, error) {
 }
 
 //ReadFile 读取文件内容，支持COS、HTTP、HTTPS、本地文件
-func ReadFile(logfile string) (string, error) {
+func ReadFile(ctx context.Context, logfile string) (string, error) {
 	// try cos
 	if len(CosBucketUrl) > 0 && strings.HasPrefix(logfile, CosBucketUrl) {
 		if len(CosSecretKey) > 0 {
-			body, err := GetFileFromCos(logfile)
+			body, err := GetFileFromCos(ctx, logfile)
 			if err != nil {
 				return "", err
 			}
@@ -1,11 +1,13 @@
 package tencent
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
 	"io/ioutil"
 
 	"ginfra/config"
+	"ginfra/tracing"
 
 	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
 	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/errors"
@@ -47,7 +49,7 @@ func ReadImage(filename string) string {
 }
 
 //FaceFusion 腾讯云人脸融合接口
-func FaceFusion(projId, moduleId, image string) (string, error) {
+func FaceFusion(ctx context.Context, projId, moduleId, image string) (string, error) {
 
 	credential := common.NewCredential(
 		faceSecretId,
@@ -64,7 +66,9 @@ func FaceFusion(projId, moduleId, image string) (string, error) {
 	request.Image = common.StringPtr(image)
 	request.RspImgType = common.StringPtr("base64")
 
+	_, span := tracing.StartClient(ctx, "tencentcloud.facefusion.FaceFusion")
 	response, err := client.FaceFusion(request)
+	tracing.End(span, err)
 	if _, ok := err.(*errors.TencentCloudSDKError); ok {
 		return "", err
 	}
@@ -1,7 +1,9 @@
 package tencent
 
 import (
+	"context"
 	"ginfra/config"
+	"ginfra/tracing"
 	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
 	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/errors"
 	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/profile"
@@ -26,7 +28,7 @@ func init() {
 }
 
 //PutMonitorData 上报数据到云监控
-func PutMonitorData(metrics []*monitor.MetricDatum) error {
+func PutMonitorData(ctx context.Context, metrics []*monitor.MetricDatum) error {
 	credential := common.NewCredential(monitorSecretId, monitorSecretKey)
 	cpf := profile.NewClientProfile()
 	cpf.HttpProfile.Endpoint = "monitor.tencentcloudapi.com"
@@ -36,7 +38,9 @@ func PutMonitorData(metrics []*monitor.MetricDatum) error {
 
 	request.Metrics = metrics
 
+	_, span := tracing.StartClient(ctx, "tencentcloud.monitor.PutMonitorData")
 	_, err := client.PutMonitorData(request)
+	tracing.End(span, err)
 	if _, ok := err.(*errors.TencentCloudSDKError); ok {
 		//fmt.Printf("An API error has returned: %s", err)
 		return err
@@ -1,9 +1,11 @@
 package tencent
 
 import (
+	"context"
 	"fmt"
 
 	"ginfra/config"
+	"ginfra/tracing"
 
 	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
 	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/errors"
@@ -33,11 +35,11 @@ func init() {
 }
 
 //SendSms 腾讯云发送短信接口
-func SendSms(phone string, signName string, tplId string, params []string) error {
+func SendSms(ctx context.Context, phone string, signName string, tplId string, params []string) error {
 
 	credential := common.NewCredential(
-		faceSecretId,
-		faceSecretKey,
+		smsSecretId,
+		smsSecretKey,
 	)
 	cpf := profile.NewClientProfile()
 	cpf.HttpProfile.Endpoint = "sms.tencentcloudapi.com"
@@ -62,7 +64,9 @@ func SendSms(phone string, signName string, tplId string, params []string) error
 	 * 示例如：+8613711112222， 其中前面有一个+号 ，86为国家码，13711112222为手机号，最多不要超过200个手机号*/
 	request.PhoneNumberSet = common.StringPtrs([]string{phone})
 	// 通过client对象调用想要访问的接口，需要传入请求对象
+	_, span := tracing.StartClient(ctx, "tencentcloud.sms.SendSms")
 	response, err := client.SendSms(request)
+	tracing.End(span, err)
 	if _, ok := err.(*errors.TencentCloudSDKError); ok {
 		return err
 	}
@@ -1,7 +1,9 @@
 package tencent
 
 import (
+	"context"
 	"ginfra/config"
+	"ginfra/tracing"
 
 	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
 	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/errors"
@@ -40,7 +42,7 @@ func init() {
 	stsSecretKey = cfg.GetString("qcloud.SecretKey")
 }
 
-func GetFederationToken(name, policy string) (*StsCredential, error) {
+func GetFederationToken(ctx context.Context, name, policy string) (*StsCredential, error) {
 
 	credential := common.NewCredential(stsSecretId, stsSecretKey)
 	cpf := profile.NewClientProfile()
@@ -52,7 +54,9 @@ func GetFederationToken(name, policy string) (*StsCredential, error) {
 	request.Policy = common.StringPtr(policy)
 	request.DurationSeconds = common.Uint64Ptr(1800)
 
+	_, span := tracing.StartClient(ctx, "tencentcloud.sts.GetFederationToken")
 	response, err := client.GetFederationToken(request)
+	tracing.End(span, err)
 	if _, ok := err.(*errors.TencentCloudSDKError); ok {
 		return nil, err
 	}
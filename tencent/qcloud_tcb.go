@@ -1,6 +1,7 @@
 package tencent
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
@@ -13,6 +14,7 @@ import (
 	"time"
 
 	"ginfra/config"
+	"ginfra/utils"
 )
 
 func sha256hex(s string) string {
@@ -96,15 +98,14 @@ func signature(secretId string, secretKey string, timestamp int64) string {
 	return authorization
 }
 
-func tcbget(url, authorization string, timestamp int64) ([]byte, error) {
-	req, err := http.NewRequest("GET", url, nil)
+func tcbget(ctx context.Context, url, authorization string, timestamp int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return []byte{}, err
 	}
 	req.Header.Set("X-CloudBase-TimeStamp", strconv.FormatInt(timestamp, 10))
 	req.Header.Set("X-CloudBase-Authorization", authorization)
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := utils.HttpClient.Do(req)
 	if err != nil {
 		return []byte{}, err
 	}
@@ -115,16 +116,15 @@ func tcbget(url, authorization string, timestamp int64) ([]byte, error) {
 	return respByte, nil
 }
 
-func tcbpost(url, authorization string, timestamp int64, body []byte) ([]byte, error) {
-	req, err := http.NewRequest("POST", url, strings.NewReader(string(body)))
+func tcbpost(ctx context.Context, url, authorization string, timestamp int64, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, strings.NewReader(string(body)))
 	if err != nil {
 		return []byte{}, err
 	}
 	req.Header.Set("X-CloudBase-TimeStamp", strconv.FormatInt(timestamp, 10))
 	req.Header.Set("X-CloudBase-Authorization", authorization)
 	req.Header.Set("content-type", "application/json")
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := utils.HttpClient.Do(req)
 	if err != nil {
 		return []byte{}, err
 	}
@@ -158,13 +158,13 @@ type tcbInsertDocData struct {
 }
 
 //QueryDocument 查询腾讯云云开发数据库
-func QueryDocument(envId, collection, docId string) ([]string, error) {
+func QueryDocument(ctx context.Context, envId, collection, docId string) ([]string, error) {
 	var timestamp int64 = time.Now().Unix()
 	var authorization string = signature(secretId, secretKey, timestamp)
 
 	url := fmt.Sprintf("https://%s/api/v2/envs/%s/databases/%s/documents/%s",
 		host, envId, collection, docId)
-	resp, err := tcbget(url, authorization, timestamp)
+	resp, err := tcbget(ctx, url, authorization, timestamp)
 	if err != nil {
 		return []string{}, err
 	}
@@ -178,7 +178,7 @@ func QueryDocument(envId, collection, docId string) ([]string, error) {
 }
 
 //InsertDocuments 往腾讯云云开发数据库插入数据
-func InsertDocuments(envId, collection string, docs [][]byte) ([]string, error) {
+func InsertDocuments(ctx context.Context, envId, collection string, docs [][]byte) ([]string, error) {
 	var timestamp int64 = time.Now().Unix()
 	var authorization string = signature(secretId, secretKey, timestamp)
 
@@ -192,7 +192,7 @@ func InsertDocuments(envId, collection string, docs [][]byte) ([]string, error)
 
 	url := fmt.Sprintf("https://%s/api/v2/envs/%s/databases/%s/documents",
 		host, envId, collection)
-	resp, err := tcbpost(url, authorization, timestamp, b)
+	resp, err := tcbpost(ctx, url, authorization, timestamp, b)
 	if err != nil {
 		return []string{}, err
 	}
@@ -1,11 +1,13 @@
 package tencent
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"net/http"
+
+	"ginfra/utils"
 )
 
 // QQ 互联 ------
@@ -19,11 +21,11 @@ type QQConnectTokenResponse struct {
 }
 
 //QQConnectToken QQ互联平台登录Code验证
-func QQConnectToken(appid, secret, redirect_uri, jscode string) (*QQConnectTokenResponse, error) {
+func QQConnectToken(ctx context.Context, appid, secret, redirect_uri, jscode string) (*QQConnectTokenResponse, error) {
 	host := "https://graph.qq.com/oauth2.0/token?" +
 		"client_id=%s&client_secret=%s&redirect_uri=%s&code=%s&" +
 		"grant_type=authorization_code&fmt=json"
-	resp, err := http.Get(fmt.Sprintf(host, appid, secret, redirect_uri, jscode))
+	resp, err := utils.HttpGet(ctx, fmt.Sprintf(host, appid, secret, redirect_uri, jscode))
 	if err != nil {
 		return nil, err
 	}
@@ -52,10 +54,10 @@ type QQConnectOpenIDResponse struct {
 }
 
 //QQConnectOpenID QQ互联平台获取OpenID
-func QQConnectOpenID(access_token string) (*QQConnectOpenIDResponse, error) {
+func QQConnectOpenID(ctx context.Context, access_token string) (*QQConnectOpenIDResponse, error) {
 	host := "https://graph.qq.com/oauth2.0/me?" +
 		"access_token=%s&unionid=1&fmt=json"
-	resp, err := http.Get(fmt.Sprintf(host, access_token))
+	resp, err := utils.HttpGet(ctx, fmt.Sprintf(host, access_token))
 	if err != nil {
 		return nil, err
 	}
@@ -87,10 +89,10 @@ type QQConnectUserInfoResponse struct {
 }
 
 //QQConnectUserInfo QQ互联平台获取用户信息
-func QQConnectUserInfo(appid, access_token, openid string) (*QQConnectUserInfoResponse, error) {
+func QQConnectUserInfo(ctx context.Context, appid, access_token, openid string) (*QQConnectUserInfoResponse, error) {
 	host := "https://graph.qq.com/user/get_user_info?" +
 		"access_token=%s&oauth_consumer_key=%s&openid=%s"
-	resp, err := http.Get(fmt.Sprintf(host, access_token, appid, openid))
+	resp, err := utils.HttpGet(ctx, fmt.Sprintf(host, access_token, appid, openid))
 	if err != nil {
 		return nil, err
 	}
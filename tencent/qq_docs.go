@@ -1,6 +1,7 @@
 package tencent
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -20,11 +21,11 @@ type QQDocsTokenResponse struct {
 }
 
 //QQDocsToken 腾讯文档开放平台授权验证
-func QQDocsToken(appid, secret, redirect_uri, code string) (*QQDocsTokenResponse, error) {
+func QQDocsToken(ctx context.Context, appid, secret, redirect_uri, code string) (*QQDocsTokenResponse, error) {
 	host := "https://docs.qq.com/oauth/v2/token?" +
 		"client_id=%s&client_secret=%s&redirect_uri=%s&code=%s&" +
 		"grant_type=authorization_code"
-	body, err := utils.GetRequest(fmt.Sprintf(host, appid, secret, redirect_uri, code), nil)
+	body, err := utils.GetRequest(ctx, fmt.Sprintf(host, appid, secret, redirect_uri, code), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -48,7 +49,7 @@ type QQDocsTempUrlResponse struct {
 }
 
 //QueryQQDocsTempUrl 获取腾讯文档临时URL
-func QueryQQDocsTempUrl(appid, token, openid, fileid string) (*QQDocsTempUrlResponse, error) {
+func QueryQQDocsTempUrl(ctx context.Context, appid, token, openid, fileid string) (*QQDocsTempUrlResponse, error) {
 	host := "https://docs.qq.com/openapi/drive/v2/util/temp-url?" +
 		"fileID=%s&type=open"
 	headers := map[string]string{
@@ -57,7 +58,7 @@ func QueryQQDocsTempUrl(appid, token, openid, fileid string) (*QQDocsTempUrlResp
 		"Open-Id":      openid,
 		"Content-Type": "application/x-www-form-urlencoded",
 	}
-	body, err := utils.PostRequest(fmt.Sprintf(host, url.QueryEscape(fileid)), headers, nil)
+	body, err := utils.PostRequest(ctx, fmt.Sprintf(host, url.QueryEscape(fileid)), headers, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -86,7 +87,7 @@ type QQDocsConverterResponse struct {
 }
 
 //QQDocsConverter 腾讯文档ID转换
-func QQDocsConverter(appid, token, openid, srcID string, convType int) (*QQDocsConverterResponse, error) {
+func QQDocsConverter(ctx context.Context, appid, token, openid, srcID string, convType int) (*QQDocsConverterResponse, error) {
 	host := "https://docs.qq.com/openapi/drive/v2/util/converter?" +
 		"value=%s&type=%d"
 	headers := map[string]string{
@@ -95,7 +96,7 @@ func QQDocsConverter(appid, token, openid, srcID string, convType int) (*QQDocsC
 		"Open-Id":      openid,
 		"Content-Type": "application/x-www-form-urlencoded",
 	}
-	body, err := utils.GetRequest(fmt.Sprintf(host, url.QueryEscape(srcID), convType), headers)
+	body, err := utils.GetRequest(ctx, fmt.Sprintf(host, url.QueryEscape(srcID), convType), headers)
 	if err != nil {
 		return nil, err
 	}
@@ -1,16 +1,17 @@
 package tencent
 
 import (
+	"context"
 	"crypto/sha1"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"net/http"
 	"sort"
 	"strings"
 
 	"ginfra/errcode"
+	"ginfra/utils"
 )
 
 // 微信开放平台 ------
@@ -24,9 +25,9 @@ type WxCode2SessionResponse struct {
 }
 
 //WxCode2Session 微信开放平台登录Code验证
-func WxCode2Session(appid, secret, jscode string) (*WxCode2SessionResponse, error) {
+func WxCode2Session(ctx context.Context, appid, secret, jscode string) (*WxCode2SessionResponse, error) {
 	host := "https://api.weixin.qq.com/sns/jscode2session?appid=%s&secret=%s&js_code=%s&grant_type=authorization_code"
-	resp, err := http.Get(fmt.Sprintf(host, appid, secret, jscode))
+	resp, err := utils.HttpGet(ctx, fmt.Sprintf(host, appid, secret, jscode))
 	if err != nil {
 		return nil, err
 	}
@@ -52,12 +53,12 @@ type WxOpenAccessTokenResponse struct {
 }
 
 //GetWxOpenAccessToken 获取微信开放平台AccessToken
-func GetWxOpenAccessToken(appid, secret string, code string) (*WxOpenAccessTokenResponse, error) {
+func GetWxOpenAccessToken(ctx context.Context, appid, secret string, code string) (*WxOpenAccessTokenResponse, error) {
 	wxloginurl := "https://api.weixin.qq.com/sns/oauth2/access_token?" +
 		fmt.Sprintf("appid=%s&secret=%s&code=%s&grant_type=authorization_code",
 			appid, secret, code)
 
-	resp, err := http.Get(wxloginurl)
+	resp, err := utils.HttpGet(ctx, wxloginurl)
 	if err != nil {
 		return nil, errcode.NewCustomError(errcode.ErrCodeInternalError,
 			"获取微信AccessToken异常，请重试")
@@ -91,11 +92,11 @@ type WxOpenUserInfo struct {
 }
 
 //GetWxOpenUserInfo 微信开放平台获取用户信息
-func GetWxOpenUserInfo(access_token, openid string) (*WxOpenUserInfo, error) {
+func GetWxOpenUserInfo(ctx context.Context, access_token, openid string) (*WxOpenUserInfo, error) {
 	wxurl := "https://api.weixin.qq.com/sns/userinfo?" +
 		fmt.Sprintf("access_token=%s&openid=%s", access_token, openid)
 
-	resp, err := http.Get(wxurl)
+	resp, err := utils.HttpGet(ctx, wxurl)
 	if err != nil {
 		return nil, errcode.NewCustomError(errcode.ErrCodeInternalError,
 			"获取微信用户信息异常，请重试")
@@ -123,9 +124,9 @@ type WxOffiAcctAccessTokenResponse struct {
 }
 
 //GetWxOffiAcctAccessToken 获取微信公众号AccessToken
-func GetWxOffiAcctAccessToken(appid, secret string) (*WxOffiAcctAccessTokenResponse, error) {
+func GetWxOffiAcctAccessToken(ctx context.Context, appid, secret string) (*WxOffiAcctAccessTokenResponse, error) {
 	host := "https://api.weixin.qq.com/cgi-bin/token?appid=%s&secret=%s&grant_type=client_credential"
-	resp, err := http.Get(fmt.Sprintf(host, appid, secret))
+	resp, err := utils.HttpGet(ctx, fmt.Sprintf(host, appid, secret))
 	if err != nil {
 		return nil, err
 	}
@@ -147,9 +148,9 @@ type WxOffiAcctJSAPITicketResponse struct {
 }
 
 //GetWxOffiAcctJSAPITicket 获取微信公众号JS API Ticket
-func GetWxOffiAcctJSAPITicket(access_token string) (*WxOffiAcctJSAPITicketResponse, error) {
+func GetWxOffiAcctJSAPITicket(ctx context.Context, access_token string) (*WxOffiAcctJSAPITicketResponse, error) {
 	host := "https://api.weixin.qq.com/cgi-bin/ticket/getticket?access_token=%s&type=jsapi"
-	resp, err := http.Get(fmt.Sprintf(host, access_token))
+	resp, err := utils.HttpGet(ctx, fmt.Sprintf(host, access_token))
 	if err != nil {
 		return nil, err
 	}
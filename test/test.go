@@ -152,7 +152,7 @@ func test_jwt() {
 
 func test_sts() {
 	policy := "{\"statement\":[{\"action\":[\"name/cos:PutObject\",\"name/cos:PostObject\",\"name/cos:InitiateMultipartUpload\",\"name/cos:UploadPart\",\"name/cos:CompleteMultipartUpload\",\"name/cos:AbortMultipartUpload\"],\"effect\":\"allow\",\"resource\":[\"qcs::cos:ap-guangzhou:uid/APPID:bucket-name/*\"]}],\"version\":\"2.0\"}"
-	resp, err := tencent.GetFederationToken("dummy", policy)
+	resp, err := tencent.GetFederationToken(context.Background(), "dummy", policy)
 	if err != nil {
 		fmt.Println(err)
 		return
@@ -205,7 +205,7 @@ func test_QQDocs() {
 	access_token := ""
 	if len(access_token) == 0 {
 		// Step 1. get access token
-		resp, err := tencent.QQDocsToken(appid, appsecret, redirecturl, code)
+		resp, err := tencent.QQDocsToken(context.Background(), appid, appsecret, redirecturl, code)
 		if err != nil {
 			fmt.Println(err)
 			return
@@ -219,7 +219,7 @@ func test_QQDocs() {
 
 	// Step 2. convert encodedid -> fileid
 	{
-		resp2, err := tencent.QQDocsConverter(appid, access_token, openid, encodedID, 2)
+		resp2, err := tencent.QQDocsConverter(context.Background(), appid, access_token, openid, encodedID, 2)
 		if err != nil {
 			fmt.Println(err)
 			return
@@ -231,7 +231,7 @@ func test_QQDocs() {
 
 	// Step 3. get temp url
 	{
-		resp3, err := tencent.QueryQQDocsTempUrl(appid, access_token, openid, fileID)
+		resp3, err := tencent.QueryQQDocsTempUrl(context.Background(), appid, access_token, openid, fileID)
 		if err != nil {
 			fmt.Println(err)
 			return
@@ -331,6 +331,7 @@ func testShit() {
 func testSeeWo() {
 	if false {
 		resp, err := seewo.GetSeeWoAccessToken(
+			context.Background(),
 			"",
 			"",
 			"",
@@ -342,6 +343,7 @@ func testSeeWo() {
 
 	if true {
 		resp, err := seewo.GetSeeWoUserInfo(
+			context.Background(),
 			"",
 			"",
 			"",
@@ -354,7 +356,7 @@ func testSeeWo() {
 
 func testAtta() {
 	for i := 1; i < 10; i++ {
-		atta.ReportBackendRequestStatus("", "", "",
+		atta.ReportBackendRequestStatus(context.Background(), "", "", "",
 			"/api/v2/Login", "OK", 200, i)
 	}
 }
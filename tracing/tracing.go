@@ -0,0 +1,124 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"ginfra/config"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "ginfra"
+
+//Options 链路追踪配置
+type Options struct {
+	Enable      bool
+	ServiceName string
+	Exporter    string  // otlp | stdout
+	Endpoint    string  // otlp http endpoint, host:port
+	URLPath     string  // otlp http url path, 默认/v1/traces
+	Insecure    bool    // otlp 是否使用http
+	SampleRate  float64 // 采样率 0~1，上游已采样的请求始终跟随上游
+
+	// stdout exporter的输出，为空时输出到os.Stdout，主要用于单元测试
+	Writer io.Writer `mapstructure:"-"`
+}
+
+//LoadOptions 从配置文件tracing字段加载配置
+func LoadOptions(cfg *config.Config) (*Options, error) {
+	opts := &Options{
+		ServiceName: tracerName,
+		Exporter:    "otlp",
+		SampleRate:  1,
+	}
+	err := cfg.UnmarshalKey("tracing", opts)
+	return opts, err
+}
+
+//Init 初始化全局TracerProvider及W3C traceparent传播，返回的函数用于退出时flush
+// 未开启时使用otel默认的noop provider，埋点代码无额外开销
+func Init(ctx context.Context, opts *Options) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+	if !opts.Enable {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch opts.Exporter {
+	case "otlp":
+		clientOpts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(opts.Endpoint)}
+		if len(opts.URLPath) > 0 {
+			clientOpts = append(clientOpts, otlptracehttp.WithURLPath(opts.URLPath))
+		}
+		if opts.Insecure {
+			clientOpts = append(clientOpts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, clientOpts...)
+	case "stdout":
+		w := opts.Writer
+		if w == nil {
+			w = os.Stdout
+		}
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(w))
+	default:
+		err = fmt.Errorf("unsupported tracing exporter: %s", opts.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRate))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceNameKey.String(opts.ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+//Tracer 获取ginfra的tracer
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+//Start 创建span，ctx中有span时作为子span
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, opts...)
+}
+
+//StartClient 创建调用外部服务的client span
+func StartClient(ctx context.Context, name string) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+}
+
+//End 结束span，err不为空时记录错误
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+//IDs 获取ctx中span的TraceID和SpanID，无有效span时返回空串
+func IDs(ctx context.Context) (traceID, spanID string) {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return "", ""
+	}
+	return sc.TraceID().String(), sc.SpanID().String()
+}
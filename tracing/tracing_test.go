@@ -0,0 +1,50 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Transport(t *testing.T) {
+	var buf bytes.Buffer
+	shutdown, err := Init(context.Background(), &Options{
+		Enable:      true,
+		ServiceName: "ginfra-test",
+		Exporter:    "stdout",
+		SampleRate:  1,
+		Writer:      &buf,
+	})
+	convey.Convey("tracing.Init", t, func() {
+		convey.So(err, convey.ShouldBeNil)
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	ctx, span := Start(context.Background(), "parent")
+	traceID, _ := IDs(ctx)
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/sns/oauth2?secret=xxxx", nil)
+	resp, err := NewHTTPClient().Do(req)
+	if err == nil {
+		resp.Body.Close()
+	}
+	span.End()
+	shutdown(context.Background())
+
+	out := buf.String()
+	convey.Convey("tracing.Transport", t, func() {
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(len(traceID), convey.ShouldEqual, 32)
+		convey.So(strings.Count(out, traceID), convey.ShouldBeGreaterThanOrEqualTo, 2)
+		convey.So(out, convey.ShouldContainSubstring, "/sns/oauth2")
+		convey.So(out, convey.ShouldNotContainSubstring, "secret=xxxx")
+	})
+}
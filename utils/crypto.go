@@ -3,7 +3,11 @@ package utils
 import (
 	"crypto/hmac"
 	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"io"
+	"math/big"
 )
 
 func MD5(content []byte) []byte {
@@ -17,3 +21,25 @@ func HmacMD5(content []byte, key string) []byte {
 	h.Write(content)
 	return h.Sum(nil)
 }
+
+//SHA256Hex 计算sha256并返回16进制字符串
+func SHA256Hex(content string) string {
+	b := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(b[:])
+}
+
+const alphanumeric = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+//RandomString 使用crypto/rand生成指定长度的随机字符串(字母+数字)
+func RandomString(length int) (string, error) {
+	buf := make([]byte, length)
+	max := big.NewInt(int64(len(alphanumeric)))
+	for i := range buf {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		buf[i] = alphanumeric[n.Int64()]
+	}
+	return string(buf), nil
+}
@@ -4,6 +4,8 @@ import (
 	"net/smtp"
 )
 
+//SendMail 使用SMTP PLAIN认证发送已生成的邮件
+// Deprecated: 使用mail包，支持模板、附件、TLS及发送队列
 func SendMail(from, password string, smtpHost, smtpPort string, message []byte, to []string) error {
 	// Authentication.
 	auth := smtp.PlainAuth("", from, password, smtpHost)
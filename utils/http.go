@@ -2,13 +2,20 @@ package utils
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+
+	"ginfra/tracing"
 )
 
-func GetRequest(url string, headers map[string]string) ([]byte, error) {
-	req, err := http.NewRequest("GET", url, nil)
+//HttpClient 外部调用使用的http client，每次调用生成一个client span
+var HttpClient = tracing.NewHTTPClient()
+
+//GetRequest 发送GET请求，非2xx返回错误
+func GetRequest(ctx context.Context, url string, headers map[string]string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -16,8 +23,7 @@ func GetRequest(url string, headers map[string]string) ([]byte, error) {
 		req.Header.Set(k, v)
 	}
 
-	client := &http.Client{}
-	response, err := client.Do(req)
+	response, err := HttpClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -39,8 +45,9 @@ func GetRequest(url string, headers map[string]string) ([]byte, error) {
 	return body, nil
 }
 
-func PostRequest(url string, headers map[string]string, reqbody []byte) ([]byte, error) {
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(reqbody))
+//PostRequest 发送POST请求，非2xx返回错误
+func PostRequest(ctx context.Context, url string, headers map[string]string, reqbody []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(reqbody))
 	if err != nil {
 		return nil, err
 	}
@@ -48,8 +55,7 @@ func PostRequest(url string, headers map[string]string, reqbody []byte) ([]byte,
 		req.Header.Set(k, v)
 	}
 
-	client := &http.Client{}
-	response, err := client.Do(req)
+	response, err := HttpClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -70,3 +76,12 @@ func PostRequest(url string, headers map[string]string, reqbody []byte) ([]byte,
 
 	return body, nil
 }
+
+//HttpGet 发送GET请求，返回原始response，由调用方判断状态码并关闭Body
+func HttpGet(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return HttpClient.Do(req)
+}
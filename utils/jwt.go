@@ -1,14 +1,26 @@
 package utils
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/dgrijalva/jwt-go/v4"
 )
 
 // 定义载荷
 type CustomClaims struct {
-	Data []byte `json:"data"`
+	Data      []byte    `json:"data"`
+	AuthTime  *jwt.Time `json:"auth_time,omitempty"` // 登录时间，续期时不变，用于限制最长会话时间
+	MFA       int       `json:"mfa,omitempty"`       // 二次验证级别
+	MFATime   *jwt.Time `json:"mfa_time,omitempty"`  // 二次验证时间，续期时不变
+	SessionID string    `json:"sid,omitempty"`       // 登录会话标识
 	// StandardClaims结构体实现了Claims接口(Valid()函数)
 	jwt.StandardClaims
 }
@@ -113,3 +125,73 @@ func ParseJWTTokenFromMapWithRS256(publicKey []byte, tokenStr string) (
 
 	return nil, errors.New("Token无效")
 }
+
+// ParsePrivateKeyFromPEM 解析PEM格式的私钥，支持PKCS1(RSA)、SEC1(EC)及PKCS8(RSA、EC、Ed25519)
+func ParsePrivateKeyFromPEM(key []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(key)
+	if block == nil {
+		return nil, errors.New("私钥不是PEM格式")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+	privateKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := privateKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("不支持的私钥类型: %T", privateKey)
+	}
+	return signer, nil
+}
+
+// ParsePublicKeyFromPEM 解析PEM格式的公钥，支持PKCS1(RSA)及PKIX(RSA、EC、Ed25519)
+func ParsePublicKeyFromPEM(key []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(key)
+	if block == nil {
+		return nil, errors.New("公钥不是PEM格式")
+	}
+
+	if block.Type == "RSA PUBLIC KEY" {
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
+
+// SigningMethodForKey 根据公钥类型选择签名算法：RSA为RS256，EC为ES256/ES384/ES512，Ed25519为EdDSA
+func SigningMethodForKey(publicKey crypto.PublicKey) (jwt.SigningMethod, error) {
+	switch k := publicKey.(type) {
+	case *rsa.PublicKey:
+		return jwt.SigningMethodRS256, nil
+	case *ecdsa.PublicKey:
+		switch k.Curve.Params().BitSize {
+		case 256:
+			return jwt.SigningMethodES256, nil
+		case 384:
+			return jwt.SigningMethodES384, nil
+		case 521:
+			return jwt.SigningMethodES512, nil
+		}
+		return nil, fmt.Errorf("不支持的EC曲线: %s", k.Curve.Params().Name)
+	case ed25519.PublicKey:
+		return SigningMethodEd25519, nil
+	}
+	return nil, fmt.Errorf("不支持的公钥类型: %T", publicKey)
+}
+
+// ValidateTimeClaims 使用指定的当前时间校验exp、nbf，不依赖全局的jwt.TimeFunc
+// 配合jwt.WithoutClaimsValidation使用，错误类型与jwt库一致
+func ValidateTimeClaims(claims *jwt.StandardClaims, now time.Time, leeway time.Duration) error {
+	if claims.ExpiresAt != nil && now.After(claims.ExpiresAt.Add(leeway)) {
+		return &jwt.TokenExpiredError{At: now, ExpiredBy: now.Sub(claims.ExpiresAt.Time)}
+	}
+	if claims.NotBefore != nil && now.Before(claims.NotBefore.Add(-leeway)) {
+		return &jwt.TokenNotValidYetError{At: now, EarlyBy: claims.NotBefore.Sub(now)}
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"golang.org/x/crypto/bcrypt"
+)
+
+// bcrypt只使用密码的前72字节
+const MaxPasswordLength = 72
+
+//HashPassword 使用bcrypt计算密码哈希，cost为0时使用默认值
+func HashPassword(password string, cost int) (string, error) {
+	if cost == 0 {
+		cost = bcrypt.DefaultCost
+	}
+	b, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+//CheckPassword 校验密码与bcrypt哈希是否匹配
+func CheckPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func Test_TOTP(t *testing.T) {
+	// RFC 6238 附录B的SHA1测试向量，密钥为ASCII的12345678901234567890，取后6位
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	vectors := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1111111111: "050471",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+
+	convey.Convey("rfc6238 vectors", t, func() {
+		for ts, code := range vectors {
+			got, err := HOTP(secret, uint64(TOTPCounter(time.Unix(ts, 0))))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(got, convey.ShouldEqual, code)
+		}
+	})
+
+	convey.Convey("validate with skew", t, func() {
+		now := time.Unix(1111111111, 0)
+		counter, ok := ValidateTOTP(secret, "050471", now, 1)
+		convey.So(ok, convey.ShouldBeTrue)
+		convey.So(counter, convey.ShouldEqual, TOTPCounter(now))
+
+		prev, _ := HOTP(secret, uint64(TOTPCounter(now)-1))
+		counter, ok = ValidateTOTP(secret, prev, now, 1)
+		convey.So(ok, convey.ShouldBeTrue)
+		convey.So(counter, convey.ShouldEqual, TOTPCounter(now)-1)
+
+		old, _ := HOTP(secret, uint64(TOTPCounter(now)-2))
+		_, ok = ValidateTOTP(secret, old, now, 1)
+		convey.So(ok, convey.ShouldBeFalse)
+
+		_, ok = ValidateTOTP(secret, "12345", now, 1)
+		convey.So(ok, convey.ShouldBeFalse)
+	})
+
+	convey.Convey("generate secret", t, func() {
+		s, err := GenerateTOTPSecret()
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(len(s), convey.ShouldEqual, 32)
+		_, err = HOTP(s, 1)
+		convey.So(err, convey.ShouldBeNil)
+	})
+
+	convey.Convey("otpauth uri", t, func() {
+		u, err := url.Parse(TOTPURI("ginfra", "alice@qq.com", secret))
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(u.Scheme, convey.ShouldEqual, "otpauth")
+		convey.So(u.Host, convey.ShouldEqual, "totp")
+		convey.So(u.Path, convey.ShouldEqual, "/ginfra:alice@qq.com")
+		convey.So(u.Query().Get("secret"), convey.ShouldEqual, secret)
+		convey.So(u.Query().Get("issuer"), convey.ShouldEqual, "ginfra")
+	})
+}
+
+func Test_AesGCM(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	convey.Convey("round trip", t, func() {
+		ct, err := AesGCMEncrypt([]byte("secret"), key)
+		convey.So(err, convey.ShouldBeNil)
+		pt, err := AesGCMDecrypt(ct, key)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(string(pt), convey.ShouldEqual, "secret")
+
+		ct[len(ct)-1] ^= 1
+		_, err = AesGCMDecrypt(ct, key)
+		convey.So(err, convey.ShouldNotBeNil)
+
+		_, err = AesGCMDecrypt([]byte("short"), key)
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,184 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"ginfra/errcode"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// 自定义校验规则使用的格式
+var (
+	phoneRegexp  = regexp.MustCompile(`^\+?[0-9]{6,15}$`)
+	openidRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]{6,128}$`)
+	envIDRegexp  = regexp.MustCompile(`^[a-z][a-z0-9-]{2,48}[a-z0-9]$`) // 云开发环境ID
+)
+
+var (
+	messagesMu sync.RWMutex
+	messages   = make(map[string]map[string]string)
+)
+
+// 未注册消息的规则使用的消息
+var defaultMessages = map[string]string{
+	errcode.LangZH: "{field}格式错误",
+	errcode.LangEN: "{field} is invalid",
+}
+
+func init() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		panic("validation: gin validator is not validator/v10")
+	}
+	// 错误详情中的字段名与JSON请求体一致
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if len(name) == 0 {
+			return f.Name
+		}
+		return name
+	})
+
+	for rule, msgs := range builtinMessages {
+		RegisterMessages(rule, msgs)
+	}
+	must(Register("phone", matchString(phoneRegexp), map[string]string{
+		errcode.LangZH: "{field}不是有效的手机号",
+		errcode.LangEN: "{field} must be a valid phone number",
+	}))
+	must(Register("openid", matchString(openidRegexp), map[string]string{
+		errcode.LangZH: "{field}不是有效的openid",
+		errcode.LangEN: "{field} must be a valid openid",
+	}))
+	must(Register("envid", matchString(envIDRegexp), map[string]string{
+		errcode.LangZH: "{field}不是有效的环境ID",
+		errcode.LangEN: "{field} must be a valid environment ID",
+	}))
+}
+
+func must(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
+
+func matchString(re *regexp.Regexp) validator.Func {
+	return func(fl validator.FieldLevel) bool {
+		return re.MatchString(fl.Field().String())
+	}
+}
+
+//IsPhone 是否为手机号，可带+国家码
+func IsPhone(s string) bool {
+	return phoneRegexp.MatchString(s)
+}
+
+//Register 注册自定义校验规则及各语言的错误消息，需在处理请求前调用
+// 消息模板中{field}为字段名，{param}为规则参数
+func Register(tag string, fn validator.Func, msgs map[string]string) error {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return errors.New("validation: gin validator is not validator/v10")
+	}
+	if err := v.RegisterValidation(tag, fn); err != nil {
+		return err
+	}
+	RegisterMessages(tag, msgs)
+	return nil
+}
+
+//RegisterMessages 注册校验规则各语言的错误消息，覆盖已有的消息
+func RegisterMessages(tag string, msgs map[string]string) {
+	messagesMu.Lock()
+	defer messagesMu.Unlock()
+	messages[tag] = msgs
+}
+
+// ruleMessages 规则的消息模板，min、max等规则对字符串、数组区分长度和数值
+func ruleMessages(tag string, kind reflect.Kind) map[string]string {
+	messagesMu.RLock()
+	defer messagesMu.RUnlock()
+	switch kind {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		if msgs, ok := messages[tag+"_len"]; ok {
+			return msgs
+		}
+	}
+	if msgs, ok := messages[tag]; ok {
+		return msgs
+	}
+	return defaultMessages
+}
+
+//Details 将请求参数绑定错误转换为字段级的错误详情，不是校验或JSON类型错误时返回空
+func Details(err error) []errcode.Detail {
+	var verrs validator.ValidationErrors
+	if errors.As(err, &verrs) {
+		details := make([]errcode.Detail, 0, len(verrs))
+		for _, fe := range verrs {
+			details = append(details, newDetail(fieldPath(fe.Namespace()), fe.Tag(), fe.Param(), fe.Kind()))
+		}
+		return details
+	}
+	var terr *json.UnmarshalTypeError
+	if errors.As(err, &terr) {
+		return []errcode.Detail{newDetail(terr.Field, "type", terr.Type.String(), reflect.Invalid)}
+	}
+	return nil
+}
+
+func newDetail(field, rule, param string, kind reflect.Kind) errcode.Detail {
+	tpl := ruleMessages(rule, kind)
+	r := strings.NewReplacer("{field}", field, "{param}", param)
+	msgs := make(map[string]string, len(tpl))
+	for lang, msg := range tpl {
+		msgs[lang] = r.Replace(msg)
+	}
+	return errcode.NewDetail(field, rule, msgs)
+}
+
+// fieldPath 去掉Namespace中的请求结构体名，如GetTicketRequest.EnvID为EnvID
+func fieldPath(ns string) string {
+	if i := strings.IndexByte(ns, '.'); i >= 0 {
+		return ns[i+1:]
+	}
+	return ns
+}
+
+var builtinMessages = map[string]map[string]string{
+	"required":         {errcode.LangZH: "{field}为必填项", errcode.LangEN: "{field} is required"},
+	"required_if":      {errcode.LangZH: "{field}为必填项", errcode.LangEN: "{field} is required"},
+	"required_with":    {errcode.LangZH: "{field}为必填项", errcode.LangEN: "{field} is required"},
+	"required_without": {errcode.LangZH: "{field}与{param}至少填写一项", errcode.LangEN: "{field} is required when {param} is absent"},
+	"len":              {errcode.LangZH: "{field}必须等于{param}", errcode.LangEN: "{field} must be {param}"},
+	"len_len":          {errcode.LangZH: "{field}长度必须为{param}", errcode.LangEN: "{field} must be {param} characters or items long"},
+	"min":              {errcode.LangZH: "{field}不能小于{param}", errcode.LangEN: "{field} must be at least {param}"},
+	"min_len":          {errcode.LangZH: "{field}长度不能小于{param}", errcode.LangEN: "{field} must be at least {param} characters or items long"},
+	"max":              {errcode.LangZH: "{field}不能大于{param}", errcode.LangEN: "{field} must be at most {param}"},
+	"max_len":          {errcode.LangZH: "{field}长度不能大于{param}", errcode.LangEN: "{field} must be at most {param} characters or items long"},
+	"gt":               {errcode.LangZH: "{field}必须大于{param}", errcode.LangEN: "{field} must be greater than {param}"},
+	"gt_len":           {errcode.LangZH: "{field}长度必须大于{param}", errcode.LangEN: "{field} must be longer than {param} characters or items"},
+	"gte":              {errcode.LangZH: "{field}不能小于{param}", errcode.LangEN: "{field} must be at least {param}"},
+	"gte_len":          {errcode.LangZH: "{field}长度不能小于{param}", errcode.LangEN: "{field} must be at least {param} characters or items long"},
+	"lt":               {errcode.LangZH: "{field}必须小于{param}", errcode.LangEN: "{field} must be less than {param}"},
+	"lt_len":           {errcode.LangZH: "{field}长度必须小于{param}", errcode.LangEN: "{field} must be shorter than {param} characters or items"},
+	"lte":              {errcode.LangZH: "{field}不能大于{param}", errcode.LangEN: "{field} must be at most {param}"},
+	"lte_len":          {errcode.LangZH: "{field}长度不能大于{param}", errcode.LangEN: "{field} must be at most {param} characters or items long"},
+	"oneof":            {errcode.LangZH: "{field}必须是[{param}]中的一个", errcode.LangEN: "{field} must be one of [{param}]"},
+	"email":            {errcode.LangZH: "{field}不是有效的邮箱", errcode.LangEN: "{field} must be a valid email address"},
+	"url":              {errcode.LangZH: "{field}不是有效的URL", errcode.LangEN: "{field} must be a valid URL"},
+	"uuid":             {errcode.LangZH: "{field}不是有效的UUID", errcode.LangEN: "{field} must be a valid UUID"},
+	"numeric":          {errcode.LangZH: "{field}必须是数字", errcode.LangEN: "{field} must be numeric"},
+	"alphanum":         {errcode.LangZH: "{field}只能包含字母和数字", errcode.LangEN: "{field} must contain only letters and digits"},
+	"type":             {errcode.LangZH: "{field}类型错误，应为{param}", errcode.LangEN: "{field} must be of type {param}"},
+}
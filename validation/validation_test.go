@@ -0,0 +1,77 @@
+package validation
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ginfra/errcode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+type testItem struct {
+	Name string `binding:"required"`
+}
+
+type testRequest struct {
+	Phone  string     `binding:"required,phone"`
+	EnvID  string     `json:"env_id" binding:"omitempty,envid"`
+	Openid string     `binding:"omitempty,openid"`
+	Count  int        `binding:"min=1"`
+	Tags   []string   `binding:"max=2"`
+	Items  []testItem `binding:"dive"`
+}
+
+func bind(body string) error {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	var req testRequest
+	return c.ShouldBindJSON(&req)
+}
+
+func Test_Details(t *testing.T) {
+	convey.Convey("validation errors", t, func() {
+		err := bind(`{"Phone":"abc","env_id":"Bad_Env","Openid":"x","Count":0,"Tags":["a","b","c"],"Items":[{"Name":""}]}`)
+		details := Details(err)
+		convey.So(details, convey.ShouldHaveLength, 6)
+
+		byField := make(map[string]errcode.Detail)
+		for _, d := range details {
+			byField[d.Field] = d
+		}
+		convey.So(byField["Phone"].Rule, convey.ShouldEqual, "phone")
+		convey.So(byField["Phone"].Message, convey.ShouldEqual, "Phone不是有效的手机号")
+		convey.So(byField["env_id"].Rule, convey.ShouldEqual, "envid")
+		convey.So(byField["Openid"].Rule, convey.ShouldEqual, "openid")
+		convey.So(byField["Count"].Message, convey.ShouldEqual, "Count不能小于1")
+		convey.So(byField["Tags"].Message, convey.ShouldEqual, "Tags长度不能大于2")
+		convey.So(byField["Items[0].Name"].Rule, convey.ShouldEqual, "required")
+
+		en := errcode.LocalizeDetails(details, errcode.LangEN)
+		convey.So(en[0].Message, convey.ShouldEqual, "Phone must be a valid phone number")
+		convey.So(details[0].Message, convey.ShouldEqual, "Phone不是有效的手机号")
+	})
+
+	convey.Convey("valid request", t, func() {
+		err := bind(`{"Phone":"+8613711112222","env_id":"prod-1a2b3c","Openid":"oXyz_123456-abc","Count":1}`)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(Details(err), convey.ShouldBeEmpty)
+	})
+
+	convey.Convey("json type error", t, func() {
+		details := Details(bind(`{"Phone":"13711112222","Count":"one"}`))
+		convey.So(details, convey.ShouldHaveLength, 1)
+		convey.So(details[0].Field, convey.ShouldEqual, "Count")
+		convey.So(details[0].Rule, convey.ShouldEqual, "type")
+		convey.So(details[0].Message, convey.ShouldEqual, "Count类型错误，应为int")
+	})
+
+	convey.Convey("unregistered rule", t, func() {
+		convey.So(Register("even", nil, nil), convey.ShouldNotBeNil)
+		convey.So(newDetail("Size", "even", "", 0).Message, convey.ShouldEqual, "Size格式错误")
+	})
+}